@@ -2,4 +2,10 @@ module github.com/rikkicom/file-syncer
 
 go 1.25.4
 
-require gopkg.in/natefinch/lumberjack.v2 v2.2.1
+require (
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/crypto v0.54.0
+	gopkg.in/natefinch/lumberjack.v2 v2.2.1
+)
+
+require golang.org/x/sys v0.47.0 // indirect
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// logOptions holds the logging flags.
+type logOptions struct {
+	// Verbose enables debug-level logging
+	Verbose bool
+	// Quiet only logs errors and keeps stdout clean
+	Quiet bool
+	// Stderr logs to stderr instead of stdout, which the run keeps for the
+	// result it prints
+	Stderr bool
+	// Format is either "json" or "text"
+	Format string
+	// File is the path of the rotated log file. Empty or "stdout" disables
+	// file logging.
+	File string
+	// MaxSize is the size in megabytes at which the log file is rotated
+	MaxSize int
+	// MaxBackups is the number of rotated files to keep
+	MaxBackups int
+	// MaxAge is the number of days to keep rotated files
+	MaxAge int
+	// Compress gzips rotated files
+	Compress bool
+}
+
+// Log formats.
+const (
+	logFormatJSON = "json"
+	logFormatText = "text"
+)
+
+func (o logOptions) validate() error {
+	if o.Verbose && o.Quiet {
+		return fmt.Errorf("-verbose and -quiet cannot be used together")
+	}
+	if o.Format != logFormatJSON && o.Format != logFormatText {
+		return fmt.Errorf("log format must be either 'json' or 'text'")
+	}
+	if o.MaxSize < 0 || o.MaxBackups < 0 || o.MaxAge < 0 {
+		return fmt.Errorf("-log-max-size, -log-max-backups and -log-max-age must not be negative")
+	}
+	return nil
+}
+
+// fileLoggingEnabled reports whether logs should also be written to a file.
+func (o logOptions) fileLoggingEnabled() bool {
+	return o.File != "" && o.File != "stdout"
+}
+
+// level returns the minimum level to log.
+func (o logOptions) level() slog.Level {
+	switch {
+	case o.Quiet:
+		return slog.LevelError
+	case o.Verbose:
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func initLogger(opts logOptions) {
+	var file io.Writer
+	if rotator := newLogRotator(opts); rotator != nil {
+		file = rotator
+	}
+
+	var out io.Writer = os.Stdout
+	if opts.Stderr {
+		out = os.Stderr
+	}
+	logger = newLogger(out, file, opts)
+	slog.SetDefault(logger)
+}
+
+// newLogRotator configures log rotation for the log file, returning nil when
+// file logging is disabled.
+func newLogRotator(opts logOptions) *lumberjack.Logger {
+	if !opts.fileLoggingEnabled() {
+		return nil
+	}
+	return &lumberjack.Logger{
+		Filename:   opts.File,
+		MaxSize:    opts.MaxSize,    // megabytes
+		MaxBackups: opts.MaxBackups, // number of old log files to keep
+		MaxAge:     opts.MaxAge,     // days
+		Compress:   opts.Compress,
+	}
+}
+
+// newLogger creates a logger writing to both stdout and file in the configured
+// format. A nil file logs to stdout only. In quiet mode stdout is left out so
+// scheduled runs produce no output, unless there is no file to log to.
+func newLogger(stdout, file io.Writer, opts logOptions) *slog.Logger {
+	// Create multi-writer for both file and stdout
+	var writer io.Writer
+	switch {
+	case file == nil:
+		writer = stdout
+	case opts.Quiet:
+		writer = file
+	default:
+		writer = io.MultiWriter(stdout, file)
+	}
+
+	handlerOpts := &slog.HandlerOptions{
+		Level: opts.level(),
+	}
+
+	// Create slog handler with text format for local use, JSON otherwise
+	var handler slog.Handler
+	if opts.Format == logFormatText {
+		handler = slog.NewTextHandler(writer, handlerOpts)
+	} else {
+		handler = slog.NewJSONHandler(writer, handlerOpts)
+	}
+
+	return slog.New(handler)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewLoggerLevels(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       logOptions
+		wantDebug  bool
+		wantInfo   bool
+		wantStdout bool
+	}{
+		{name: "default", opts: logOptions{Format: logFormatJSON}, wantDebug: false, wantInfo: true, wantStdout: true},
+		{name: "verbose", opts: logOptions{Verbose: true, Format: logFormatJSON}, wantDebug: true, wantInfo: true, wantStdout: true},
+		{name: "quiet", opts: logOptions{Quiet: true, Format: logFormatJSON}, wantDebug: false, wantInfo: false, wantStdout: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, file bytes.Buffer
+			l := newLogger(&stdout, &file, tt.opts)
+
+			l.Debug("debug line")
+			l.Info("info line")
+			l.Error("error line")
+
+			output := file.String()
+			if got := strings.Contains(output, "debug line"); got != tt.wantDebug {
+				t.Errorf("debug line logged = %v, want %v", got, tt.wantDebug)
+			}
+			if got := strings.Contains(output, "info line"); got != tt.wantInfo {
+				t.Errorf("info line logged = %v, want %v", got, tt.wantInfo)
+			}
+			if !strings.Contains(output, "error line") {
+				t.Error("error line should always be logged")
+			}
+			if got := stdout.Len() > 0; got != tt.wantStdout {
+				t.Errorf("stdout written = %v, want %v", got, tt.wantStdout)
+			}
+		})
+	}
+}
+
+func TestNewLoggerFormats(t *testing.T) {
+	tests := []struct {
+		format   string
+		wantJSON bool
+	}{
+		{format: logFormatJSON, wantJSON: true},
+		{format: logFormatText, wantJSON: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var stdout, file bytes.Buffer
+			l := newLogger(&stdout, &file, logOptions{Format: tt.format})
+			l.Info("File Syncer started", "mode", "push")
+
+			// Both destinations must receive the entry in either format
+			if stdout.String() != file.String() {
+				t.Errorf("stdout and file output differ: %q vs %q", stdout.String(), file.String())
+			}
+
+			line := strings.TrimSpace(stdout.String())
+			if got := json.Valid([]byte(line)); got != tt.wantJSON {
+				t.Errorf("output is JSON = %v, want %v: %s", got, tt.wantJSON, line)
+			}
+			if !tt.wantJSON && !strings.Contains(line, `msg="File Syncer started" mode=push`) {
+				t.Errorf("unexpected text output: %s", line)
+			}
+		})
+	}
+}
+
+func TestLogOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    logOptions
+		wantErr bool
+	}{
+		{name: "defaults", opts: logOptions{Format: logFormatJSON}},
+		{name: "verbose text", opts: logOptions{Verbose: true, Format: logFormatText}},
+		{name: "verbose and quiet", opts: logOptions{Verbose: true, Quiet: true, Format: logFormatJSON}, wantErr: true},
+		{name: "unknown format", opts: logOptions{Format: "xml"}, wantErr: true},
+		{name: "zero rotation values", opts: logOptions{Format: logFormatJSON, MaxSize: 0, MaxBackups: 0, MaxAge: 0}},
+		{name: "negative max size", opts: logOptions{Format: logFormatJSON, MaxSize: -1}, wantErr: true},
+		{name: "negative max backups", opts: logOptions{Format: logFormatJSON, MaxBackups: -1}, wantErr: true},
+		{name: "negative max age", opts: logOptions{Format: logFormatJSON, MaxAge: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewLogRotator(t *testing.T) {
+	opts := logOptions{
+		File:       "/var/log/file-syncer.log",
+		MaxSize:    1,
+		MaxBackups: 7,
+		MaxAge:     90,
+		Compress:   false,
+	}
+
+	rotator := newLogRotator(opts)
+	if rotator == nil {
+		t.Fatal("newLogRotator() returned nil for a log file path")
+	}
+	if rotator.Filename != opts.File {
+		t.Errorf("Filename = %q, want %q", rotator.Filename, opts.File)
+	}
+	if rotator.MaxSize != 1 || rotator.MaxBackups != 7 || rotator.MaxAge != 90 || rotator.Compress {
+		t.Errorf("unexpected rotation settings: %+v", rotator)
+	}
+
+	for _, file := range []string{"", "stdout"} {
+		if rotator := newLogRotator(logOptions{File: file}); rotator != nil {
+			t.Errorf("newLogRotator() with -log-file %q should disable file logging", file)
+		}
+	}
+}
+
+func TestNewLoggerWithoutFile(t *testing.T) {
+	var stdout bytes.Buffer
+	l := newLogger(&stdout, nil, logOptions{Quiet: true, Format: logFormatJSON})
+	l.Error("error line")
+
+	if !strings.Contains(stdout.String(), "error line") {
+		t.Error("errors should go to stdout when file logging is disabled")
+	}
+}
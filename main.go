@@ -1,472 +1,365 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 
-	"gopkg.in/natefinch/lumberjack.v2"
+	"github.com/rikkicom/file-syncer/syncer"
 )
 
-// escapeShellArg escapes a string for safe use as a shell argument in GIT_SSH_COMMAND.
-// It uses backslash escaping for special shell characters to prevent command injection
-// while maintaining compatibility with how shells process environment variables.
-//
-// Example: "/path/with spaces/key.pem" -> "/path/with\\ spaces/key.pem"
-func escapeShellArg(s string) string {
-	// Characters that need escaping in shell
-	needsEscape := " \t\n\r\"'`$\\|&;<>(){}[]!*?"
-	var result strings.Builder
-	for _, c := range s {
-		if strings.ContainsRune(needsEscape, c) {
-			result.WriteRune('\\')
-		}
-		result.WriteRune(c)
-	}
-	return result.String()
-}
-
-// buildGitSSHCommand creates the GIT_SSH_COMMAND environment variable value
-// with the specified SSH key path, properly escaped for shell execution.
-// It includes StrictHostKeyChecking=accept-new to automatically accept new host keys
-// without prompting, while still verifying known hosts for security.
-func buildGitSSHCommand(sshKeyPath string) string {
-	return fmt.Sprintf("ssh -i %s -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new", escapeShellArg(sshKeyPath))
-}
-
-const (
-	ModePush = "push"
-	ModePull = "pull"
-)
-
-type Config struct {
-	Mode       string
-	FolderPath string
-	RepoURL    string
-	Branch     string
-	SSHKeyPath string
-}
-
 var logger *slog.Logger
 
+// sshKeyEnv is the environment variable read when -ssh-key-data is not given.
+const sshKeyEnv = "GIT_SYNC_SSH_KEY"
+
 func main() {
-	// Initialize logger with rotation
-	initLogger()
+	config, logOpts := parseFlags()
+
+	// Initialize logger with rotation, keeping stdout for the result a
+	// program reads
+	logOpts.Stderr = config.PrintsResult()
+	initLogger(logOpts)
 
-	config := parseFlags()
+	if err := logOpts.validate(); err != nil {
+		logger.Error("Configuration validation failed", "error", err)
+		flag.Usage()
+		os.Exit(exitValidation)
+	}
 
-	if err := validateConfig(config); err != nil {
+	if err := config.Validate(); err != nil {
 		logger.Error("Configuration validation failed", "error", err)
 		flag.Usage()
-		os.Exit(1)
+		os.Exit(exitValidation)
 	}
 
-	if err := run(config); err != nil {
+	startRun(&config)
+	err := run(context.Background(), config)
+	if err != nil && !errors.Is(err, syncer.ErrNoChanges) {
 		logger.Error("Operation failed", "error", err)
-		os.Exit(1)
 	}
+	os.Exit(exitCode(onNoChanges(err, config.OnNoChanges)))
 }
 
-func initLogger() {
-	// Configure log rotation
-	logWriter := &lumberjack.Logger{
-		Filename:   "file-syncer.log",
-		MaxSize:    10, // megabytes
-		MaxBackups: 3,  // number of old log files to keep
-		MaxAge:     28, // days
-		Compress:   true,
+// onNoChanges applies -on-no-changes to the result of run: with exit0, a
+// push or sync that found nothing to commit counts as a plain success.
+func onNoChanges(err error, action string) error {
+	if action == syncer.NoChangesExit0 && errors.Is(err, syncer.ErrNoChanges) {
+		return nil
 	}
-
-	// Create multi-writer for both file and stdout
-	multiWriter := io.MultiWriter(os.Stdout, logWriter)
-
-	// Create slog handler with JSON format
-	handler := slog.NewJSONHandler(multiWriter, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	})
-
-	logger = slog.New(handler)
-	slog.SetDefault(logger)
+	return err
 }
 
-func parseFlags() Config {
-	config := Config{}
-
-	flag.StringVar(&config.Mode, "mode", "", "Operation mode: 'push' or 'pull'")
-	flag.StringVar(&config.FolderPath, "folder", "", "Path to the folder to sync")
-	flag.StringVar(&config.RepoURL, "repo", "", "GitHub repository URL")
-	flag.StringVar(&config.Branch, "branch", "main", "Git branch to use (default: main)")
-	flag.StringVar(&config.SSHKeyPath, "ssh-key", "", "Path to SSH private key for git operations (optional)")
+// Exit codes reported by the command.
+const (
+	exitOK         = 0 // changes were pushed, or the pull succeeded
+	exitFailure    = 1 // any failure not covered below
+	exitNoChanges  = 2 // push succeeded but there was nothing to commit
+	exitValidation = 3 // invalid options, missing folder or rejected content
+	exitGit        = 4 // git or network failure
+	exitConflict   = 5 // the remote or the source moved on, a sync conflicted, or another run holds the lock
+)
 
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
-		fmt.Fprintf(os.Stderr, "\nExamples:\n")
-		fmt.Fprintf(os.Stderr, "  Push files to repository:\n")
-		fmt.Fprintf(os.Stderr, "    %s -mode push -folder ./myfiles -repo https://github.com/user/repo.git\n\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  Pull files from repository:\n")
-		fmt.Fprintf(os.Stderr, "    %s -mode pull -folder ./myfiles -repo https://github.com/user/repo.git\n\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  Use custom SSH key:\n")
-		fmt.Fprintf(os.Stderr, "    %s -mode push -folder ./myfiles -repo git@github.com:user/repo.git -ssh-key ~/.ssh/id_rsa\n\n", os.Args[0])
+// exitCode maps the result of run to the process exit code.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, syncer.ErrNoChanges):
+		return exitNoChanges
+	case errors.Is(err, syncer.ErrPushRejected),
+		errors.Is(err, syncer.ErrLocked),
+		errors.Is(err, syncer.ErrPlanDrift),
+		errors.Is(err, syncer.ErrSyncConflict),
+		errors.Is(err, syncer.ErrTagExists):
+		return exitConflict
+	case errors.Is(err, syncer.ErrCloneFailed),
+		errors.Is(err, syncer.ErrCommitFailed),
+		errors.Is(err, syncer.ErrBranchProtected),
+		errors.Is(err, syncer.ErrPushFailed):
+		return exitGit
+	case errors.Is(err, syncer.ErrFolderMissing),
+		errors.Is(err, syncer.ErrFileTooLarge),
+		errors.Is(err, syncer.ErrSchemaViolation),
+		errors.Is(err, syncer.ErrPathTooLong),
+		errors.Is(err, syncer.ErrInvalidFileList),
+		errors.Is(err, syncer.ErrConflictMarkers),
+		errors.Is(err, syncer.ErrCaseCollision),
+		errors.Is(err, syncer.ErrLFSPointer),
+		errors.Is(err, syncer.ErrTooManyChanges),
+		errors.Is(err, syncer.ErrSpecialFile):
+		return exitValidation
+	default:
+		return exitFailure
 	}
-
-	flag.Parse()
-	return config
 }
 
-func validateConfig(config Config) error {
-	if config.Mode != ModePush && config.Mode != ModePull {
-		return fmt.Errorf("mode must be either 'push' or 'pull'")
-	}
+// printChanges writes stats to w as a single line of JSON (-list-changes).
+func printChanges(w io.Writer, stats syncer.FileChangeStats) error {
+	return json.NewEncoder(w).Encode(stats)
+}
 
-	if config.FolderPath == "" {
-		return fmt.Errorf("folder path is required")
-	}
+// patternList is a flag.Value that collects every occurrence of a repeatable
+// flag such as -include, -exclude, -commit-paths or -commit-trailer.
+type patternList []string
 
-	if config.RepoURL == "" {
-		return fmt.Errorf("repository URL is required")
-	}
+func (p *patternList) String() string {
+	return strings.Join(*p, ",")
+}
 
+func (p *patternList) Set(value string) error {
+	*p = append(*p, value)
 	return nil
 }
 
-func run(config Config) error {
-	logger.Info("File Syncer started",
-		"mode", config.Mode,
-		"folder", config.FolderPath,
-		"repository", config.RepoURL,
-		"branch", config.Branch)
-
-	if config.Mode == ModePush {
-		return pushFiles(config)
-	}
-	return pullFiles(config)
+// branchList is the flag.Value of -branch. The first occurrence replaces the
+// default branch, every further one adds a branch to push to.
+type branchList struct {
+	branch *string
+	extra  *[]string
+	set    bool
 }
 
-func pushFiles(config Config) error {
-	logger.Info("Starting push operation")
-
-	// Create absolute path for folder
-	absPath, err := filepath.Abs(config.FolderPath)
-	if err != nil {
-		return fmt.Errorf("failed to resolve folder path: %w", err)
-	}
-
-	// Check if folder exists
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		return fmt.Errorf("folder does not exist: %s", absPath)
-	}
-
-	// Create temporary directory for git operations
-	tempDir, err := os.MkdirTemp("", "file-syncer-*")
-	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %w", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	// Clone the repository
-	logger.Info("Cloning repository", "url", config.RepoURL, "branch", config.Branch)
-	if err := runCommand(tempDir, config.SSHKeyPath, "git", "clone", "--branch", config.Branch, config.RepoURL, "."); err != nil {
-		// Try cloning without branch if it doesn't exist
-		logger.Info("Branch not found, cloning default branch", "branch", config.Branch)
-		if err := runCommand(tempDir, config.SSHKeyPath, "git", "clone", config.RepoURL, "."); err != nil {
-			return fmt.Errorf("failed to clone repository: %w", err)
-		}
-		// Create and checkout the branch
-		if err := runCommand(tempDir, config.SSHKeyPath, "git", "checkout", "-b", config.Branch); err != nil {
-			return fmt.Errorf("failed to create branch: %w", err)
-		}
-	}
-
-	// Sync files from source folder to repo
-	logger.Info("Syncing files", "source", absPath, "destination", tempDir)
-	if err := syncFiles(absPath, tempDir); err != nil {
-		return fmt.Errorf("failed to sync files: %w", err)
-	}
-
-	// Check if there are changes
-	output, err := runCommandOutput(tempDir, config.SSHKeyPath, "git", "status", "--porcelain")
-	if err != nil {
-		return fmt.Errorf("failed to check git status: %w", err)
+func (b *branchList) String() string {
+	if b.branch == nil {
+		return ""
 	}
+	return strings.Join(append([]string{*b.branch}, *b.extra...), ",")
+}
 
-	if strings.TrimSpace(output) == "" {
-		logger.Info("No changes to push")
+func (b *branchList) Set(value string) error {
+	if !b.set {
+		*b.branch = value
+		b.set = true
 		return nil
 	}
-
-	// Add all changes
-	logger.Info("Adding changes")
-	if err := runCommand(tempDir, config.SSHKeyPath, "git", "add", "-A"); err != nil {
-		return fmt.Errorf("failed to add changes: %w", err)
-	}
-
-	// Generate meaningful commit message based on changes
-	stats := parseGitStatus(output)
-	commitSubject, commitBody := generateCommitMessage(stats)
-	
-	// Commit changes
-	logger.Info("Committing changes", "message", commitSubject)
-	commitMessage := commitSubject
-	if commitBody != "" {
-		commitMessage = commitSubject + "\n\n" + commitBody
-	}
-	if err := runCommand(tempDir, config.SSHKeyPath, "git", "commit", "-m", commitMessage); err != nil {
-		return fmt.Errorf("failed to commit changes: %w", err)
-	}
-
-	// Push to remote
-	logger.Info("Pushing to remote", "branch", config.Branch)
-	if err := runCommand(tempDir, config.SSHKeyPath, "git", "push", "origin", config.Branch); err != nil {
-		return fmt.Errorf("failed to push changes: %w", err)
-	}
-
-	logger.Info("Push completed successfully")
+	*b.extra = append(*b.extra, value)
 	return nil
 }
 
-func pullFiles(config Config) error {
-	logger.Info("Starting pull operation")
-
-	// Create absolute path for folder
-	absPath, err := filepath.Abs(config.FolderPath)
-	if err != nil {
-		return fmt.Errorf("failed to resolve folder path: %w", err)
-	}
-
-	// Create folder if it doesn't exist
-	if err := os.MkdirAll(absPath, 0755); err != nil {
-		return fmt.Errorf("failed to create folder: %w", err)
-	}
-
-	// Create temporary directory for git operations
-	tempDir, err := os.MkdirTemp("", "file-syncer-*")
-	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %w", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	// Clone the repository
-	logger.Info("Cloning repository", "url", config.RepoURL, "branch", config.Branch)
-	if err := runCommand(tempDir, config.SSHKeyPath, "git", "clone", "--branch", config.Branch, config.RepoURL, "."); err != nil {
-		return fmt.Errorf("failed to clone repository: %w", err)
-	}
-
-	// Sync files from repo to destination folder
-	logger.Info("Syncing files", "source", tempDir, "destination", absPath)
-	if err := syncFiles(tempDir, absPath); err != nil {
-		return fmt.Errorf("failed to sync files: %w", err)
-	}
-
-	logger.Info("Pull completed successfully")
-	return nil
-}
+func parseFlags() (syncer.Config, logOptions) {
+	config := syncer.Config{}
+	logOpts := logOptions{}
 
-func syncFiles(srcDir, dstDir string) error {
-	// Walk through source directory
-	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+	flag.StringVar(&config.Mode, "mode", "", "Operation mode: 'push', 'pull' or 'sync'")
+	flag.StringVar(&config.FolderPath, "folder", "", "Path to the folder to sync")
+	flag.StringVar(&config.RepoURL, "repo", "", "Git repository URL (GitHub, GitLab, Gitea or any other git host)")
+	flag.StringVar(&config.RepoFile, "repo-file", "", "File containing the repository URL, instead of -repo")
+	config.Branch = "main"
+	flag.Var(&branchList{branch: &config.Branch, extra: &config.Branches}, "branch", "Git branch to use, or \"auto\" for the remote's default branch; repeat to push the same content to several branches")
+	flag.StringVar(&config.BranchTemplate, "branch-template", "", "Push to a new branch per run, named by this template from {{.Date}}, {{.Time}}, {{.Base}} and {{.RunID}} and created from -branch (push mode only)")
+	flag.StringVar(&config.Ref, "ref", "", "Tag, branch or commit to pull instead of the tip of -branch (pull mode only)")
+	flag.StringVar(&config.SSHKeyPath, "ssh-key", "", "Path to SSH private key for git operations (optional)")
+	flag.StringVar(&config.SSHKeyData, "ssh-key-data", "", "SSH private key contents, written to a temporary file for the run (default: $"+sshKeyEnv+")")
+	flag.BoolVar(&config.UseAgent, "use-agent", false, "Authenticate SSH with the keys in the running ssh-agent (SSH_AUTH_SOCK)")
+	flag.BoolVar(&config.UseNetrc, "use-netrc", false, "Authenticate HTTPS with the credentials in ~/.netrc and never prompt for a password")
+	flag.StringVar(&config.Proxy, "proxy", "", "HTTP or SOCKS proxy URL for git, e.g. socks5://127.0.0.1:1080")
+	flag.BoolVar(&config.InsecureHTTP, "insecure-http", false, "Allow unencrypted http:// repository URLs")
+	flag.Var((*patternList)(&config.Include), "include", "Only sync files matching this glob pattern (repeatable)")
+	flag.Var((*patternList)(&config.Exclude), "exclude", "Skip files and directories matching this glob pattern; a leading ! re-includes paths excluded by earlier patterns (repeatable)")
+	flag.BoolVar(&config.Snapshot, "snapshot", false, "Copy the folder to a staging directory first and push from that copy, so changes during the run are not committed (push mode only)")
+	flag.BoolVar(&config.FromStdin, "from-stdin", false, "Push only the files whose paths, relative to the folder, are read line by line from stdin (push mode only)")
+	flag.BoolVar(&config.AllowSingleFile, "allow-single-file", false, "Allow -folder to be a single file, pushed into the repository root (push mode only)")
+	flag.BoolVar(&config.RespectRepoGitignore, "respect-repo-gitignore", false, "Skip files ignored by the repository's .gitignore (push mode only)")
+	flag.BoolVar(&config.OnlyTracked, "only-tracked", false, "Commit only changes to files the repository already tracks; new files are left out (push mode only)")
+	flag.BoolVar(&config.ExportIgnore, "export-ignore", false, "Skip paths marked export-ignore in the folder's .gitattributes (push mode only)")
+	flag.BoolVar(&config.LFS, "lfs", false, "Fetch and push Git LFS content for files tracked by LFS (requires git-lfs)")
+	flag.BoolVar(&config.StrictLFS, "strict-lfs", false, "Fail the push instead of warning when it would commit Git LFS pointer files (push mode only)")
+	flag.IntVar(&config.MaxChanges, "max-changes", 0, "Fail the push if it would change more than this many files, 0 for no limit (push mode only)")
+	flag.BoolVar(&config.ForceLarge, "force-large", false, "Push even if the changes exceed -max-changes")
+	flag.StringVar(&config.SchemaPath, "schema", "", "Path to a JSON schema describing the required folder layout (optional)")
+	flag.BoolVar(&config.KeepEmptyDirs, "keep-empty-dirs", false, "Preserve empty directories using .gitkeep placeholders")
+	flag.BoolVar(&config.Progress, "progress", false, "Log progress with percent complete and throughput while copying files")
+	flag.StringVar(&config.RateLimit, "rate-limit", "", "Maximum file copy throughput per second, e.g. 10MB (optional)")
+	flag.StringVar(&config.CopyBuffer, "copy-buffer", "", "Size of the buffer used to copy each file, e.g. 1MB (optional)")
+	flag.IntVar(&config.CopyRetries, "copy-retries", 2, "How many times to retry copying a file after a transient I/O error such as EIO; 0 disables retries")
+	flag.BoolVar(&config.LongPaths, "long-paths", false, "Write files through \\\\?\\ paths on Windows to lift the 260-character path limit")
+	flag.StringVar(&config.MaxFileSize, "max-file-size", "", "Maximum size of a pushed file, e.g. 100MB (push mode only, optional)")
+	flag.StringVar(&config.OnOversize, "on-oversize", syncer.OversizeSkip, "What to do with files above -max-file-size: 'skip' or 'fail'")
+	flag.BoolVar(&config.NoVerify, "no-verify", false, "Skip git hooks, such as pre-commit and pre-push hooks inherited by the clone")
+	flag.BoolVar(&config.ValidateOnly, "validate-only", false, "Check the options and access to the repository and branch, then exit without syncing")
+	flag.BoolVar(&config.DryRun, "dry-run", false, "Show what would be committed without committing or pushing (push mode only)")
+	flag.BoolVar(&config.NoCommit, "no-commit", false, "Stage the changes in -work-dir for review without committing or pushing (push mode only)")
+	flag.BoolVar(&config.ListChanges, "list-changes", false, "Print the changes a push would make as JSON and stop without committing (push mode only)")
+	flag.BoolVar(&config.Diff, "diff", false, "Print the changes of modified text files during -dry-run")
+	flag.Func("diff-context", "Number of unchanged lines shown around each change by -diff (default: 3)", func(s string) error {
+		lines, err := strconv.Atoi(s)
 		if err != nil {
 			return err
 		}
-
-		// Get relative path
-		relPath, err := filepath.Rel(srcDir, path)
+		config.DiffContext = &lines
+		return nil
+	})
+	flag.StringVar(&config.PlanOutPath, "plan-out", "", "Write the planned push operations to this file without committing (push mode only)")
+	flag.Func("max-depth", "Only sync files up to this many directory levels below the folder; 0 syncs only top-level files (default: no limit)", func(s string) error {
+		depth, err := strconv.Atoi(s)
 		if err != nil {
 			return err
 		}
-
-		// Skip .git directory
-		if strings.HasPrefix(relPath, ".git") || relPath == ".git" {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Skip root directory
-		if relPath == "." {
-			return nil
-		}
-
-		dstPath := filepath.Join(dstDir, relPath)
-
-		if info.IsDir() {
-			// Create directory
-			return os.MkdirAll(dstPath, info.Mode())
-		}
-
-		// Copy file
-		return copyFile(path, dstPath, info.Mode())
+		config.MaxDepth = &depth
+		return nil
 	})
-}
+	flag.Var((*patternList)(&config.Sparse), "sparse", "Only clone and pull this directory of the repository; can be repeated (pull mode only)")
+	flag.IntVar(&config.Depth, "depth", 0, "Clone only this many commits of history (pull mode only, default: full history)")
+	flag.IntVar(&config.Jobs, "jobs", 0, "Number of files hashed concurrently when writing a plan (default: number of CPUs)")
+	flag.StringVar(&config.ApplyPlanPath, "apply-plan", "", "Push the operations of a previously saved plan, aborting if the source drifted (push mode only)")
+	flag.BoolVar(&config.CommitPerFile, "commit-per-file", false, "Commit each changed file separately, then push once (push mode only)")
+	flag.IntVar(&config.SquashAfter, "squash-after", 0, "Squash the last n sync commits into one once that many have accumulated, then push with --force-with-lease (push mode only)")
+	flag.BoolVar(&config.Amend, "amend", false, "Amend the branch tip instead of adding a commit when it has the same author email, then push with --force-with-lease (push mode only)")
+	flag.StringVar(&config.GroupBy, "group-by", "", "Commit the changes in groups, then push once; 'ext' makes one commit per file extension (push mode only)")
+	flag.StringVar(&config.CommitMessageFile, "commit-message-file", "", "Use this file's contents as the full commit message instead of the generated one (push mode only)")
+	flag.StringVar(&config.MessagePrefix, "message-prefix", "", "Prepend this text, e.g. [skip ci], to the subject of every commit, generated or from -commit-message-file")
+	flag.StringVar(&config.CommitDate, "commit-date", "", "Author and committer date of the sync commit in RFC 3339, e.g. 2024-01-02T15:04:05Z (push mode only)")
+	flag.IntVar(&config.SummaryLimit, "summary-limit", 100, "Maximum number of files listed per category in the commit message body; 0 lists all")
+	flag.Var((*patternList)(&config.CommitPaths), "commit-paths", "Only commit and push if a changed path matches this glob pattern or lies in a matching directory (repeatable, push mode only)")
+	flag.Var((*patternList)(&config.CommitTrailers), "commit-trailer", "Append a key=value trailer to the commit message; a bare Sync-Time gets the commit time (repeatable)")
+	flag.BoolVar(&config.CommitEmpty, "commit-empty", false, "Create and push an empty heartbeat commit when there are no changes (push mode only)")
+	flag.StringVar(&config.OnNoChanges, "on-no-changes", syncer.NoChangesExitCode, "Exit code of a push or sync without changes: 'exit-code' exits with 2, 'exit0' with 0")
+	flag.BoolVar(&config.NoModeChanges, "no-mode-changes", false, "Ignore executable-bit changes and add new files as non-executable (push mode only)")
+	flag.BoolVar(&config.Force, "force", false, "Overwrite divergent remote history using --force-with-lease (push mode only)")
+	flag.BoolVar(&config.ForceUnsafe, "force-unsafe", false, "Overwrite remote history unconditionally using --force (push mode only)")
+	flag.StringVar(&config.MirrorRemote, "mirror-remote", "", "Also push every pushed branch and tag to this backup repository (push mode only)")
+	flag.BoolVar(&config.MirrorRequired, "mirror-required", false, "Fail the run if the push to -mirror-remote fails, instead of logging a warning")
+	flag.StringVar(&config.PreHook, "pre-hook", "", "Shell command run in the folder before pushing; a failure aborts the push (push mode only)")
+	flag.StringVar(&config.PostHook, "post-hook", "", "Shell command run in the folder after a successful pull (pull mode only)")
+	flag.BoolVar(&config.KeepTemp, "keep-temp", false, "Keep the temporary clone after the run and log its path, for debugging")
+	flag.StringVar(&config.WorkDir, "work-dir", "", "Persistent checkout reused across runs instead of a fresh clone (push and pull modes)")
+	flag.StringVar(&config.Strategy, "strategy", syncer.StrategyCopy, "How pull writes files: 'copy' file by file, or 'archive' via git archive (pull mode only)")
+	flag.BoolVar(&config.RecurseSubmodules, "recurse-submodules", false, "Check out submodules so that their files are pulled too (pull mode only)")
+	flag.BoolVar(&config.PreserveOwner, "preserve-owner", false, "Give pulled files the owner and group of the repository's files; requires root (pull mode only)")
+	flag.BoolVar(&config.PreserveXattrs, "preserve-xattrs", false, "Copy extended attributes of pulled files, Linux only (pull mode only)")
+	flag.BoolVar(&config.Clean, "clean", false, "Remove files from the folder that are not in the repository (pull mode only)")
+	flag.BoolVar(&config.Strict, "strict", false, "Fail instead of skipping FIFOs, sockets and devices in the folder")
+	flag.BoolVar(&config.ForceOverwrite, "force-overwrite", false, "Replace read-only files in the folder, keeping them read-only, instead of failing (pull and sync modes)")
+	flag.BoolVar(&config.SkipUnchanged, "skip-unchanged", true, "Leave files whose content already matches the repository untouched (pull mode only)")
+	flag.BoolVar(&config.ScanConflictMarkers, "scan-conflict-markers", false, "Fail the pull if pulled text files contain merge conflict markers (pull mode only)")
+	flag.StringVar(&config.ArchiveOut, "archive-out", "", "Also write the pulled files to this path as a zstd-compressed tar, e.g. snapshot.tar.zst (pull mode only)")
+	flag.BoolVar(&config.RenderTemplates, "render-templates", false, "Render pulled files ending in -template-suffix as Go templates with the environment variables as data (pull mode only)")
+	flag.StringVar(&config.TemplateSuffix, "template-suffix", "", "Suffix of the files rendered by -render-templates, removed from the output file name (default: .tmpl)")
+	flag.BoolVar(&config.HashCache, "hash-cache", false, "Cache the hashes of destination files so unchanged files are not rehashed on the next pull (pull mode only)")
+	flag.StringVar(&config.ChecksumAlgo, "checksum-algo", "", "Hash used to compare files and recorded in plans and sync manifests: sha256, sha512, sha1 or blake2b (default: sha256)")
+	flag.BoolVar(&config.Sign, "sign", false, "GPG-sign the sync commit (push mode only)")
+	flag.StringVar(&config.SigningKey, "signing-key", "", "GPG key ID used with -sign (optional)")
+	flag.BoolVar(&config.Signoff, "signoff", false, "Add a Signed-off-by trailer with the committer identity to the sync commits (push and sync modes)")
+	flag.StringVar(&config.Tag, "tag", "", "Create and push an annotated tag on the pushed commit (push mode only)")
+	flag.StringVar(&config.TagTemplate, "tag-template", "", "Tag name template using {{.Date}} and {{.Commit}}, e.g. sync-{{.Date}} (push mode only)")
+	flag.BoolVar(&config.ForceTag, "force-tag", false, "Replace the tag if it already exists")
+	flag.StringVar(&config.MetricsFile, "metrics-file", "", "Write Prometheus metrics about the run to this file, e.g. for the node_exporter textfile collector")
+	flag.StringVar(&config.LockDir, "lock-dir", "", "Directory for the lock files that prevent concurrent runs on the same folder (default: system temp directory)")
+	flag.DurationVar(&config.Interval, "interval", 0, "Pull repeatedly, waiting this long between pulls, e.g. 5m, until interrupted (pull mode only)")
+	flag.DurationVar(&config.LockTimeout, "lock-timeout", 0, "How long to wait for another run on the same folder to finish, e.g. 30s (default: fail immediately)")
+	flag.BoolVar(&logOpts.Verbose, "verbose", false, "Enable debug logging, including the git commands being run and every file copied")
+	flag.BoolVar(&logOpts.Quiet, "quiet", false, "Only log errors and write nothing to stdout")
+	flag.StringVar(&logOpts.Format, "log-format", logFormatJSON, "Log format: 'json' or 'text'")
+	flag.StringVar(&logOpts.File, "log-file", "file-syncer.log", "Path of the rotated log file; empty or 'stdout' disables file logging")
+	flag.IntVar(&logOpts.MaxSize, "log-max-size", 10, "Maximum log file size in megabytes before rotation")
+	flag.IntVar(&logOpts.MaxBackups, "log-max-backups", 3, "Number of rotated log files to keep")
+	flag.IntVar(&logOpts.MaxAge, "log-max-age", 28, "Number of days to keep rotated log files")
+	flag.BoolVar(&logOpts.Compress, "log-compress", true, "Compress rotated log files")
 
-func copyFile(src, dst string, mode os.FileMode) error {
-	// Open source file
-	srcFile, err := os.Open(src)
-	if err != nil {
-		return err
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExamples:\n")
+		fmt.Fprintf(os.Stderr, "  Push files to repository:\n")
+		fmt.Fprintf(os.Stderr, "    %s -mode push -folder ./myfiles -repo https://github.com/user/repo.git\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  Pull files from repository:\n")
+		fmt.Fprintf(os.Stderr, "    %s -mode pull -folder ./myfiles -repo https://github.com/user/repo.git\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  Use custom SSH key:\n")
+		fmt.Fprintf(os.Stderr, "    %s -mode push -folder ./myfiles -repo git@github.com:user/repo.git -ssh-key ~/.ssh/id_rsa\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  Push to a self-hosted GitLab:\n")
+		fmt.Fprintf(os.Stderr, "    %s -mode push -folder ./myfiles -repo git@gitlab.example.com:group/repo.git\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  Push only Markdown and PNG files:\n")
+		fmt.Fprintf(os.Stderr, "    %s -mode push -folder ./myfiles -repo https://github.com/user/repo.git -include '*.md' -include '*.png'\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Exit codes:\n")
+		fmt.Fprintf(os.Stderr, "  0  success (changes pushed, or pull completed)\n")
+		fmt.Fprintf(os.Stderr, "  1  other failure\n")
+		fmt.Fprintf(os.Stderr, "  2  push or sync succeeded but there were no changes (0 with -on-no-changes exit0)\n")
+		fmt.Fprintf(os.Stderr, "  3  validation error (invalid options, missing folder, schema violation, oversized file, path too long)\n")
+		fmt.Fprintf(os.Stderr, "  4  git or network error\n")
+		fmt.Fprintf(os.Stderr, "  5  conflict (push rejected, plan drift, existing tag, sync conflict, folder locked by another run)\n")
 	}
-	defer srcFile.Close()
 
-	// Create destination file
-	dstFile, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
-	if err != nil {
-		return err
+	flag.Parse()
+
+	// -ref replaces the default branch, but an explicit -branch conflicts with it
+	if config.Ref != "" && !flagPassed("branch") {
+		config.Branch = ""
 	}
-	defer dstFile.Close()
 
-	// Copy contents
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+	// Secrets are better passed through the environment than on the command line
+	if config.SSHKeyData == "" {
+		config.SSHKeyData = os.Getenv(sshKeyEnv)
+	}
+	return config, logOpts
 }
 
-// FileChangeStats holds statistics about file changes
-type FileChangeStats struct {
-	Added    []string
-	Modified []string
-	Deleted  []string
+// flagPassed reports whether the named flag was given on the command line.
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
 }
 
-// parseGitStatus parses git status --porcelain output and returns file change statistics
-func parseGitStatus(statusOutput string) FileChangeStats {
-	stats := FileChangeStats{
-		Added:    []string{},
-		Modified: []string{},
-		Deleted:  []string{},
-	}
-
-	// Don't trim the output before splitting to preserve leading spaces in status codes
-	lines := strings.Split(statusOutput, "\n")
-	for _, line := range lines {
-		// Skip empty lines
-		if len(line) < 3 {
-			continue
-		}
+// startRun gives the run a random ID and adds it to every log line, so that
+// runs interleaving in a shared log can be told apart.
+func startRun(config *syncer.Config) {
+	config.RunID = syncer.NewRunID()
+	logger = logger.With("run_id", config.RunID)
+}
 
-		// Git status --porcelain format: XY filename
-		// X = status in index, Y = status in working tree
-		// The filename starts at position 3 (after 2 status chars and 1 space)
-		statusCode := line[0:2]
-		filename := line[3:]
+func run(ctx context.Context, config syncer.Config) error {
+	logger.Info("File Syncer started",
+		"mode", config.Mode,
+		"folder", config.FolderPath,
+		"repository", syncer.SanitizeURL(config.RepoURL),
+		"branch", config.Branch)
 
-		switch {
-		case statusCode == "A " || statusCode == "??":
-			stats.Added = append(stats.Added, filename)
-		case statusCode == "M " || statusCode == " M" || statusCode == "MM":
-			stats.Modified = append(stats.Modified, filename)
-		case statusCode == "D " || statusCode == " D":
-			stats.Deleted = append(stats.Deleted, filename)
-		case statusCode[0] == 'R':
-			// Renamed files - treat as modified
-			// Format is "old -> new", extract just the new filename
-			if idx := strings.Index(filename, " -> "); idx != -1 {
-				filename = filename[idx+4:]
-			}
-			stats.Modified = append(stats.Modified, filename)
-		}
+	s, err := syncer.New(config, logger)
+	if err != nil {
+		return err
 	}
 
-	return stats
-}
-
-// generateCommitMessage creates a meaningful commit message based on file changes
-func generateCommitMessage(stats FileChangeStats) (string, string) {
-	totalChanges := len(stats.Added) + len(stats.Modified) + len(stats.Deleted)
-	
-	// Build commit subject
-	var subject strings.Builder
-	subject.WriteString("Sync ")
-	subject.WriteString(fmt.Sprintf("%d file", totalChanges))
-	if totalChanges != 1 {
-		subject.WriteString("s")
-	}
-	
-	parts := []string{}
-	if len(stats.Added) > 0 {
-		parts = append(parts, fmt.Sprintf("%d added", len(stats.Added)))
-	}
-	if len(stats.Modified) > 0 {
-		parts = append(parts, fmt.Sprintf("%d modified", len(stats.Modified)))
-	}
-	if len(stats.Deleted) > 0 {
-		parts = append(parts, fmt.Sprintf("%d deleted", len(stats.Deleted)))
-	}
-	
-	if len(parts) > 0 {
-		subject.WriteString(" (")
-		subject.WriteString(strings.Join(parts, ", "))
-		subject.WriteString(")")
+	if config.ValidateOnly {
+		return s.Check(ctx)
 	}
 
-	// Build commit body with file details
-	var body strings.Builder
-	firstSection := true
-	
-	if len(stats.Added) > 0 {
-		if !firstSection {
-			body.WriteString("\n")
+	switch config.Mode {
+	case syncer.ModePush:
+		result, err := s.Push(ctx)
+		// Print the new branch for CI, e.g. to open a pull request from it
+		if err == nil && config.BranchTemplate != "" && result.Pushed {
+			fmt.Println(result.Branch)
 		}
-		body.WriteString("Added files:\n")
-		for _, file := range stats.Added {
-			body.WriteString(fmt.Sprintf("  + %s\n", file))
-		}
-		firstSection = false
-	}
-	
-	if len(stats.Modified) > 0 {
-		if !firstSection {
-			body.WriteString("\n")
-		}
-		body.WriteString("Modified files:\n")
-		for _, file := range stats.Modified {
-			body.WriteString(fmt.Sprintf("  ~ %s\n", file))
-		}
-		firstSection = false
-	}
-	
-	if len(stats.Deleted) > 0 {
-		if !firstSection {
-			body.WriteString("\n")
+		if err == nil && config.ListChanges {
+			return printChanges(os.Stdout, result.Stats)
 		}
-		body.WriteString("Deleted files:\n")
-		for _, file := range stats.Deleted {
-			body.WriteString(fmt.Sprintf("  - %s\n", file))
-		}
-		firstSection = false
-	}
-
-	return subject.String(), strings.TrimSpace(body.String())
-}
-
-func runCommand(dir string, sshKeyPath string, name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	cmd.Dir = dir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	// Ensure environment is inherited for git credentials
-	cmd.Env = os.Environ()
-	// Set GIT_SSH_COMMAND if SSH key is provided
-	if sshKeyPath != "" {
-		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND="+buildGitSSHCommand(sshKeyPath))
+		return err
+	case syncer.ModeSync:
+		_, err := s.Sync(ctx)
+		return err
 	}
-	return cmd.Run()
-}
-
-func runCommandOutput(dir string, sshKeyPath string, name string, args ...string) (string, error) {
-	cmd := exec.Command(name, args...)
-	cmd.Dir = dir
-	// Ensure environment is inherited for git credentials
-	cmd.Env = os.Environ()
-	// Set GIT_SSH_COMMAND if SSH key is provided
-	if sshKeyPath != "" {
-		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND="+buildGitSSHCommand(sshKeyPath))
+	if config.Interval > 0 {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		// Restore the default handling once a stop was requested, so that a
+		// second signal kills a pull that hangs
+		context.AfterFunc(ctx, stop)
+		return s.PullLoop(ctx)
 	}
-	output, err := cmd.CombinedOutput()
-	return string(output), err
+	return s.Pull(ctx)
 }
@@ -1,524 +1,279 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
-)
-
-func TestValidateConfig(t *testing.T) {
-	tests := []struct {
-		name    string
-		config  Config
-		wantErr bool
-	}{
-		{
-			name: "valid push config",
-			config: Config{
-				Mode:       ModePush,
-				FolderPath: "/tmp/test",
-				RepoURL:    "https://github.com/user/repo.git",
-				Branch:     "main",
-			},
-			wantErr: false,
-		},
-		{
-			name: "valid pull config",
-			config: Config{
-				Mode:       ModePull,
-				FolderPath: "/tmp/test",
-				RepoURL:    "https://github.com/user/repo.git",
-				Branch:     "main",
-			},
-			wantErr: false,
-		},
-		{
-			name: "invalid mode",
-			config: Config{
-				Mode:       "invalid",
-				FolderPath: "/tmp/test",
-				RepoURL:    "https://github.com/user/repo.git",
-			},
-			wantErr: true,
-		},
-		{
-			name: "missing folder path",
-			config: Config{
-				Mode:    ModePush,
-				RepoURL: "https://github.com/user/repo.git",
-			},
-			wantErr: true,
-		},
-		{
-			name: "missing repo URL",
-			config: Config{
-				Mode:       ModePush,
-				FolderPath: "/tmp/test",
-			},
-			wantErr: true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := validateConfig(tt.config)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
-func TestSyncFiles(t *testing.T) {
-	// Create source directory with test files
-	srcDir, err := os.MkdirTemp("", "sync-src-*")
-	if err != nil {
-		t.Fatalf("failed to create temp source dir: %v", err)
-	}
-	defer os.RemoveAll(srcDir)
-
-	// Create destination directory
-	dstDir, err := os.MkdirTemp("", "sync-dst-*")
-	if err != nil {
-		t.Fatalf("failed to create temp destination dir: %v", err)
-	}
-	defer os.RemoveAll(dstDir)
-
-	// Create test files in source
-	testFile1 := filepath.Join(srcDir, "test1.txt")
-	if err := os.WriteFile(testFile1, []byte("test content 1"), 0644); err != nil {
-		t.Fatalf("failed to create test file 1: %v", err)
-	}
-
-	testDir := filepath.Join(srcDir, "subdir")
-	if err := os.Mkdir(testDir, 0755); err != nil {
-		t.Fatalf("failed to create test directory: %v", err)
-	}
-
-	testFile2 := filepath.Join(testDir, "test2.txt")
-	if err := os.WriteFile(testFile2, []byte("test content 2"), 0644); err != nil {
-		t.Fatalf("failed to create test file 2: %v", err)
-	}
-
-	// Sync files
-	if err := syncFiles(srcDir, dstDir); err != nil {
-		t.Fatalf("syncFiles() failed: %v", err)
-	}
 
-	// Verify files were synced
-	dstFile1 := filepath.Join(dstDir, "test1.txt")
-	content1, err := os.ReadFile(dstFile1)
-	if err != nil {
-		t.Errorf("failed to read destination file 1: %v", err)
-	}
-	if string(content1) != "test content 1" {
-		t.Errorf("file 1 content mismatch: got %q, want %q", string(content1), "test content 1")
-	}
-
-	dstFile2 := filepath.Join(dstDir, "subdir", "test2.txt")
-	content2, err := os.ReadFile(dstFile2)
-	if err != nil {
-		t.Errorf("failed to read destination file 2: %v", err)
-	}
-	if string(content2) != "test content 2" {
-		t.Errorf("file 2 content mismatch: got %q, want %q", string(content2), "test content 2")
-	}
-}
-
-func TestSyncFilesSkipsGitDirectory(t *testing.T) {
-	// Create source directory with .git directory
-	srcDir, err := os.MkdirTemp("", "sync-src-git-*")
-	if err != nil {
-		t.Fatalf("failed to create temp source dir: %v", err)
-	}
-	defer os.RemoveAll(srcDir)
-
-	// Create destination directory
-	dstDir, err := os.MkdirTemp("", "sync-dst-git-*")
-	if err != nil {
-		t.Fatalf("failed to create temp destination dir: %v", err)
-	}
-	defer os.RemoveAll(dstDir)
-
-	// Create .git directory in source
-	gitDir := filepath.Join(srcDir, ".git")
-	if err := os.Mkdir(gitDir, 0755); err != nil {
-		t.Fatalf("failed to create .git directory: %v", err)
-	}
+	"github.com/rikkicom/file-syncer/syncer"
+)
 
-	gitFile := filepath.Join(gitDir, "config")
-	if err := os.WriteFile(gitFile, []byte("git config"), 0644); err != nil {
-		t.Fatalf("failed to create git config file: %v", err)
-	}
+func TestPatternListCollectsRepeatedFlags(t *testing.T) {
+	var patterns []string
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var((*patternList)(&patterns), "include", "")
 
-	// Create regular file
-	testFile := filepath.Join(srcDir, "test.txt")
-	if err := os.WriteFile(testFile, []byte("test content"), 0644); err != nil {
-		t.Fatalf("failed to create test file: %v", err)
+	if err := fs.Parse([]string{"-include", "*.md", "-include", "*.png"}); err != nil {
+		t.Fatalf("Parse() failed: %v", err)
 	}
 
-	// Sync files
-	if err := syncFiles(srcDir, dstDir); err != nil {
-		t.Fatalf("syncFiles() failed: %v", err)
+	if len(patterns) != 2 || patterns[0] != "*.md" || patterns[1] != "*.png" {
+		t.Errorf("patterns = %v, want [*.md *.png]", patterns)
 	}
-
-	// Verify .git directory was not synced
-	dstGitDir := filepath.Join(dstDir, ".git")
-	if _, err := os.Stat(dstGitDir); !os.IsNotExist(err) {
-		t.Errorf(".git directory should not be synced, but it exists")
-	}
-
-	// Verify regular file was synced
-	dstFile := filepath.Join(dstDir, "test.txt")
-	if _, err := os.Stat(dstFile); err != nil {
-		t.Errorf("regular file should be synced: %v", err)
+	if got := (*patternList)(&patterns).String(); got != "*.md,*.png" {
+		t.Errorf("String() = %q, want %q", got, "*.md,*.png")
 	}
 }
 
-func TestCopyFile(t *testing.T) {
-	// Create temporary directory
-	tempDir, err := os.MkdirTemp("", "copy-test-*")
-	if err != nil {
-		t.Fatalf("failed to create temp dir: %v", err)
-	}
-	defer os.RemoveAll(tempDir)
+func TestBranchListReplacesDefault(t *testing.T) {
+	branch, extra := "main", []string(nil)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&branchList{branch: &branch, extra: &extra}, "branch", "")
 
-	// Create source file
-	srcFile := filepath.Join(tempDir, "source.txt")
-	testContent := "test file content"
-	if err := os.WriteFile(srcFile, []byte(testContent), 0644); err != nil {
-		t.Fatalf("failed to create source file: %v", err)
+	if err := fs.Parse([]string{"-branch", "develop", "-branch", "stable", "-branch", "release"}); err != nil {
+		t.Fatalf("Parse() failed: %v", err)
 	}
 
-	// Copy file
-	dstFile := filepath.Join(tempDir, "destination.txt")
-	if err := copyFile(srcFile, dstFile, 0644); err != nil {
-		t.Fatalf("copyFile() failed: %v", err)
-	}
-
-	// Verify destination file
-	content, err := os.ReadFile(dstFile)
-	if err != nil {
-		t.Fatalf("failed to read destination file: %v", err)
+	if branch != "develop" {
+		t.Errorf("branch = %q, want develop", branch)
 	}
-
-	if string(content) != testContent {
-		t.Errorf("content mismatch: got %q, want %q", string(content), testContent)
+	if len(extra) != 2 || extra[0] != "stable" || extra[1] != "release" {
+		t.Errorf("extra branches = %v, want [stable release]", extra)
 	}
 }
 
-func TestValidateConfigWithSSHKey(t *testing.T) {
+func TestExitCode(t *testing.T) {
 	tests := []struct {
-		name    string
-		config  Config
-		wantErr bool
+		name string
+		err  error
+		want int
 	}{
-		{
-			name: "valid config with SSH key",
-			config: Config{
-				Mode:       ModePush,
-				FolderPath: "/tmp/test",
-				RepoURL:    "git@github.com:user/repo.git",
-				Branch:     "main",
-				SSHKeyPath: "/home/user/.ssh/id_rsa",
-			},
-			wantErr: false,
-		},
-		{
-			name: "valid config without SSH key",
-			config: Config{
-				Mode:       ModePush,
-				FolderPath: "/tmp/test",
-				RepoURL:    "https://github.com/user/repo.git",
-				Branch:     "main",
-				SSHKeyPath: "",
-			},
-			wantErr: false,
-		},
+		{name: "success", err: nil, want: exitOK},
+		{name: "no changes", err: syncer.ErrNoChanges, want: exitNoChanges},
+		{name: "missing folder", err: fmt.Errorf("%w: /data", syncer.ErrFolderMissing), want: exitValidation},
+		{name: "oversized file", err: fmt.Errorf("%w: dump.bin", syncer.ErrFileTooLarge), want: exitValidation},
+		{name: "schema violation", err: fmt.Errorf("%w: missing README.md", syncer.ErrSchemaViolation), want: exitValidation},
+		{name: "path too long", err: fmt.Errorf("%w: deep/file.txt", syncer.ErrPathTooLong), want: exitValidation},
+		{name: "invalid file list", err: fmt.Errorf("%w: ../secret is outside the folder", syncer.ErrInvalidFileList), want: exitValidation},
+		{name: "conflict markers", err: fmt.Errorf("%w: config.yml", syncer.ErrConflictMarkers), want: exitValidation},
+		{name: "LFS pointer", err: fmt.Errorf("%w: assets/logo.png", syncer.ErrLFSPointer), want: exitValidation},
+		{name: "special file", err: fmt.Errorf("%w: pipe is a FIFO", syncer.ErrSpecialFile), want: exitValidation},
+		{name: "too many changes", err: fmt.Errorf("%w: 500 files changed", syncer.ErrTooManyChanges), want: exitValidation},
+		{name: "case collision", err: fmt.Errorf("%w: File.txt and file.txt", syncer.ErrCaseCollision), want: exitValidation},
+		{name: "clone failed", err: fmt.Errorf("%w: exit status 128", syncer.ErrCloneFailed), want: exitGit},
+		{name: "push failed", err: fmt.Errorf("%w: exit status 128", syncer.ErrPushFailed), want: exitGit},
+		{name: "protected branch", err: fmt.Errorf("%w: exit status 1", syncer.ErrBranchProtected), want: exitGit},
+		{name: "push rejected", err: fmt.Errorf("%w: exit status 1", syncer.ErrPushRejected), want: exitConflict},
+		{name: "plan drift", err: fmt.Errorf("%w: a.txt", syncer.ErrPlanDrift), want: exitConflict},
+		{name: "sync conflict", err: fmt.Errorf("%w: notes.txt", syncer.ErrSyncConflict), want: exitConflict},
+		{name: "folder locked", err: fmt.Errorf("%w: /data", syncer.ErrLocked), want: exitConflict},
+		{name: "other failure", err: errors.New("boom"), want: exitFailure},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateConfig(tt.config)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
 			}
 		})
 	}
 }
 
-func TestEscapeShellArg(t *testing.T) {
+func TestOnNoChanges(t *testing.T) {
+	failure := fmt.Errorf("%w: exit status 128", syncer.ErrPushFailed)
 	tests := []struct {
-		name  string
-		input string
-		want  string
+		name   string
+		err    error
+		action string
+		want   int
 	}{
-		{
-			name:  "simple path",
-			input: "/home/user/.ssh/id_rsa",
-			want:  "/home/user/.ssh/id_rsa",
-		},
-		{
-			name:  "path with spaces",
-			input: "/home/user/my files/.ssh/id_rsa",
-			want:  "/home/user/my\\ files/.ssh/id_rsa",
-		},
-		{
-			name:  "path with single quote",
-			input: "/home/user's/.ssh/id_rsa",
-			want:  "/home/user\\'s/.ssh/id_rsa",
-		},
-		{
-			name:  "path with special chars",
-			input: "/home/user/.ssh/key$file",
-			want:  "/home/user/.ssh/key\\$file",
-		},
-		{
-			name:  "path with multiple special chars",
-			input: "/home/user name/.ssh/key file (1).pem",
-			want:  "/home/user\\ name/.ssh/key\\ file\\ \\(1\\).pem",
-		},
+		{name: "default", err: syncer.ErrNoChanges, want: exitNoChanges},
+		{name: "exit-code", err: syncer.ErrNoChanges, action: syncer.NoChangesExitCode, want: exitNoChanges},
+		{name: "exit0", err: syncer.ErrNoChanges, action: syncer.NoChangesExit0, want: exitOK},
+		{name: "exit0 with changes", err: nil, action: syncer.NoChangesExit0, want: exitOK},
+		{name: "exit0 keeps failures", err: failure, action: syncer.NoChangesExit0, want: exitGit},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := escapeShellArg(tt.input)
-			if got != tt.want {
-				t.Errorf("escapeShellArg() = %v, want %v", got, tt.want)
+			if got := exitCode(onNoChanges(tt.err, tt.action)); got != tt.want {
+				t.Errorf("exit code for %v with %q = %d, want %d", tt.err, tt.action, got, tt.want)
 			}
 		})
 	}
 }
 
-func TestBuildGitSSHCommand(t *testing.T) {
-	tests := []struct {
-		name       string
-		sshKeyPath string
-		want       string
-	}{
-		{
-			name:       "simple path",
-			sshKeyPath: "/home/user/.ssh/id_rsa",
-			want:       "ssh -i /home/user/.ssh/id_rsa -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new",
-		},
-		{
-			name:       "path with spaces",
-			sshKeyPath: "/home/user/my files/.ssh/id_rsa",
-			want:       "ssh -i /home/user/my\\ files/.ssh/id_rsa -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new",
-		},
-		{
-			name:       "path with special chars",
-			sshKeyPath: "/home/user's key/.ssh/deploy (prod).pem",
-			want:       "ssh -i /home/user\\'s\\ key/.ssh/deploy\\ \\(prod\\).pem -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new",
-		},
+func TestPrintChanges(t *testing.T) {
+	stats := syncer.FileChangeStats{
+		Added:    []string{"new.txt"},
+		Modified: []string{"notes.md"},
+		Deleted:  []string{},
+		Renamed:  [][2]string{{"a.txt", "docs/a.txt"}},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := buildGitSSHCommand(tt.sshKeyPath)
-			if got != tt.want {
-				t.Errorf("buildGitSSHCommand() = %v, want %v", got, tt.want)
-			}
-		})
+	var out bytes.Buffer
+	if err := printChanges(&out, stats); err != nil {
+		t.Fatalf("printChanges() error = %v", err)
+	}
+	want := `{"added":["new.txt"],"modified":["notes.md"],"deleted":[],"renamed":[["a.txt","docs/a.txt"]]}` + "\n"
+	if out.String() != want {
+		t.Errorf("printChanges() = %q, want %q", out.String(), want)
 	}
 }
 
-func TestParseGitStatus(t *testing.T) {
+// runGit runs git in dir, failing the test on error.
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+	}
+}
+
+// captureStdout runs f with os.Stdout redirected and returns what it wrote.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	saved := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- out
+	}()
+	defer func() {
+		os.Stdout = saved
+	}()
+	f()
+	w.Close()
+	return string(<-done)
+}
+
+func TestRunKeepsStdoutForResult(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	for _, env := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(env, "File Syncer Test")
+	}
+	for _, env := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(env, "test@example.com")
+	}
+	saved := logger
+	t.Cleanup(func() { logger = saved })
+
 	tests := []struct {
 		name   string
-		output string
-		want   FileChangeStats
+		config syncer.Config
+		check  func(t *testing.T, stdout string)
 	}{
 		{
-			name:   "single added file",
-			output: "A  newfile.txt",
-			want: FileChangeStats{
-				Added:    []string{"newfile.txt"},
-				Modified: []string{},
-				Deleted:  []string{},
-			},
-		},
-		{
-			name:   "single modified file",
-			output: "M  modified.txt",
-			want: FileChangeStats{
-				Added:    []string{},
-				Modified: []string{"modified.txt"},
-				Deleted:  []string{},
-			},
-		},
-		{
-			name:   "single deleted file",
-			output: "D  deleted.txt",
-			want: FileChangeStats{
-				Added:    []string{},
-				Modified: []string{},
-				Deleted:  []string{"deleted.txt"},
-			},
-		},
-		{
-			name:   "mixed changes",
-			output: "A  added.txt\nM  modified.txt\nD  deleted.txt",
-			want: FileChangeStats{
-				Added:    []string{"added.txt"},
-				Modified: []string{"modified.txt"},
-				Deleted:  []string{"deleted.txt"},
-			},
-		},
-		{
-			name:   "untracked file",
-			output: "?? untracked.txt",
-			want: FileChangeStats{
-				Added:    []string{"untracked.txt"},
-				Modified: []string{},
-				Deleted:  []string{},
-			},
-		},
-		{
-			name:   "multiple files of same type",
-			output: "A  file1.txt\nA  file2.txt\nM  file3.txt",
-			want: FileChangeStats{
-				Added:    []string{"file1.txt", "file2.txt"},
-				Modified: []string{"file3.txt"},
-				Deleted:  []string{},
-			},
-		},
-		{
-			name:   "modified with space prefix",
-			output: " M modified.txt",
-			want: FileChangeStats{
-				Added:    []string{},
-				Modified: []string{"modified.txt"},
-				Deleted:  []string{},
+			name:   "list changes",
+			config: syncer.Config{ListChanges: true},
+			check: func(t *testing.T, stdout string) {
+				dec := json.NewDecoder(strings.NewReader(stdout))
+				var stats syncer.FileChangeStats
+				if err := dec.Decode(&stats); err != nil {
+					t.Fatalf("stdout is not a JSON object: %v\n%s", err, stdout)
+				}
+				if dec.More() {
+					t.Fatalf("stdout holds more than one JSON object:\n%s", stdout)
+				}
+				if len(stats.Modified) != 1 || stats.Modified[0] != "file.txt" {
+					t.Errorf("modified = %v, want [file.txt]", stats.Modified)
+				}
 			},
 		},
 		{
-			name:   "renamed file",
-			output: "R  old-name.txt -> new-name.txt",
-			want: FileChangeStats{
-				Added:    []string{},
-				Modified: []string{"new-name.txt"},
-				Deleted:  []string{},
+			name:   "branch template",
+			config: syncer.Config{BranchTemplate: "sync/{{.RunID}}"},
+			check: func(t *testing.T, stdout string) {
+				if !strings.HasPrefix(stdout, "sync/") || strings.Count(stdout, "\n") != 1 {
+					t.Errorf("stdout = %q, want only the pushed branch name", stdout)
+				}
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := parseGitStatus(tt.output)
-			if len(got.Added) != len(tt.want.Added) {
-				t.Errorf("parseGitStatus() added count = %v, want %v", len(got.Added), len(tt.want.Added))
-			}
-			if len(got.Modified) != len(tt.want.Modified) {
-				t.Errorf("parseGitStatus() modified count = %v, want %v", len(got.Modified), len(tt.want.Modified))
-			}
-			if len(got.Deleted) != len(tt.want.Deleted) {
-				t.Errorf("parseGitStatus() deleted count = %v, want %v", len(got.Deleted), len(tt.want.Deleted))
+			remote := filepath.Join(t.TempDir(), "remote.git")
+			runGit(t, t.TempDir(), "init", "--bare", "-b", "main", remote)
+			seed := t.TempDir()
+			runGit(t, seed, "init", "-b", "main")
+			if err := os.WriteFile(filepath.Join(seed, "file.txt"), []byte("old"), 0644); err != nil {
+				t.Fatalf("failed to write seed file: %v", err)
 			}
-			
-			// Check individual files
-			for i, file := range tt.want.Added {
-				if i >= len(got.Added) {
-					t.Errorf("parseGitStatus() added[%d] missing, want %q", i, file)
-					break
-				}
-				if got.Added[i] != file {
-					t.Errorf("parseGitStatus() added[%d] = %q, want %q", i, got.Added[i], file)
-					break
-				}
-			}
-			for i, file := range tt.want.Modified {
-				if i >= len(got.Modified) {
-					t.Errorf("parseGitStatus() modified[%d] missing, want %q", i, file)
-					break
-				}
-				if got.Modified[i] != file {
-					t.Errorf("parseGitStatus() modified[%d] = %q, want %q", i, got.Modified[i], file)
-					break
-				}
+			runGit(t, seed, "add", "-A")
+			runGit(t, seed, "commit", "-m", "seed")
+			runGit(t, seed, "push", remote, "main")
+
+			folder := t.TempDir()
+			if err := os.WriteFile(filepath.Join(folder, "file.txt"), []byte("new"), 0644); err != nil {
+				t.Fatalf("failed to write source file: %v", err)
 			}
-			for i, file := range tt.want.Deleted {
-				if i >= len(got.Deleted) {
-					t.Errorf("parseGitStatus() deleted[%d] missing, want %q", i, file)
-					break
-				}
-				if got.Deleted[i] != file {
-					t.Errorf("parseGitStatus() deleted[%d] = %q, want %q", i, got.Deleted[i], file)
-					break
+			config := tt.config
+			config.Mode, config.FolderPath, config.RepoURL, config.Branch = syncer.ModePush, folder, remote, "main"
+
+			stdout := captureStdout(t, func() {
+				// As in main
+				initLogger(logOptions{Format: logFormatJSON, Stderr: config.PrintsResult()})
+				startRun(&config)
+				if err := run(context.Background(), config); err != nil {
+					t.Errorf("run() failed: %v", err)
 				}
-			}
+			})
+			tt.check(t, stdout)
 		})
 	}
 }
 
-func TestGenerateCommitMessage(t *testing.T) {
-	tests := []struct {
-		name        string
-		stats       FileChangeStats
-		wantSubject string
-		wantBody    string
-	}{
-		{
-			name: "single added file",
-			stats: FileChangeStats{
-				Added:    []string{"file.txt"},
-				Modified: []string{},
-				Deleted:  []string{},
-			},
-			wantSubject: "Sync 1 file (1 added)",
-			wantBody:    "Added files:\n  + file.txt",
-		},
-		{
-			name: "single modified file",
-			stats: FileChangeStats{
-				Added:    []string{},
-				Modified: []string{"file.txt"},
-				Deleted:  []string{},
-			},
-			wantSubject: "Sync 1 file (1 modified)",
-			wantBody:    "Modified files:\n  ~ file.txt",
-		},
-		{
-			name: "single deleted file",
-			stats: FileChangeStats{
-				Added:    []string{},
-				Modified: []string{},
-				Deleted:  []string{"file.txt"},
-			},
-			wantSubject: "Sync 1 file (1 deleted)",
-			wantBody:    "Deleted files:\n  - file.txt",
-		},
-		{
-			name: "multiple files mixed",
-			stats: FileChangeStats{
-				Added:    []string{"new1.txt", "new2.txt"},
-				Modified: []string{"mod.txt"},
-				Deleted:  []string{"old.txt"},
-			},
-			wantSubject: "Sync 4 files (2 added, 1 modified, 1 deleted)",
-			wantBody:    "Added files:\n  + new1.txt\n  + new2.txt\n\nModified files:\n  ~ mod.txt\n\nDeleted files:\n  - old.txt",
-		},
-		{
-			name: "only modified files",
-			stats: FileChangeStats{
-				Added:    []string{},
-				Modified: []string{"file1.txt", "file2.txt"},
-				Deleted:  []string{},
-			},
-			wantSubject: "Sync 2 files (2 modified)",
-			wantBody:    "Modified files:\n  ~ file1.txt\n  ~ file2.txt",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			gotSubject, gotBody := generateCommitMessage(tt.stats)
-			if gotSubject != tt.wantSubject {
-				t.Errorf("generateCommitMessage() subject = %v, want %v", gotSubject, tt.wantSubject)
-			}
-			if gotBody != tt.wantBody {
-				t.Errorf("generateCommitMessage() body = %v, want %v", gotBody, tt.wantBody)
-			}
-		})
+func TestRunLogsShareRunID(t *testing.T) {
+	var logs bytes.Buffer
+	saved := logger
+	logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	t.Cleanup(func() { logger = saved })
+
+	// The clone fails, but the run logs several lines before it does
+	config := syncer.Config{Mode: syncer.ModePull, FolderPath: t.TempDir(), RepoURL: filepath.Join(t.TempDir(), "missing.git"), Branch: "main"}
+	startRun(&config)
+	if err := run(context.Background(), config); err == nil {
+		t.Fatal("run() should fail for a missing repository")
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("expected several log lines, got:\n%s", logs.String())
+	}
+	for _, line := range lines {
+		var entry struct {
+			RunID string `json:"run_id"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("malformed log line %q: %v", line, err)
+		}
+		if entry.RunID == "" || entry.RunID != config.RunID {
+			t.Errorf("log line has run_id %q, want %q: %s", entry.RunID, config.RunID, line)
+		}
+	}
+
+	other := syncer.Config{}
+	startRun(&other)
+	if other.RunID == config.RunID {
+		t.Errorf("two runs share run ID %q", config.RunID)
 	}
 }
@@ -0,0 +1,56 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// shouldAmend reports whether a branch tip with parents parents, authored
+// by tipEmail, may be replaced by the next sync commit of an author with
+// email (-amend). Merge commits are never amended, and neither is anything
+// when the author's email is unknown.
+func shouldAmend(parents int, tipEmail, email string) bool {
+	return parents <= 1 && email != "" && strings.EqualFold(tipEmail, email)
+}
+
+// parseIdentEmail returns the email of a git identity such as the output of
+// git var GIT_AUTHOR_IDENT, "Name <email> 1700000000 +0000".
+func parseIdentEmail(ident string) string {
+	_, rest, ok := strings.Cut(ident, "<")
+	if !ok {
+		return ""
+	}
+	email, _, ok := strings.Cut(rest, ">")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(email)
+}
+
+// amendLease decides whether the sync commit in dir amends the branch tip
+// instead of being added on top of it (-amend). It returns the remote
+// branch's commit the push must lease against, or an empty string to make a
+// new commit, which is the case for branches the remote does not have yet.
+func (s *Syncer) amendLease(ctx context.Context, dir string) (string, error) {
+	lease, err := s.runCommandOutput(ctx, dir, "git", "rev-parse", "-q", "--verify", "refs/remotes/origin/"+s.config.Branch)
+	if err != nil {
+		return "", nil
+	}
+	tip, err := s.runCommandOutput(ctx, dir, "git", "log", "-1", "--format=%P%x00%ae", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the branch tip: %w", err)
+	}
+	ident, err := s.runCommandOutput(ctx, dir, "git", "var", "GIT_AUTHOR_IDENT")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the commit author: %w", err)
+	}
+
+	parents, tipEmail, _ := strings.Cut(strings.TrimSpace(tip), "\x00")
+	email := parseIdentEmail(ident)
+	if !shouldAmend(len(strings.Fields(parents)), tipEmail, email) {
+		s.logger.Info("Branch tip was not made by this author, creating a new commit", "tip_author", tipEmail, "author", email)
+		return "", nil
+	}
+	return strings.TrimSpace(lease), nil
+}
@@ -0,0 +1,48 @@
+package syncer
+
+import "testing"
+
+func TestShouldAmend(t *testing.T) {
+	const email = "sync-bot@example.com"
+
+	tests := []struct {
+		name     string
+		parents  int
+		tipEmail string
+		email    string
+		want     bool
+	}{
+		{name: "own commit", parents: 1, tipEmail: email, email: email, want: true},
+		{name: "own root commit", parents: 0, tipEmail: email, email: email, want: true},
+		{name: "email case differs", parents: 1, tipEmail: "Sync-Bot@Example.com", email: email, want: true},
+		{name: "other author", parents: 1, tipEmail: "jane@example.com", email: email},
+		{name: "own merge commit", parents: 2, tipEmail: email, email: email},
+		{name: "unknown email", parents: 1, tipEmail: "", email: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldAmend(tt.parents, tt.tipEmail, tt.email); got != tt.want {
+				t.Errorf("shouldAmend(%d, %q, %q) = %v, want %v", tt.parents, tt.tipEmail, tt.email, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIdentEmail(t *testing.T) {
+	tests := []struct {
+		ident string
+		want  string
+	}{
+		{ident: "file-syncer <sync-bot@example.com> 1700000000 +0000\n", want: "sync-bot@example.com"},
+		{ident: "<> 1700000000 +0000", want: ""},
+		{ident: "no email", want: ""},
+		{ident: "broken <email", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := parseIdentEmail(tt.ident); got != tt.want {
+			t.Errorf("parseIdentEmail(%q) = %q, want %q", tt.ident, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package syncer
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// extractArchive writes the tree at HEAD of the clone at repoDir into dstDir
+// by streaming `git archive` through a tar reader (pull with -strategy archive).
+func (s *Syncer) extractArchive(ctx context.Context, repoDir, dstDir string, opts syncOptions) (syncCounts, error) {
+	var stderr bytes.Buffer
+	cmd := s.command(ctx, repoDir, "git", "archive", "--format=tar", "HEAD")
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return syncCounts{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return syncCounts{}, fmt.Errorf("failed to run git archive: %w", err)
+	}
+
+	counts, err := extractTar(stdout, dstDir, opts)
+	if err != nil {
+		// Drain the pipe so that git can exit
+		io.Copy(io.Discard, stdout)
+	}
+	if waitErr := cmd.Wait(); waitErr != nil && err == nil {
+		err = fmt.Errorf("git archive failed: %w: %s", waitErr, redact(strings.TrimSpace(stderr.String())))
+	}
+	return counts, err
+}
+
+// extractTar extracts the tar stream r into dstDir, applying the same
+// exclude, include and .gitkeep rules as syncFiles.
+func extractTar(r io.Reader, dstDir string, opts syncOptions) (syncCounts, error) {
+	var counts syncCounts
+	var skippedDirs []string
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return counts, nil
+		}
+		if err != nil {
+			return counts, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		name := path.Clean(strings.TrimSuffix(hdr.Name, "/"))
+		if name == "." {
+			continue
+		}
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return counts, fmt.Errorf("archive entry %q escapes the destination", hdr.Name)
+		}
+		if underAny(name, skippedDirs) {
+			continue
+		}
+		relPath := filepath.FromSlash(name)
+		dstPath := filepath.Join(dstDir, relPath)
+		isDir := hdr.Typeflag == tar.TypeDir
+
+		if opts.skipped(relPath, isDir) {
+			if isDir {
+				skippedDirs = append(skippedDirs, name)
+			}
+			continue
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			// With include or negated exclude patterns, directories are
+			// created on demand
+			if opts.dirsOnDemand() {
+				continue
+			}
+			if err := opts.Staging.mkdirAll(dstPath, hdr.FileInfo().Mode().Perm()); err != nil {
+				return counts, err
+			}
+
+		case tar.TypeReg:
+			if opts.StripGitKeep && path.Base(name) == gitKeepFile {
+				if err := removeGitKeep(dstPath, opts.Staging); err != nil {
+					return counts, err
+				}
+				continue
+			}
+			if !opts.included(relPath) {
+				continue
+			}
+			if err := opts.Staging.mkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+				return counts, err
+			}
+			if _, err := os.Lstat(dstPath); os.IsNotExist(err) {
+				counts.Created++
+			}
+			mode := hdr.FileInfo().Mode().Perm()
+			perm, readOnly, err := opts.prepareOverwrite(dstPath)
+			if err != nil {
+				return counts, err
+			}
+			if readOnly {
+				mode = perm
+			}
+			if err := writeFile(opts.Staging.target(dstPath), tr, mode); err != nil {
+				return counts, err
+			}
+			counts.Copied++
+
+		case tar.TypeSymlink:
+			if !opts.included(relPath) {
+				continue
+			}
+			if err := opts.Staging.mkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+				return counts, err
+			}
+			// A staged link replaces dstPath when it is moved into place
+			link := opts.Staging.target(dstPath)
+			if link == dstPath {
+				if err := os.Remove(dstPath); err != nil && !os.IsNotExist(err) {
+					return counts, err
+				}
+			}
+			if err := os.Symlink(hdr.Linkname, link); err != nil {
+				return counts, err
+			}
+			counts.Copied++
+		}
+	}
+}
+
+// underAny reports whether name lies inside one of the directories.
+func underAny(name string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFile writes the content of r to path, replacing any existing file.
+func writeFile(path string, r io.Reader, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,73 @@
+package syncer
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildTar returns a tar stream with a directory entry for each dir and a
+// regular file for each entry of files.
+func buildTar(t *testing.T, dirs []string, files map[string]string) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, dir := range dirs {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir + "/", Mode: 0755}); err != nil {
+			t.Fatalf("failed to write tar header: %v", err)
+		}
+	}
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatalf("failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write tar content: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar: %v", err)
+	}
+	return &buf
+}
+
+func TestExtractTar(t *testing.T) {
+	archive := buildTar(t, []string{"docs", "tmp"}, map[string]string{
+		"README.md":     "readme",
+		"docs/guide.md": "guide",
+		"tmp/cache.txt": "cache",
+		"debug.log":     "log",
+	})
+
+	dstDir := t.TempDir()
+	counts, err := extractTar(archive, dstDir, syncOptions{Exclude: []string{"tmp", "*.log"}})
+	if err != nil {
+		t.Fatalf("extractTar() failed: %v", err)
+	}
+	if counts.Copied != 2 || counts.Created != 2 {
+		t.Errorf("counts = %+v, want 2 files copied and created", counts)
+	}
+
+	for path, want := range map[string]string{"README.md": "readme", "docs/guide.md": "guide"} {
+		got, err := os.ReadFile(filepath.Join(dstDir, filepath.FromSlash(path)))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", path, got, err, want)
+		}
+	}
+	for _, path := range []string{"tmp", "debug.log"} {
+		if _, err := os.Stat(filepath.Join(dstDir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s is excluded and should not be extracted", path)
+		}
+	}
+}
+
+func TestExtractTarRejectsEscapingPaths(t *testing.T) {
+	archive := buildTar(t, nil, map[string]string{"../evil.txt": "evil"})
+
+	if _, err := extractTar(archive, t.TempDir(), syncOptions{}); err == nil {
+		t.Error("extractTar() should reject entries outside the destination")
+	}
+}
@@ -0,0 +1,125 @@
+package syncer
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// writeArchive writes the files below srcDir that a sync with opts copies
+// as a zstd-compressed tar to path (pull with -archive-out), returning the
+// number of files written. The archive is streamed through the encoder into
+// a temporary file that replaces path only once it is complete.
+func writeArchive(srcDir, path string, opts syncOptions) (files int, err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), ".file-syncer-archive-*")
+	if err != nil {
+		return 0, err
+	}
+	tmp := f.Name()
+	defer func() {
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+
+	zw, err := zstd.NewWriter(f)
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	tw := tar.NewWriter(zw)
+	files, err = writeTar(tw, srcDir, opts)
+	if closeErr := tw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return files, os.Rename(tmp, path)
+}
+
+// writeTar adds the directories, regular files and symlinks below srcDir to
+// tw, applying the same skip and include rules as syncFiles. Entry names are
+// slash-separated and relative to srcDir.
+func writeTar(tw *tar.Writer, srcDir string, opts syncOptions) (int, error) {
+	var files int
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		if opts.skipped(relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() && !opts.included(relPath) {
+			return nil
+		}
+
+		var link string
+		switch {
+		case info.IsDir():
+			// With include or negated exclude patterns, directories come
+			// with their files
+			if opts.dirsOnDemand() {
+				return nil
+			}
+		case info.Mode()&os.ModeSymlink != 0:
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case !info.Mode().IsRegular():
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("%s: %w", relPath, err)
+		}
+		hdr.Name = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		// Leave out the owner, which means nothing on another machine
+		hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		if _, err := io.Copy(tw, src); err != nil {
+			return fmt.Errorf("%s: %w", relPath, err)
+		}
+		files++
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,141 @@
+package syncer
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// readArchive returns the content of every regular file in the
+// zstd-compressed tar at path, keyed by entry name, and the directory names.
+func readArchive(t *testing.T, path string) (map[string]string, []string) {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer f.Close()
+	zr, err := zstd.NewReader(f)
+	if err != nil {
+		t.Fatalf("failed to read zstd stream: %v", err)
+	}
+	defer zr.Close()
+
+	files := map[string]string{}
+	var dirs []string
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, dirs
+		}
+		if err != nil {
+			t.Fatalf("failed to read archive: %v", err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			dirs = append(dirs, hdr.Name)
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatalf("failed to read %s: %v", hdr.Name, err)
+			}
+			files[hdr.Name] = string(data)
+		}
+	}
+}
+
+func TestWriteArchiveRoundTrip(t *testing.T) {
+	srcDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{
+		"README.md":          "# Project",
+		"docs/guide.md":      "guide",
+		"docs/img/logo.svg":  "<svg/>",
+		"build/output.bin":   "excluded",
+		".git/HEAD":          "ref: refs/heads/main",
+		".gitignore":         "build/",
+		"nested/deep/a.conf": "key=value",
+	})
+
+	path := filepath.Join(t.TempDir(), "snapshot.tar.zst")
+	files, err := writeArchive(srcDir, path, syncOptions{Exclude: []string{"build"}})
+	if err != nil {
+		t.Fatalf("writeArchive() failed: %v", err)
+	}
+
+	want := map[string]string{
+		"README.md":          "# Project",
+		"docs/guide.md":      "guide",
+		"docs/img/logo.svg":  "<svg/>",
+		".gitignore":         "build/",
+		"nested/deep/a.conf": "key=value",
+	}
+	got, dirs := readArchive(t, path)
+	if !maps.Equal(got, want) {
+		t.Errorf("archive files = %v, want %v", got, want)
+	}
+	if files != len(want) {
+		t.Errorf("writeArchive() = %d files, want %d", files, len(want))
+	}
+	wantDirs := []string{"docs/", "docs/img/", "nested/", "nested/deep/"}
+	if len(dirs) != len(wantDirs) {
+		t.Errorf("archive directories = %q, want %q", dirs, wantDirs)
+	}
+
+	// Only the archive is left in its directory
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("archive directory holds %d entries, want only the archive", len(entries))
+	}
+}
+
+func TestWriteArchiveInclude(t *testing.T) {
+	srcDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{
+		"docs/guide.md": "guide",
+		"docs/data.csv": "a,b",
+		"notes.md":      "notes",
+	})
+
+	path := filepath.Join(t.TempDir(), "snapshot.tar.zst")
+	if _, err := writeArchive(srcDir, path, syncOptions{Include: []string{"*.md"}}); err != nil {
+		t.Fatalf("writeArchive() failed: %v", err)
+	}
+
+	got, dirs := readArchive(t, path)
+	want := map[string]string{"docs/guide.md": "guide", "notes.md": "notes"}
+	if !maps.Equal(got, want) {
+		t.Errorf("archive files = %v, want %v", got, want)
+	}
+	if len(dirs) != 0 {
+		t.Errorf("archive directories = %q, want none with include patterns", dirs)
+	}
+}
+
+func TestWriteArchiveKeepsOldArchiveOnFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.tar.zst")
+	if err := os.WriteFile(path, []byte("previous"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if _, err := writeArchive(filepath.Join(t.TempDir(), "missing"), path, syncOptions{}); err == nil {
+		t.Fatal("writeArchive() of a missing directory succeeded, want an error")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "previous" {
+		t.Errorf("archive = %q, %v, want the previous archive kept", data, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("archive directory holds %d entries, want the temporary file removed", len(entries))
+	}
+}
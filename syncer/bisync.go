@@ -0,0 +1,397 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// syncManifestFile records, in the root of the folder, the content of every
+// file as of the last successful sync. It is the merge base of the next sync.
+const syncManifestFile = ".file-syncer-sync.json"
+
+// conflictSuffix is appended to the repository's version of a conflicting
+// file. While the copy exists, the file is left out of later syncs. Only the
+// copies recorded in the manifest are the tool's: any other file ending in
+// the suffix is synced like the rest.
+const conflictSuffix = ".conflict"
+
+// syncManifest is the state of a folder after the last sync.
+type syncManifest struct {
+	// Commit is the repository commit the folder matched.
+	Commit   string    `json:"commit,omitempty"`
+	SyncedAt time.Time `json:"syncedAt"`
+	// Algorithm is the checksum algorithm of Files. Empty means SHA-256,
+	// for manifests written before -checksum-algo.
+	Algorithm string `json:"algorithm,omitempty"`
+	// Files maps slash-separated paths to their digests.
+	Files map[string]string `json:"files"`
+	// Conflicts lists the slash-separated paths whose repository version
+	// was written next to them as a conflict copy.
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// conflictCopies returns the paths of the conflict copies of m.
+func (m *syncManifest) conflictCopies() []string {
+	copies := make([]string, len(m.Conflicts))
+	for i, path := range m.Conflicts {
+		copies[i] = path + conflictSuffix
+	}
+	return copies
+}
+
+// readSyncManifest loads the manifest at path. A missing manifest means the
+// folder was never synced and yields an empty base.
+func readSyncManifest(path string) (*syncManifest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &syncManifest{Files: map[string]string{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync manifest: %w", err)
+	}
+
+	var manifest syncManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse sync manifest %s: %w", path, err)
+	}
+	if manifest.Files == nil {
+		manifest.Files = map[string]string{}
+	}
+	return &manifest, nil
+}
+
+func writeSyncManifest(path string, manifest *syncManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sync manifest: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write sync manifest: %w", err)
+	}
+	return nil
+}
+
+// mergeAction says how a three-way merge resolves one path.
+type mergeAction int
+
+const (
+	// mergeNone means both sides already agree.
+	mergeNone mergeAction = iota
+	// mergeTakeRemote copies the repository's version, or deletion, to the folder.
+	mergeTakeRemote
+	// mergeTakeLocal copies the folder's version, or deletion, to the repository.
+	mergeTakeLocal
+	// mergeConflict means both sides changed the path differently.
+	mergeConflict
+)
+
+// mergeDecision resolves a path from the hashes of its base, local and
+// remote versions, where an empty hash means the file does not exist.
+func mergeDecision(base, local, remote string) mergeAction {
+	switch {
+	case local == remote:
+		return mergeNone
+	case local == base:
+		return mergeTakeRemote
+	case remote == base:
+		return mergeTakeLocal
+	default:
+		return mergeConflict
+	}
+}
+
+// listSyncFiles hashes the files below root that take part in a sync,
+// keyed by slash-separated relative path. The conflict copies in copies are
+// left out.
+func listSyncFiles(root string, copies []string, opts syncOptions, jobs int) (map[string]string, error) {
+	var relPaths, paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		if opts.skipped(relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !opts.included(relPath) {
+			return nil
+		}
+		if slices.Contains(copies, filepath.ToSlash(relPath)) {
+			return nil
+		}
+		// FIFOs, sockets and devices have no content to hash
+		if special, err := opts.special(relPath, path, info); special || err != nil {
+			return err
+		}
+		relPaths = append(relPaths, filepath.ToSlash(relPath))
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hashes, err := hashFiles(paths, opts.ChecksumAlgo, jobs)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string, len(relPaths))
+	for i, relPath := range relPaths {
+		files[relPath] = hashes[i]
+	}
+	return files, nil
+}
+
+// replaceFile makes dst match src: it copies src over dst, or removes dst
+// when the file was deleted (hash is empty).
+func replaceFile(src, dst, hash string, opts syncOptions) error {
+	if hash == "" {
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return copyFile(src, dst, info.Mode(), opts)
+}
+
+// Sync merges the local folder and the repository branch in both directions.
+// Changes made on one side since the last sync are copied to the other, and
+// local changes are committed and pushed. Files changed on both sides are
+// conflicts: the local version stays in place, the repository's version is
+// written next to it with a .conflict suffix, and ErrSyncConflict is
+// returned once everything else has been synced.
+func (s *Syncer) Sync(ctx context.Context) (result *RunResult, err error) {
+	config := s.config
+	logger := s.logger
+	result = &RunResult{Branch: config.Branch, RunID: config.RunID}
+
+	metrics := runMetrics{Start: time.Now()}
+	defer func() {
+		if result != nil {
+			metrics.Added, metrics.Modified = len(result.Stats.Added), len(result.Stats.Modified)
+			metrics.Deleted, metrics.Renamed = len(result.Stats.Deleted), len(result.Stats.Renamed)
+		}
+		s.recordMetrics(metrics, err)
+	}()
+
+	logger.Info("Starting sync operation")
+
+	absPath, err := filepath.Abs(config.FolderPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve folder path: %w", err)
+	}
+
+	// Keep overlapping runs on the same folder apart
+	release, err := s.lock(ctx, absPath)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	removeKey, err := s.installSSHKey()
+	if err != nil {
+		return nil, err
+	}
+	defer removeKey()
+
+	restoreBranch, err := s.resolveBranch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer restoreBranch()
+	config.Branch = s.config.Branch
+	result.Branch = config.Branch
+
+	if err := os.MkdirAll(absPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create folder: %w", err)
+	}
+	manifestPath := filepath.Join(absPath, syncManifestFile)
+	manifest, err := readSyncManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	// The base digests can't be compared with ones from another algorithm
+	algo := checksumAlgo(config.ChecksumAlgo)
+	if len(manifest.Files) > 0 && checksumAlgo(manifest.Algorithm) != algo {
+		return nil, fmt.Errorf("sync manifest %s was written with -checksum-algo %s, not %s; rerun with -checksum-algo %s", manifestPath, checksumAlgo(manifest.Algorithm), algo, checksumAlgo(manifest.Algorithm))
+	}
+
+	tempDir, removeTemp, err := s.makeTempDir()
+	if err != nil {
+		return nil, err
+	}
+	defer removeTemp()
+
+	if err := s.cloneForPush(ctx, tempDir); err != nil {
+		return nil, err
+	}
+	if config.Signoff {
+		if err := s.checkSignoffIdentity(ctx, tempDir); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := config.syncOptions()
+	// Only the writes into the folder refuse to replace read-only files
+	folderOpts := opts
+	folderOpts.ProtectReadOnly = true
+	copies := manifest.conflictCopies()
+	local, err := listSyncFiles(absPath, copies, opts, config.hashJobs())
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan folder: %w", err)
+	}
+	remote, err := listSyncFiles(tempDir, copies, opts, config.hashJobs())
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan repository: %w", err)
+	}
+
+	var paths []string
+	for _, files := range []map[string]string{manifest.Files, local, remote} {
+		for path := range files {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	// Merge every path, recording the content both sides agree on as the
+	// next base
+	base := map[string]string{}
+	var pulled int
+	var conflicts []string
+	for _, path := range paths {
+		localPath := filepath.Join(absPath, filepath.FromSlash(path))
+		repoPath := filepath.Join(tempDir, filepath.FromSlash(path))
+		b, l, r := manifest.Files[path], local[path], remote[path]
+
+		// An earlier conflict stays unresolved until its copy is removed
+		if slices.Contains(manifest.Conflicts, path) {
+			if _, err := os.Lstat(localPath + conflictSuffix); err == nil {
+				conflicts = append(conflicts, path)
+				if b != "" {
+					base[path] = b
+				}
+				continue
+			}
+		}
+
+		switch mergeDecision(b, l, r) {
+		case mergeNone:
+			if l != "" {
+				base[path] = l
+			}
+		case mergeTakeRemote:
+			logger.Debug("Pulling change", "path", path)
+			if err := replaceFile(repoPath, localPath, r, folderOpts); err != nil {
+				return nil, fmt.Errorf("failed to update %s: %w", path, err)
+			}
+			pulled++
+			if r != "" {
+				base[path] = r
+			}
+		case mergeTakeLocal:
+			if err := replaceFile(localPath, repoPath, l, opts); err != nil {
+				return nil, fmt.Errorf("failed to stage %s: %w", path, err)
+			}
+			if l != "" {
+				base[path] = l
+			}
+		case mergeConflict:
+			logger.Warn("Conflicting changes, keeping the local version", "path", path, "copy", path+conflictSuffix)
+			// An empty copy marks a file deleted in the repository
+			if r == "" {
+				err = os.WriteFile(localPath+conflictSuffix, nil, 0644)
+			} else {
+				err = replaceFile(repoPath, localPath+conflictSuffix, r, folderOpts)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to write conflict copy of %s: %w", path, err)
+			}
+			conflicts = append(conflicts, path)
+			// Once the copy is removed, the local version wins
+			if r != "" {
+				base[path] = r
+			}
+		}
+	}
+
+	// Record the conflict copies first, so that a later sync does not take
+	// them for the user's files if the push fails
+	if !slices.Equal(conflicts, manifest.Conflicts) {
+		manifest.Conflicts = conflicts
+		if err := writeSyncManifest(manifestPath, manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	// Commit and push the local changes
+	if err := s.runCommand(ctx, tempDir, "git", "add", "-A"); err != nil {
+		return nil, fmt.Errorf("failed to add changes: %w", err)
+	}
+	output, err := s.runCommandOutput(ctx, tempDir, "git", "status", "--porcelain", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check git status: %w", err)
+	}
+	result.Stats = parseGitStatus(output)
+	if strings.TrimSpace(output) != "" {
+		subject, body := generateCommitMessage(result.Stats, s.config.SummaryLimit)
+		logger.Info("Committing changes", "message", subject)
+		message := subject
+		if body != "" {
+			message = subject + "\n\n" + body
+		}
+		if err := s.commit(ctx, tempDir, message); err != nil {
+			return nil, err
+		}
+		logger.Info("Pushing to remote", "branch", config.Branch)
+		if err := s.push(ctx, tempDir); err != nil {
+			return nil, err
+		}
+		result.Pushed = true
+	}
+
+	// An empty repository without local files has no commit yet
+	var head string
+	if output, err := s.runCommandOutput(ctx, tempDir, "git", "rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		head = strings.TrimSpace(output)
+	}
+	if result.Pushed {
+		result.Commit = head
+	}
+	if err := writeSyncManifest(manifestPath, &syncManifest{Commit: head, SyncedAt: time.Now().UTC(), Algorithm: algo, Files: base, Conflicts: conflicts}); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Sync completed", "pulled", pulled, "pushed", result.Pushed, "conflicts", len(conflicts))
+	if len(conflicts) > 0 {
+		result.Conflicts = conflicts
+		return result, fmt.Errorf("%w: %s", ErrSyncConflict, strings.Join(conflicts, ", "))
+	}
+	if pulled == 0 && !result.Pushed {
+		return result, ErrNoChanges
+	}
+	return result, nil
+}
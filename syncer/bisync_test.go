@@ -0,0 +1,151 @@
+package syncer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeDecision(t *testing.T) {
+	tests := []struct {
+		name                string
+		base, local, remote string
+		want                mergeAction
+	}{
+		{name: "unchanged", base: "a", local: "a", remote: "a", want: mergeNone},
+		{name: "same change on both sides", base: "a", local: "b", remote: "b", want: mergeNone},
+		{name: "deleted on both sides", base: "a", want: mergeNone},
+		{name: "changed remotely", base: "a", local: "a", remote: "b", want: mergeTakeRemote},
+		{name: "deleted remotely", base: "a", local: "a", want: mergeTakeRemote},
+		{name: "added remotely", remote: "b", want: mergeTakeRemote},
+		{name: "changed locally", base: "a", local: "b", remote: "a", want: mergeTakeLocal},
+		{name: "deleted locally", base: "a", remote: "a", want: mergeTakeLocal},
+		{name: "added locally", local: "b", want: mergeTakeLocal},
+		{name: "changed differently", base: "a", local: "b", remote: "c", want: mergeConflict},
+		{name: "changed locally, deleted remotely", base: "a", local: "b", want: mergeConflict},
+		{name: "added differently", local: "b", remote: "c", want: mergeConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeDecision(tt.base, tt.local, tt.remote); got != tt.want {
+				t.Errorf("mergeDecision(%q, %q, %q) = %v, want %v", tt.base, tt.local, tt.remote, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListSyncFilesSkipsSyncState(t *testing.T) {
+	root := t.TempDir()
+	createTestFiles(t, root, map[string]string{
+		"notes.txt":          "notes",
+		"notes.txt.conflict": "theirs",
+		"docs/guide.md":      "guide",
+		// Not a copy the tool wrote
+		"docs/plan.conflict": "plan",
+		syncManifestFile:     "{}",
+		".git/HEAD":          "ref: refs/heads/main",
+	})
+
+	files, err := listSyncFiles(root, []string{"notes.txt.conflict"}, syncOptions{}, 1)
+	if err != nil {
+		t.Fatalf("listSyncFiles() failed: %v", err)
+	}
+
+	want := map[string]string{
+		"docs/guide.md":      hashString("guide"),
+		"docs/plan.conflict": hashString("plan"),
+		"notes.txt":          hashString("notes"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("listSyncFiles() = %v, want %v", files, want)
+	}
+}
+
+func TestSyncManifestIsNeverSynced(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	// A folder used with -mode sync, pushed or pulled into
+	createTestFiles(t, srcDir, map[string]string{syncManifestFile: "{}", "a.txt": "a"})
+	createTestFiles(t, dstDir, map[string]string{syncManifestFile: "{}"})
+
+	pushDir := t.TempDir()
+	if _, err := syncFiles(srcDir, pushDir, syncOptions{}); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(pushDir, syncManifestFile)); !os.IsNotExist(err) {
+		t.Errorf("the sync manifest was synced: %v", err)
+	}
+	removed, err := removeExtraneous(t.TempDir(), dstDir, syncOptions{})
+	if err != nil {
+		t.Fatalf("removeExtraneous() failed: %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("removeExtraneous() removed %v, want the sync manifest kept", removed)
+	}
+}
+
+func TestSyncManifestRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), syncManifestFile)
+
+	// A folder that was never synced has an empty base
+	manifest, err := readSyncManifest(path)
+	if err != nil {
+		t.Fatalf("readSyncManifest() of a missing manifest failed: %v", err)
+	}
+	if len(manifest.Files) != 0 {
+		t.Errorf("missing manifest should have no files, got %v", manifest.Files)
+	}
+
+	want := &syncManifest{Commit: "abc123", Files: map[string]string{"notes.txt": "deadbeef"}}
+	if err := writeSyncManifest(path, want); err != nil {
+		t.Fatalf("writeSyncManifest() failed: %v", err)
+	}
+	got, err := readSyncManifest(path)
+	if err != nil {
+		t.Fatalf("readSyncManifest() failed: %v", err)
+	}
+	if got.Commit != want.Commit || !reflect.DeepEqual(got.Files, want.Files) {
+		t.Errorf("round-tripped manifest = %+v, want %+v", got, want)
+	}
+}
+
+func TestSyncManifestAlgorithms(t *testing.T) {
+	root := t.TempDir()
+	createTestFiles(t, root, map[string]string{"notes.txt": "notes"})
+
+	for _, algo := range []string{ChecksumSHA1, ChecksumBLAKE2b} {
+		t.Run(algo, func(t *testing.T) {
+			files, err := listSyncFiles(root, nil, syncOptions{ChecksumAlgo: algo}, 1)
+			if err != nil {
+				t.Fatalf("listSyncFiles() failed: %v", err)
+			}
+			want, err := hashFile(filepath.Join(root, "notes.txt"), algo)
+			if err != nil {
+				t.Fatalf("hashFile() failed: %v", err)
+			}
+			if files["notes.txt"] != want {
+				t.Errorf("listSyncFiles() = %v, want the %s digest %s", files, algo, want)
+			}
+
+			path := filepath.Join(t.TempDir(), syncManifestFile)
+			if err := writeSyncManifest(path, &syncManifest{Algorithm: algo, Files: files}); err != nil {
+				t.Fatalf("writeSyncManifest() failed: %v", err)
+			}
+			got, err := readSyncManifest(path)
+			if err != nil {
+				t.Fatalf("readSyncManifest() failed: %v", err)
+			}
+			if got.Algorithm != algo || !reflect.DeepEqual(got.Files, files) {
+				t.Errorf("round-tripped manifest = %+v, want algorithm %s and files %v", got, algo, files)
+			}
+		})
+	}
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,118 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// BranchAuto as the branch name makes a run use the remote's default branch
+// (-branch auto).
+const BranchAuto = "auto"
+
+// remoteDefaultBranch asks the remote which branch its HEAD points to.
+func (s *Syncer) remoteDefaultBranch(ctx context.Context) (string, error) {
+	output, err := s.runCommandOutput(ctx, "", "git", "ls-remote", "--symref", s.config.RepoURL, "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to query the remote's default branch: %w: %s", ErrCloneFailed, err, strings.TrimSpace(output))
+	}
+	branch, ok := parseSymref(output)
+	if !ok {
+		return "", fmt.Errorf("%w: the remote has no default branch, it may be empty; pass -branch explicitly", ErrCloneFailed)
+	}
+	return branch, nil
+}
+
+// parseSymref extracts the branch name from git ls-remote --symref output
+// for HEAD, e.g. "ref: refs/heads/master\tHEAD".
+func parseSymref(output string) (string, bool) {
+	for _, line := range strings.Split(output, "\n") {
+		ref, name, ok := strings.Cut(strings.TrimSuffix(line, "\r"), "\t")
+		if !ok || name != "HEAD" {
+			continue
+		}
+		if branch, ok := strings.CutPrefix(ref, "ref: refs/heads/"); ok && branch != "" {
+			return branch, true
+		}
+	}
+	return "", false
+}
+
+// resolveBranch replaces -branch auto with the remote's default branch for
+// the duration of a run. The returned function restores it, so that a
+// Syncer that is run again resolves the branch again.
+func (s *Syncer) resolveBranch(ctx context.Context) (func(), error) {
+	if s.config.Branch != BranchAuto {
+		return func() {}, nil
+	}
+	branch, err := s.remoteDefaultBranch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("Using the remote's default branch", "branch", branch)
+	s.config.Branch = branch
+	return func() { s.config.Branch = BranchAuto }, nil
+}
+
+// branchTemplateData is the data available to -branch-template.
+type branchTemplateData struct {
+	// Date is the UTC date of the run as YYYYMMDD
+	Date string
+	// Time is the UTC time of the run as HHMMSS
+	Time string
+	// Base is the branch the new branch starts from
+	Base string
+	// RunID is the identifier of the run
+	RunID string
+}
+
+// parseBranchTemplate parses a -branch-template value.
+func parseBranchTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("branch").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid branch template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderBranchName renders a branch template for a run started at now that
+// branches off base, and checks that the result is a valid branch name.
+func renderBranchName(text string, now time.Time, base, runID string) (string, error) {
+	tmpl, err := parseBranchTemplate(text)
+	if err != nil {
+		return "", err
+	}
+
+	var name strings.Builder
+	now = now.UTC()
+	data := branchTemplateData{Date: now.Format("20060102"), Time: now.Format("150405"), Base: base, RunID: runID}
+	if err := tmpl.Execute(&name, data); err != nil {
+		return "", fmt.Errorf("failed to render branch template: %w", err)
+	}
+	branch := strings.TrimSpace(name.String())
+	if !isValidBranchName(branch) {
+		return "", fmt.Errorf("branch template rendered the invalid branch name %q", branch)
+	}
+	return branch, nil
+}
+
+// checkoutTemplateBranch creates the branch named by -branch-template for a
+// run started at now in the clone at dir, starting from the checked out
+// base branch, and returns its name. A branch the remote already has is
+// never reused, as the run would push on top of someone else's work.
+func (s *Syncer) checkoutTemplateBranch(ctx context.Context, dir string, now time.Time) (string, error) {
+	branch, err := renderBranchName(s.config.BranchTemplate, now, s.config.Branch, s.config.RunID)
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.runCommandOutput(ctx, dir, "git", "rev-parse", "-q", "--verify", "refs/remotes/origin/"+branch); err == nil {
+		return "", fmt.Errorf("%w: branch %s already exists on the remote", ErrPushRejected, branch)
+	}
+	s.logger.Info("Creating branch", "branch", branch, "base", s.config.Branch)
+	if err := s.runCommand(ctx, dir, "git", "checkout", "-b", branch); err != nil {
+		return "", fmt.Errorf("failed to create branch %s: %w", branch, err)
+	}
+	return branch, nil
+}
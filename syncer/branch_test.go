@@ -0,0 +1,87 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseSymref(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+		wantOK bool
+	}{
+		{name: "master", output: "ref: refs/heads/master\tHEAD\n1f0c3e2d\tHEAD\n", want: "master", wantOK: true},
+		{name: "nested branch", output: "ref: refs/heads/release/v2\tHEAD\n1f0c3e2d\tHEAD\n", want: "release/v2", wantOK: true},
+		{name: "CRLF", output: "ref: refs/heads/main\tHEAD\r\n1f0c3e2d\tHEAD\r\n", want: "main", wantOK: true},
+		{name: "no symref", output: "1f0c3e2d\tHEAD\n"},
+		{name: "empty remote", output: ""},
+		{name: "other ref", output: "ref: refs/heads/main\trefs/remotes/origin/HEAD\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseSymref(tt.output)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseSymref() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRenderBranchName(t *testing.T) {
+	now := time.Date(2024, 1, 1, 13, 0, 0, 0, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{name: "date and time in UTC", template: "sync/{{.Date}}-{{.Time}}", want: "sync/20240101-120000"},
+		{name: "base and run ID", template: "sync/{{.Base}}/{{.RunID}}", want: "sync/main/3f9a2c1d"},
+		{name: "surrounding whitespace", template: " sync-{{.Date}} ", want: "sync-20240101"},
+		{name: "unknown field", template: "sync/{{.Commit}}", wantErr: true},
+		{name: "invalid branch name", template: "sync {{.Date}}", wantErr: true},
+		{name: "empty name", template: "{{.RunID}}", wantErr: true},
+		{name: "parse error", template: "sync/{{.Date", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runID := "3f9a2c1d"
+			if tt.name == "empty name" {
+				runID = ""
+			}
+			got, err := renderBranchName(tt.template, now, "main", runID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderBranchName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("renderBranchName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckoutTemplateBranchExisting(t *testing.T) {
+	// The fake git finds every ref, so the branch already exists
+	logPath := installFakeGit(t, ":")
+	s := newTestSyncer(t, Config{Mode: ModePush, FolderPath: "/tmp/test", RepoURL: "https://github.com/user/repo.git", Branch: "main", BranchTemplate: "sync/{{.Date}}"})
+
+	_, err := s.checkoutTemplateBranch(context.Background(), t.TempDir(), time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	if !errors.Is(err, ErrPushRejected) {
+		t.Fatalf("checkoutTemplateBranch() error = %v, want ErrPushRejected", err)
+	}
+	calls := readFakeGitCalls(t, logPath)
+	if !slices.Contains(calls, "rev-parse -q --verify refs/remotes/origin/sync/20240101") {
+		t.Errorf("git calls = %q, want a lookup of the rendered branch", calls)
+	}
+	if slices.Contains(calls, "checkout -b sync/20240101") {
+		t.Errorf("git calls = %q, want no branch created", calls)
+	}
+}
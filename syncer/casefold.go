@@ -0,0 +1,109 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// checkCaseCollisions fails with ErrCaseCollision if the repository checked
+// out in repoDir holds files whose paths differ only in case and dstDir is on
+// a case-insensitive filesystem, where copying them would silently let one
+// overwrite the other. The paths come from git rather than a walk of repoDir
+// because a checkout on a case-insensitive filesystem has already lost one
+// of them. Paths a sync with opts skips are ignored.
+func (s *Syncer) checkCaseCollisions(ctx context.Context, repoDir, dstDir string, opts syncOptions) error {
+	args := []string{"ls-files", "-z"}
+	if s.config.RecurseSubmodules {
+		args = append(args, "--recurse-submodules")
+	}
+	out, err := s.runCommandOutput(ctx, repoDir, "git", args...)
+	if err != nil {
+		return fmt.Errorf("failed to list repository files: %w", err)
+	}
+	var paths []string
+	for _, p := range strings.Split(out, "\x00") {
+		if p != "" && !opts.skippedPath(p) && opts.included(filepath.FromSlash(p)) {
+			paths = append(paths, p)
+		}
+	}
+
+	collisions := detectCaseCollisions(paths)
+	if len(collisions) == 0 {
+		return nil
+	}
+	insensitive := s.caseInsensitive
+	if insensitive == nil {
+		insensitive = caseInsensitiveDir
+	}
+	fold, err := insensitive(dstDir)
+	if err != nil {
+		return fmt.Errorf("failed to check filesystem case sensitivity: %w", err)
+	}
+	groups := make([]string, len(collisions))
+	for i, group := range collisions {
+		groups[i] = strings.Join(group, " and ")
+	}
+	if !fold {
+		s.logger.Debug("Paths differ only in case", "paths", groups)
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrCaseCollision, strings.Join(groups, ", "))
+}
+
+// skippedPath reports whether a sync with o skips the file at slashPath,
+// either itself or because one of its parent directories is skipped. It is
+// the equivalent of the directory pruning done while walking a tree.
+func (o syncOptions) skippedPath(slashPath string) bool {
+	for i, c := range slashPath {
+		if c == '/' && o.skipped(filepath.FromSlash(slashPath[:i]), true) {
+			return true
+		}
+	}
+	return o.skipped(filepath.FromSlash(slashPath), false)
+}
+
+// detectCaseCollisions groups the slash-separated paths that differ only in
+// case. Each group is sorted, as are the groups, so that reports are stable.
+func detectCaseCollisions(paths []string) [][]string {
+	byFold := make(map[string][]string)
+	for _, p := range paths {
+		key := strings.ToLower(p)
+		if !slices.Contains(byFold[key], p) {
+			byFold[key] = append(byFold[key], p)
+		}
+	}
+	var collisions [][]string
+	for _, group := range byFold {
+		if len(group) > 1 {
+			slices.Sort(group)
+			collisions = append(collisions, group)
+		}
+	}
+	slices.SortFunc(collisions, func(a, b []string) int {
+		return strings.Compare(a[0], b[0])
+	})
+	return collisions
+}
+
+// caseInsensitiveDir reports whether dir is on a filesystem that ignores
+// case in file names, such as the defaults on macOS and Windows. It creates
+// a probe file and looks it up again by its upper-case name.
+func caseInsensitiveDir(dir string) (bool, error) {
+	f, err := os.CreateTemp(dir, ".file-syncer-case-*")
+	if err != nil {
+		return false, err
+	}
+	name := f.Name()
+	f.Close()
+	defer os.Remove(name)
+
+	_, err = os.Lstat(filepath.Join(dir, strings.ToUpper(filepath.Base(name))))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
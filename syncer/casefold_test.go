@@ -0,0 +1,91 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDetectCaseCollisions(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		want  [][]string
+	}{
+		{
+			name:  "no collisions",
+			paths: []string{"a.txt", "b.txt", "docs/a.txt"},
+		},
+		{
+			name:  "file names",
+			paths: []string{"file.txt", "README.md", "File.txt"},
+			want:  [][]string{{"File.txt", "file.txt"}},
+		},
+		{
+			name:  "directory names",
+			paths: []string{"docs/a.txt", "Docs/a.txt", "Docs/b.txt", "docs/c.txt"},
+			want:  [][]string{{"Docs/a.txt", "docs/a.txt"}},
+		},
+		{
+			name:  "several groups",
+			paths: []string{"b.txt", "B.TXT", "a.txt", "A.txt", "a.TXT"},
+			want:  [][]string{{"A.txt", "a.TXT", "a.txt"}, {"B.TXT", "b.txt"}},
+		},
+		{
+			name:  "duplicates",
+			paths: []string{"a.txt", "a.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectCaseCollisions(tt.paths)
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("detectCaseCollisions(%q) = %q, want %q", tt.paths, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCaseInsensitiveDir(t *testing.T) {
+	dir := t.TempDir()
+
+	// Work out what the filesystem does independently of the probe
+	if err := os.WriteFile(filepath.Join(dir, "case.txt"), nil, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	_, err := os.Stat(filepath.Join(dir, "CASE.TXT"))
+	want := err == nil
+
+	got, err := caseInsensitiveDir(dir)
+	if err != nil {
+		t.Fatalf("caseInsensitiveDir() failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("caseInsensitiveDir() = %v, want %v", got, want)
+	}
+
+	// The probe file is removed again
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only case.txt", len(entries))
+	}
+}
+
+func TestSkippedPath(t *testing.T) {
+	opts := syncOptions{Exclude: []string{"build"}}
+
+	if !opts.skippedPath("build/File.txt") {
+		t.Error("skippedPath() = false for a file in an excluded directory, want true")
+	}
+	if !opts.skippedPath(".git/config") {
+		t.Error("skippedPath() = false for a file in .git, want true")
+	}
+	if opts.skippedPath("src/File.txt") {
+		t.Error("skippedPath() = true for a synced file, want false")
+	}
+}
@@ -0,0 +1,69 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// Check verifies that a run could start, without cloning or touching the
+// folder (-validate-only). The configuration has been validated by New; Check
+// loads the schema, if any, and asks the remote for its branches, which
+// proves that it is reachable and that the credentials work. Pull and sync
+// need the branch to exist, push creates it if needed.
+func (s *Syncer) Check(ctx context.Context) error {
+	config := s.config
+	logger := s.logger
+
+	if _, err := config.loadSchema(); err != nil {
+		return err
+	}
+
+	removeKey, err := s.installSSHKey()
+	if err != nil {
+		return err
+	}
+	defer removeKey()
+
+	restoreBranch, err := s.resolveBranch(ctx)
+	if err != nil {
+		return err
+	}
+	defer restoreBranch()
+	config.Branch = s.config.Branch
+
+	if config.Signoff {
+		// Outside any repository, only the environment and the global
+		// and system configuration count
+		if err := s.checkSignoffIdentity(ctx, os.TempDir()); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Checking repository access", "repository", SanitizeURL(config.RepoURL))
+	remote, err := s.remoteBranches(ctx, "")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCloneFailed, err)
+	}
+	logger.Info("Repository is reachable", "branches", len(remote))
+
+	// -ref can name a tag or commit, which only a clone can resolve
+	if config.Ref != "" {
+		logger.Info("Not checking -ref, which is resolved when cloning", "ref", config.Ref)
+		logger.Info("Validation passed")
+		return nil
+	}
+	for _, branch := range append([]string{config.Branch}, config.Branches...) {
+		switch {
+		case slices.Contains(remote, branch):
+			logger.Info("Branch found", "branch", branch)
+		case config.Mode == ModePush:
+			logger.Info("Branch not found, push will create it", "branch", branch)
+		default:
+			return fmt.Errorf("%w: branch %s not found on the remote", ErrCloneFailed, branch)
+		}
+	}
+	logger.Info("Validation passed")
+	return nil
+}
@@ -0,0 +1,430 @@
+package syncer
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"os/exec"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FileChangeStats holds statistics about file changes
+type FileChangeStats struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Deleted  []string `json:"deleted"`
+	// Renamed holds {old, new} path pairs of moved files.
+	Renamed [][2]string `json:"renamed"`
+}
+
+// parseGitStatus parses git status --porcelain -z output and returns file
+// change statistics. Paths are taken as git prints them with -z, unquoted,
+// so that they can be passed back to git. Each list is sorted, renames by
+// their old path, so that commit messages and other output do not depend on
+// the order git reports files in.
+func parseGitStatus(statusOutput string) FileChangeStats {
+	stats := FileChangeStats{
+		Added:    []string{},
+		Modified: []string{},
+		Deleted:  []string{},
+		Renamed:  [][2]string{},
+	}
+
+	// Entries are NUL-terminated: "XY path", where X is the status in the
+	// index and Y the one in the working tree. A rename or copy is followed
+	// by a second entry holding the source path.
+	fields := strings.Split(statusOutput, "\x00")
+	for i := 0; i < len(fields); i++ {
+		entry := fields[i]
+		if len(entry) < 4 {
+			continue
+		}
+		statusCode := entry[0:2]
+		filename := entry[3:]
+
+		switch {
+		case statusCode[0] == 'R' || statusCode[0] == 'C':
+			if i+1 >= len(fields) {
+				stats.Modified = append(stats.Modified, filename)
+				break
+			}
+			i++
+			if statusCode[0] == 'C' {
+				stats.Added = append(stats.Added, filename)
+			} else {
+				stats.Renamed = append(stats.Renamed, [2]string{fields[i], filename})
+			}
+		case statusCode == "??" || statusCode[0] == 'A':
+			stats.Added = append(stats.Added, filename)
+		case statusCode[0] == 'D' || statusCode == " D":
+			stats.Deleted = append(stats.Deleted, filename)
+		default:
+			// M, T (type change, e.g. a file replaced by a symlink) and any
+			// other code all change an existing path
+			stats.Modified = append(stats.Modified, filename)
+		}
+	}
+
+	slices.Sort(stats.Added)
+	slices.Sort(stats.Modified)
+	slices.Sort(stats.Deleted)
+	slices.SortFunc(stats.Renamed, func(a, b [2]string) int {
+		return cmp.Or(strings.Compare(a[0], b[0]), strings.Compare(a[1], b[1]))
+	})
+	return stats
+}
+
+// paths returns every path touched by the changes, including both names of
+// renamed files.
+func (stats FileChangeStats) paths() []string {
+	var paths []string
+	paths = append(paths, stats.Added...)
+	paths = append(paths, stats.Modified...)
+	paths = append(paths, stats.Deleted...)
+	for _, rename := range stats.Renamed {
+		paths = append(paths, rename[0], rename[1])
+	}
+	return paths
+}
+
+// total returns the number of changed files, counting a rename once.
+func (stats FileChangeStats) total() int {
+	return len(stats.Added) + len(stats.Modified) + len(stats.Deleted) + len(stats.Renamed)
+}
+
+// touchesPaths reports whether any changed path matches one of the
+// -commit-paths patterns, either itself or through one of its parent
+// directories, so that "config" and "config/" cover everything below config.
+func (stats FileChangeStats) touchesPaths(patterns []string) bool {
+	trimmed := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		trimmed[i] = strings.TrimSuffix(pattern, "/")
+	}
+	for _, file := range stats.paths() {
+		for p := file; p != "." && p != "/"; p = path.Dir(p) {
+			if matchAny(trimmed, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// generateCommitMessage creates a meaningful commit message based on file
+// changes. The body lists at most limit files per category, followed by a
+// count of the rest; a limit of zero lists every file.
+func generateCommitMessage(stats FileChangeStats, limit int) (string, string) {
+	totalChanges := stats.total()
+
+	// Build commit subject
+	var subject strings.Builder
+	subject.WriteString("Sync ")
+	subject.WriteString(fmt.Sprintf("%d file", totalChanges))
+	if totalChanges != 1 {
+		subject.WriteString("s")
+	}
+
+	parts := []string{}
+	if len(stats.Added) > 0 {
+		parts = append(parts, fmt.Sprintf("%d added", len(stats.Added)))
+	}
+	if len(stats.Modified) > 0 {
+		parts = append(parts, fmt.Sprintf("%d modified", len(stats.Modified)))
+	}
+	if len(stats.Deleted) > 0 {
+		parts = append(parts, fmt.Sprintf("%d deleted", len(stats.Deleted)))
+	}
+	if len(stats.Renamed) > 0 {
+		parts = append(parts, fmt.Sprintf("%d renamed", len(stats.Renamed)))
+	}
+
+	if len(parts) > 0 {
+		subject.WriteString(" (")
+		subject.WriteString(strings.Join(parts, ", "))
+		subject.WriteString(")")
+	}
+
+	// Build commit body with file details
+	renamed := make([]string, len(stats.Renamed))
+	for i, rename := range stats.Renamed {
+		renamed[i] = rename[0] + " -> " + rename[1]
+	}
+	sections := []struct {
+		title  string
+		prefix string
+		files  []string
+	}{
+		{"Added files:", "  + ", stats.Added},
+		{"Modified files:", "  ~ ", stats.Modified},
+		{"Deleted files:", "  - ", stats.Deleted},
+		{"Renamed files:", "  ", renamed},
+	}
+
+	var body strings.Builder
+	for _, section := range sections {
+		if len(section.files) == 0 {
+			continue
+		}
+		if body.Len() > 0 {
+			body.WriteString("\n")
+		}
+		body.WriteString(section.title + "\n")
+		files := section.files
+		if limit > 0 && len(files) > limit {
+			files = files[:limit]
+		}
+		for _, file := range files {
+			body.WriteString(section.prefix + file + "\n")
+		}
+		if more := len(section.files) - len(files); more > 0 {
+			body.WriteString(fmt.Sprintf("  ... and %d more\n", more))
+		}
+	}
+
+	return subject.String(), strings.TrimSpace(body.String())
+}
+
+// syncTimeTrailer is the trailer that, given without a value, is filled with
+// the time of the commit.
+const syncTimeTrailer = "Sync-Time"
+
+// trailerKeyPattern matches the keys git accepts as trailer tokens.
+var trailerKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// parseTrailer splits a -commit-trailer value of the form key=value.
+func parseTrailer(trailer string) (string, string, error) {
+	key, value, hasValue := strings.Cut(trailer, "=")
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	if !trailerKeyPattern.MatchString(key) {
+		return "", "", fmt.Errorf("invalid commit trailer %q: key must consist of letters, digits and dashes", trailer)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return "", "", fmt.Errorf("invalid commit trailer %q: value must be a single line", trailer)
+	}
+	if value == "" && (hasValue || key != syncTimeTrailer) {
+		return "", "", fmt.Errorf("invalid commit trailer %q: expected key=value", trailer)
+	}
+	return key, value, nil
+}
+
+// renderTrailers formats trailers as "Key: value" lines in the given order,
+// filling a bare Sync-Time with now.
+func renderTrailers(trailers []string, now time.Time) string {
+	lines := make([]string, 0, len(trailers))
+	for _, trailer := range trailers {
+		// Validate has already checked the format
+		key, value, _ := parseTrailer(trailer)
+		if value == "" {
+			value = now.Format(time.RFC3339)
+		}
+		lines = append(lines, key+": "+value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// appendTrailers adds the trailer block to message, separated from it by a
+// blank line as git expects.
+func appendTrailers(message, trailers string) string {
+	if trailers == "" {
+		return message
+	}
+	return strings.TrimRight(message, "\n") + "\n\n" + trailers
+}
+
+// heartbeatSubject is the message of the empty commit created by
+// -commit-empty when there are no file changes.
+const heartbeatSubject = "Sync heartbeat: no file changes"
+
+// prefixSubject prepends prefix to the subject line of message (-message-prefix),
+// separated from it by exactly one space.
+func prefixSubject(prefix, message string) string {
+	if prefix == "" {
+		return message
+	}
+	return strings.TrimRight(prefix, " \t") + " " + strings.TrimLeft(message, " \t\r\n")
+}
+
+// commitCommand builds the git commit command for message, with the
+// -message-prefix prefix prepended and the -commit-trailer trailers
+// appended, amending the branch tip if amend is set. With CommitDate set,
+// the author and committer dates are pinned to it.
+func (s *Syncer) commitCommand(ctx context.Context, dir string, message string, amend bool) *exec.Cmd {
+	message = prefixSubject(s.config.MessagePrefix, message)
+	message = appendTrailers(message, renderTrailers(s.config.CommitTrailers, time.Now()))
+	cmd := s.command(ctx, dir, "git", commitArgs(s.config, message, amend)...)
+	if s.config.CommitDate != "" {
+		// Validate has already checked the format
+		date, _ := time.Parse(time.RFC3339, s.config.CommitDate)
+		gitDate := gitDateFormat(date)
+		cmd.Env = append(cmd.Env, "GIT_AUTHOR_DATE="+gitDate, "GIT_COMMITTER_DATE="+gitDate)
+	}
+	return cmd
+}
+
+// checkSignoffIdentity makes sure git knows the committer's name and email
+// in the clone at dir, from the environment or the git configuration, before
+// anything is committed (-signoff). Git would otherwise sign off with a
+// guessed identity such as user@hostname.
+func (s *Syncer) checkSignoffIdentity(ctx context.Context, dir string) error {
+	if _, err := s.runCommandOutput(ctx, dir, "git", "-c", "user.useConfigOnly=true", "var", "GIT_COMMITTER_IDENT"); err != nil {
+		return fmt.Errorf("-signoff requires a committer identity, set user.name and user.email in the git configuration or GIT_COMMITTER_NAME and GIT_COMMITTER_EMAIL: %w", err)
+	}
+	return nil
+}
+
+// gitDateFormat formats t in git's internal "<unix seconds> <offset>" date
+// format, which keeps the time zone and is parsed unambiguously.
+func gitDateFormat(t time.Time) string {
+	return fmt.Sprintf("%d %s", t.Unix(), t.Format("-0700"))
+}
+
+// commitArgs builds the git arguments that create the sync commit with the
+// given message, adding the signing options when Sign is set, allowing an
+// empty commit when CommitEmpty is set, adding a Signed-off-by trailer when
+// Signoff is set and skipping the commit hooks when NoVerify is set. With
+// amend, the commit replaces the branch tip and gets a fresh author date.
+func commitArgs(config Config, message string, amend bool) []string {
+	var args []string
+	if config.Sign {
+		args = append(args, "-c", "commit.gpgsign=true")
+		if config.SigningKey != "" {
+			args = append(args, "-c", "user.signingkey="+config.SigningKey)
+		}
+	}
+
+	args = append(args, "commit")
+	if config.Sign {
+		args = append(args, "-S")
+	}
+	if config.Signoff {
+		args = append(args, "-s")
+	}
+	if amend {
+		args = append(args, "--amend", "--reset-author")
+	}
+	if config.CommitEmpty {
+		args = append(args, "--allow-empty")
+	}
+	if config.NoVerify {
+		args = append(args, "--no-verify")
+	}
+	// With a prefix, the caller passes the file's content as the message
+	if config.CommitMessageFile != "" && config.MessagePrefix == "" {
+		return append(args, "-F", config.CommitMessageFile)
+	}
+	return append(args, "-m", message)
+}
+
+// fileCommit is a single-path commit made in -commit-per-file mode.
+type fileCommit struct {
+	Path string
+	// OldPath is the previous path of a renamed file, empty otherwise.
+	OldPath string
+	Message string
+}
+
+// perFileCommits lists one commit per changed path: additions first, then
+// modifications, deletions and renames.
+func perFileCommits(stats FileChangeStats) []fileCommit {
+	var commits []fileCommit
+	for _, file := range stats.Added {
+		commits = append(commits, fileCommit{Path: file, Message: "Sync: add " + file})
+	}
+	for _, file := range stats.Modified {
+		commits = append(commits, fileCommit{Path: file, Message: "Sync: modify " + file})
+	}
+	for _, file := range stats.Deleted {
+		commits = append(commits, fileCommit{Path: file, Message: "Sync: delete " + file})
+	}
+	for _, rename := range stats.Renamed {
+		commits = append(commits, fileCommit{Path: rename[1], OldPath: rename[0], Message: "Sync: rename " + rename[0] + " -> " + rename[1]})
+	}
+	return commits
+}
+
+// groupCommit is a commit of all changed paths sharing an extension, made
+// in -group-by ext mode.
+type groupCommit struct {
+	// Ext is the lower-cased extension, e.g. ".md", or empty for files
+	// without one.
+	Ext     string
+	Paths   []string
+	Message string
+}
+
+// fileExt returns the lower-cased extension of file. Dotfiles such as
+// .gitignore have no extension.
+func fileExt(file string) string {
+	base := path.Base(file)
+	ext := path.Ext(base)
+	if ext == base {
+		return ""
+	}
+	return strings.ToLower(ext)
+}
+
+// extensionCommits partitions the changes by file extension and returns one
+// commit per extension in alphabetical order, files without an extension
+// last. Renames are grouped by their new name. limit caps the files listed
+// in each body, as in generateCommitMessage.
+func extensionCommits(stats FileChangeStats, limit int) []groupCommit {
+	groups := make(map[string]*FileChangeStats)
+	group := func(file string) *FileChangeStats {
+		ext := fileExt(file)
+		if groups[ext] == nil {
+			groups[ext] = &FileChangeStats{}
+		}
+		return groups[ext]
+	}
+	for _, file := range stats.Added {
+		g := group(file)
+		g.Added = append(g.Added, file)
+	}
+	for _, file := range stats.Modified {
+		g := group(file)
+		g.Modified = append(g.Modified, file)
+	}
+	for _, file := range stats.Deleted {
+		g := group(file)
+		g.Deleted = append(g.Deleted, file)
+	}
+	for _, rename := range stats.Renamed {
+		g := group(rename[1])
+		g.Renamed = append(g.Renamed, rename)
+	}
+
+	exts := slices.Collect(maps.Keys(groups))
+	slices.SortFunc(exts, func(a, b string) int {
+		// Sort files without an extension last
+		if (a == "") != (b == "") {
+			return strings.Compare(b, a)
+		}
+		return strings.Compare(a, b)
+	})
+
+	commits := make([]groupCommit, 0, len(exts))
+	for _, ext := range exts {
+		g := groups[ext]
+		var paths []string
+		paths = append(paths, g.Added...)
+		paths = append(paths, g.Modified...)
+		paths = append(paths, g.Deleted...)
+		for _, rename := range g.Renamed {
+			paths = append(paths, rename[1], rename[0])
+		}
+		count := len(g.Added) + len(g.Modified) + len(g.Deleted) + len(g.Renamed)
+
+		subject := fmt.Sprintf("Sync %s files (%d changed)", ext, count)
+		if ext == "" {
+			subject = fmt.Sprintf("Sync files without extension (%d changed)", count)
+		}
+		_, body := generateCommitMessage(*g, limit)
+		commits = append(commits, groupCommit{Ext: ext, Paths: paths, Message: subject + "\n\n" + body})
+	}
+	return commits
+}
@@ -0,0 +1,614 @@
+package syncer
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGitStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   FileChangeStats
+	}{
+		{
+			name:   "single added file",
+			output: "A  newfile.txt\x00",
+			want: FileChangeStats{
+				Added:    []string{"newfile.txt"},
+				Modified: []string{},
+				Deleted:  []string{},
+			},
+		},
+		{
+			name:   "single modified file",
+			output: "M  modified.txt\x00",
+			want: FileChangeStats{
+				Added:    []string{},
+				Modified: []string{"modified.txt"},
+				Deleted:  []string{},
+			},
+		},
+		{
+			name:   "single deleted file",
+			output: "D  deleted.txt\x00",
+			want: FileChangeStats{
+				Added:    []string{},
+				Modified: []string{},
+				Deleted:  []string{"deleted.txt"},
+			},
+		},
+		{
+			name:   "mixed changes",
+			output: "A  added.txt\x00M  modified.txt\x00D  deleted.txt\x00",
+			want: FileChangeStats{
+				Added:    []string{"added.txt"},
+				Modified: []string{"modified.txt"},
+				Deleted:  []string{"deleted.txt"},
+			},
+		},
+		{
+			name:   "untracked file",
+			output: "?? untracked.txt\x00",
+			want: FileChangeStats{
+				Added:    []string{"untracked.txt"},
+				Modified: []string{},
+				Deleted:  []string{},
+			},
+		},
+		{
+			name:   "multiple files of same type",
+			output: "A  file1.txt\x00A  file2.txt\x00M  file3.txt\x00",
+			want: FileChangeStats{
+				Added:    []string{"file1.txt", "file2.txt"},
+				Modified: []string{"file3.txt"},
+				Deleted:  []string{},
+			},
+		},
+		{
+			name:   "modified with space prefix",
+			output: " M modified.txt\x00",
+			want: FileChangeStats{
+				Added:    []string{},
+				Modified: []string{"modified.txt"},
+				Deleted:  []string{},
+			},
+		},
+		{
+			name:   "type change",
+			output: "T  link.txt\x00",
+			want: FileChangeStats{
+				Added:    []string{},
+				Modified: []string{"link.txt"},
+				Deleted:  []string{},
+			},
+		},
+		{
+			name:   "added and modified",
+			output: "AM added.txt\x00",
+			want: FileChangeStats{
+				Added:    []string{"added.txt"},
+				Modified: []string{},
+				Deleted:  []string{},
+			},
+		},
+		{
+			name:   "paths are not quoted",
+			output: "A  a b.txt\x00M  café.txt\x00",
+			want: FileChangeStats{
+				Added:    []string{"a b.txt"},
+				Modified: []string{"café.txt"},
+				Deleted:  []string{},
+			},
+		},
+		{
+			name:   "renamed file",
+			output: "R  new.txt\x00old.txt\x00",
+			want: FileChangeStats{
+				Added:    []string{},
+				Modified: []string{},
+				Deleted:  []string{},
+				Renamed:  [][2]string{{"old.txt", "new.txt"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseGitStatus(tt.output)
+			if len(got.Added) != len(tt.want.Added) {
+				t.Errorf("parseGitStatus() added count = %v, want %v", len(got.Added), len(tt.want.Added))
+			}
+			if len(got.Modified) != len(tt.want.Modified) {
+				t.Errorf("parseGitStatus() modified count = %v, want %v", len(got.Modified), len(tt.want.Modified))
+			}
+			if len(got.Deleted) != len(tt.want.Deleted) {
+				t.Errorf("parseGitStatus() deleted count = %v, want %v", len(got.Deleted), len(tt.want.Deleted))
+			}
+			if !slices.Equal(got.Renamed, tt.want.Renamed) {
+				t.Errorf("parseGitStatus() renamed = %q, want %q", got.Renamed, tt.want.Renamed)
+			}
+
+			// Check individual files
+			for i, file := range tt.want.Added {
+				if i >= len(got.Added) {
+					t.Errorf("parseGitStatus() added[%d] missing, want %q", i, file)
+					break
+				}
+				if got.Added[i] != file {
+					t.Errorf("parseGitStatus() added[%d] = %q, want %q", i, got.Added[i], file)
+					break
+				}
+			}
+			for i, file := range tt.want.Modified {
+				if i >= len(got.Modified) {
+					t.Errorf("parseGitStatus() modified[%d] missing, want %q", i, file)
+					break
+				}
+				if got.Modified[i] != file {
+					t.Errorf("parseGitStatus() modified[%d] = %q, want %q", i, got.Modified[i], file)
+					break
+				}
+			}
+			for i, file := range tt.want.Deleted {
+				if i >= len(got.Deleted) {
+					t.Errorf("parseGitStatus() deleted[%d] missing, want %q", i, file)
+					break
+				}
+				if got.Deleted[i] != file {
+					t.Errorf("parseGitStatus() deleted[%d] = %q, want %q", i, got.Deleted[i], file)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestParseGitStatusSortsFiles(t *testing.T) {
+	output := "?? zeta.txt\x00 M src/b.go\x00A  alpha.txt\x00 D old/z.txt\x00R  b.txt\x00y.txt\x00 M src/a.go\x00D  old/a.txt\x00R  a.txt\x00x.txt\x00?? beta.txt\x00"
+
+	stats := parseGitStatus(output)
+
+	if want := []string{"alpha.txt", "beta.txt", "zeta.txt"}; !slices.Equal(stats.Added, want) {
+		t.Errorf("Added = %v, want %v", stats.Added, want)
+	}
+	if want := []string{"src/a.go", "src/b.go"}; !slices.Equal(stats.Modified, want) {
+		t.Errorf("Modified = %v, want %v", stats.Modified, want)
+	}
+	if want := []string{"old/a.txt", "old/z.txt"}; !slices.Equal(stats.Deleted, want) {
+		t.Errorf("Deleted = %v, want %v", stats.Deleted, want)
+	}
+	if want := [][2]string{{"x.txt", "a.txt"}, {"y.txt", "b.txt"}}; !slices.Equal(stats.Renamed, want) {
+		t.Errorf("Renamed = %v, want %v", stats.Renamed, want)
+	}
+
+	// The same changes reported in another order give the same message
+	reordered := "R  a.txt\x00x.txt\x00?? beta.txt\x00D  old/a.txt\x00 M src/a.go\x00A  alpha.txt\x00 D old/z.txt\x00?? zeta.txt\x00R  b.txt\x00y.txt\x00 M src/b.go\x00"
+	subject, body := generateCommitMessage(stats, 0)
+	otherSubject, otherBody := generateCommitMessage(parseGitStatus(reordered), 0)
+	if subject != otherSubject || body != otherBody {
+		t.Errorf("commit message depends on the status order:\n%s\n%s\nvs\n%s\n%s", subject, body, otherSubject, otherBody)
+	}
+}
+
+func TestGenerateCommitMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		stats       FileChangeStats
+		wantSubject string
+		wantBody    string
+	}{
+		{
+			name: "single added file",
+			stats: FileChangeStats{
+				Added:    []string{"file.txt"},
+				Modified: []string{},
+				Deleted:  []string{},
+			},
+			wantSubject: "Sync 1 file (1 added)",
+			wantBody:    "Added files:\n  + file.txt",
+		},
+		{
+			name: "single modified file",
+			stats: FileChangeStats{
+				Added:    []string{},
+				Modified: []string{"file.txt"},
+				Deleted:  []string{},
+			},
+			wantSubject: "Sync 1 file (1 modified)",
+			wantBody:    "Modified files:\n  ~ file.txt",
+		},
+		{
+			name: "single deleted file",
+			stats: FileChangeStats{
+				Added:    []string{},
+				Modified: []string{},
+				Deleted:  []string{"file.txt"},
+			},
+			wantSubject: "Sync 1 file (1 deleted)",
+			wantBody:    "Deleted files:\n  - file.txt",
+		},
+		{
+			name: "multiple files mixed",
+			stats: FileChangeStats{
+				Added:    []string{"new1.txt", "new2.txt"},
+				Modified: []string{"mod.txt"},
+				Deleted:  []string{"old.txt"},
+			},
+			wantSubject: "Sync 4 files (2 added, 1 modified, 1 deleted)",
+			wantBody:    "Added files:\n  + new1.txt\n  + new2.txt\n\nModified files:\n  ~ mod.txt\n\nDeleted files:\n  - old.txt",
+		},
+		{
+			name: "only modified files",
+			stats: FileChangeStats{
+				Added:    []string{},
+				Modified: []string{"file1.txt", "file2.txt"},
+				Deleted:  []string{},
+			},
+			wantSubject: "Sync 2 files (2 modified)",
+			wantBody:    "Modified files:\n  ~ file1.txt\n  ~ file2.txt",
+		},
+		{
+			name: "renamed file",
+			stats: FileChangeStats{
+				Modified: []string{"mod.txt"},
+				Renamed:  [][2]string{{"old.txt", "new.txt"}},
+			},
+			wantSubject: "Sync 2 files (1 modified, 1 renamed)",
+			wantBody:    "Modified files:\n  ~ mod.txt\n\nRenamed files:\n  old.txt -> new.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSubject, gotBody := generateCommitMessage(tt.stats, 0)
+			if gotSubject != tt.wantSubject {
+				t.Errorf("generateCommitMessage() subject = %v, want %v", gotSubject, tt.wantSubject)
+			}
+			if gotBody != tt.wantBody {
+				t.Errorf("generateCommitMessage() body = %v, want %v", gotBody, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCommitArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		amend  bool
+		want   []string
+	}{
+		{
+			name:   "unsigned",
+			config: Config{},
+			want:   []string{"commit", "-m", "msg"},
+		},
+		{
+			name:   "signed with default key",
+			config: Config{Sign: true},
+			want:   []string{"-c", "commit.gpgsign=true", "commit", "-S", "-m", "msg"},
+		},
+		{
+			name:   "signed with explicit key",
+			config: Config{Sign: true, SigningKey: "ABCD1234"},
+			want:   []string{"-c", "commit.gpgsign=true", "-c", "user.signingkey=ABCD1234", "commit", "-S", "-m", "msg"},
+		},
+		{
+			name:   "allow empty",
+			config: Config{CommitEmpty: true},
+			want:   []string{"commit", "--allow-empty", "-m", "msg"},
+		},
+		{
+			name:   "message file",
+			config: Config{CommitMessageFile: "/tmp/message.txt"},
+			want:   []string{"commit", "-F", "/tmp/message.txt"},
+		}, {
+			name:   "message file with prefix",
+			config: Config{CommitMessageFile: "/tmp/message.txt", MessagePrefix: "[skip ci]"},
+			want:   []string{"commit", "-m", "msg"},
+		},
+
+		{
+			name:   "no verify",
+			config: Config{NoVerify: true},
+			want:   []string{"commit", "--no-verify", "-m", "msg"},
+		},
+		{
+			name:   "sign off",
+			config: Config{Signoff: true},
+			want:   []string{"commit", "-s", "-m", "msg"},
+		},
+		{
+			name:   "signed and signed off",
+			config: Config{Sign: true, Signoff: true},
+			want:   []string{"-c", "commit.gpgsign=true", "commit", "-S", "-s", "-m", "msg"},
+		},
+		{
+			name:   "amend",
+			config: Config{CommitEmpty: true},
+			amend:  true,
+			want:   []string{"commit", "--amend", "--reset-author", "--allow-empty", "-m", "msg"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := commitArgs(tt.config, "msg", tt.amend)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("commitArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitCommandSetsDates(t *testing.T) {
+	tests := []struct {
+		name       string
+		commitDate string
+		want       string
+	}{
+		{name: "no date", commitDate: "", want: ""},
+		{name: "UTC", commitDate: "2024-01-02T15:04:05Z", want: "1704207845 +0000"},
+		{name: "with offset", commitDate: "2024-01-02T17:04:05+02:00", want: "1704207845 +0200"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GIT_AUTHOR_DATE", "")
+			t.Setenv("GIT_COMMITTER_DATE", "")
+			s := newTestSyncer(t, Config{Mode: ModePush, FolderPath: "/tmp/test", RepoURL: "https://github.com/user/repo.git", Branch: "main", CommitDate: tt.commitDate})
+			cmd := s.commitCommand(context.Background(), t.TempDir(), "msg", false)
+
+			env := map[string]string{}
+			for _, kv := range cmd.Env {
+				if key, value, ok := strings.Cut(kv, "="); ok {
+					env[key] = value
+				}
+			}
+			if env["GIT_AUTHOR_DATE"] != tt.want || env["GIT_COMMITTER_DATE"] != tt.want {
+				t.Errorf("GIT_AUTHOR_DATE = %q, GIT_COMMITTER_DATE = %q, want %q", env["GIT_AUTHOR_DATE"], env["GIT_COMMITTER_DATE"], tt.want)
+			}
+		})
+	}
+}
+
+func TestPerFileCommits(t *testing.T) {
+	stats := FileChangeStats{
+		Added:    []string{"a.txt", "b.txt"},
+		Modified: []string{"c.txt"},
+		Deleted:  []string{"d.txt"},
+		Renamed:  [][2]string{{"e.txt", "f.txt"}},
+	}
+
+	want := []fileCommit{
+		{Path: "a.txt", Message: "Sync: add a.txt"},
+		{Path: "b.txt", Message: "Sync: add b.txt"},
+		{Path: "c.txt", Message: "Sync: modify c.txt"},
+		{Path: "d.txt", Message: "Sync: delete d.txt"},
+		{Path: "f.txt", OldPath: "e.txt", Message: "Sync: rename e.txt -> f.txt"},
+	}
+
+	got := perFileCommits(stats)
+	if !slices.Equal(got, want) {
+		t.Errorf("perFileCommits() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGenerateCommitMessageSummaryLimit(t *testing.T) {
+	stats := FileChangeStats{
+		Added:    []string{"a1.txt", "a2.txt", "a3.txt", "a4.txt", "a5.txt"},
+		Modified: []string{"m1.txt", "m2.txt"},
+	}
+
+	subject, body := generateCommitMessage(stats, 2)
+
+	if want := "Sync 7 files (5 added, 2 modified)"; subject != want {
+		t.Errorf("subject = %q, want %q", subject, want)
+	}
+	wantBody := "Added files:\n" +
+		"  + a1.txt\n" +
+		"  + a2.txt\n" +
+		"  ... and 3 more\n" +
+		"\n" +
+		"Modified files:\n" +
+		"  ~ m1.txt\n" +
+		"  ~ m2.txt"
+	if body != wantBody {
+		t.Errorf("body = %q, want %q", body, wantBody)
+	}
+
+	if _, body := generateCommitMessage(stats, 0); strings.Contains(body, "more") || !strings.Contains(body, "a5.txt") {
+		t.Errorf("without a limit every file should be listed, got %q", body)
+	}
+}
+
+func TestExtensionCommits(t *testing.T) {
+	stats := FileChangeStats{
+		Added:    []string{"docs/a.md", "logo.PNG", "Makefile"},
+		Modified: []string{"README.md", ".gitignore"},
+		Deleted:  []string{"old.png"},
+		Renamed:  [][2]string{{"notes.txt", "docs/notes.md"}},
+	}
+
+	got := extensionCommits(stats, 0)
+
+	wantPaths := map[string][]string{
+		".md":  {"docs/a.md", "README.md", "docs/notes.md", "notes.txt"},
+		".png": {"logo.PNG", "old.png"},
+		"":     {"Makefile", ".gitignore"},
+	}
+	wantSubjects := []string{
+		"Sync .md files (3 changed)",
+		"Sync .png files (2 changed)",
+		"Sync files without extension (2 changed)",
+	}
+	if len(got) != len(wantSubjects) {
+		t.Fatalf("extensionCommits() returned %d commits, want %d: %+v", len(got), len(wantSubjects), got)
+	}
+	for i, gc := range got {
+		subject, body, _ := strings.Cut(gc.Message, "\n\n")
+		if subject != wantSubjects[i] {
+			t.Errorf("commit %d subject = %q, want %q", i, subject, wantSubjects[i])
+		}
+		if !slices.Equal(gc.Paths, wantPaths[gc.Ext]) {
+			t.Errorf("commit for %q paths = %q, want %q", gc.Ext, gc.Paths, wantPaths[gc.Ext])
+		}
+		for _, path := range gc.Paths {
+			if !strings.Contains(body, path) {
+				t.Errorf("commit for %q body %q does not list %s", gc.Ext, body, path)
+			}
+		}
+	}
+}
+
+func TestTouchesPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		stats    FileChangeStats
+		patterns []string
+		want     bool
+	}{
+		{name: "file in directory", stats: FileChangeStats{Modified: []string{"config/app.yaml"}}, patterns: []string{"config/"}, want: true},
+		{name: "directory without slash", stats: FileChangeStats{Added: []string{"config/nested/db.yaml"}}, patterns: []string{"config"}, want: true},
+		{name: "glob on file name", stats: FileChangeStats{Deleted: []string{"docs/old.yaml"}}, patterns: []string{"*.yaml"}, want: true},
+		{name: "anchored directory", stats: FileChangeStats{Modified: []string{"app/config/x.txt"}}, patterns: []string{"/config"}},
+		{name: "rename into directory", stats: FileChangeStats{Renamed: [][2]string{{"tmp/x.yaml", "config/x.yaml"}}}, patterns: []string{"config/"}, want: true},
+		{name: "change elsewhere", stats: FileChangeStats{Added: []string{"logs/today.log"}, Modified: []string{"README.md"}}, patterns: []string{"config/"}},
+		{name: "similar prefix", stats: FileChangeStats{Modified: []string{"configuration/a.txt"}}, patterns: []string{"config/"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stats.touchesPaths(tt.patterns); got != tt.want {
+				t.Errorf("touchesPaths(%q) = %v, want %v", tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrailer(t *testing.T) {
+	tests := []struct {
+		trailer   string
+		wantKey   string
+		wantValue string
+		wantErr   bool
+	}{
+		{trailer: "Synced-By=file-syncer", wantKey: "Synced-By", wantValue: "file-syncer"},
+		{trailer: " Source-Host = build-01 ", wantKey: "Source-Host", wantValue: "build-01"},
+		{trailer: "Ticket=ABC-1=2", wantKey: "Ticket", wantValue: "ABC-1=2"},
+		{trailer: "Sync-Time", wantKey: "Sync-Time"},
+		{trailer: "Synced-By", wantErr: true},
+		{trailer: "Synced-By=", wantErr: true},
+		{trailer: "Sync-Time=", wantErr: true},
+		{trailer: "=value", wantErr: true},
+		{trailer: "Synced By=me", wantErr: true},
+		{trailer: "Note=line one\nline two", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.trailer, func(t *testing.T) {
+			key, value, err := parseTrailer(tt.trailer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTrailer(%q) error = %v, wantErr %v", tt.trailer, err, tt.wantErr)
+			}
+			if key != tt.wantKey || value != tt.wantValue {
+				t.Errorf("parseTrailer(%q) = %q, %q, want %q, %q", tt.trailer, key, value, tt.wantKey, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestCommitCommandAppendsTrailers(t *testing.T) {
+	config := Config{
+		Mode:           ModePush,
+		FolderPath:     "/tmp/test",
+		RepoURL:        "https://github.com/user/repo.git",
+		Branch:         "main",
+		CommitTrailers: []string{"Synced-By=file-syncer", "Source-Host=build-01", "Sync-Time"},
+	}
+	s := newTestSyncer(t, config)
+
+	subject, body := generateCommitMessage(FileChangeStats{Modified: []string{"notes.txt"}}, 0)
+	before := time.Now().Truncate(time.Second)
+	cmd := s.commitCommand(context.Background(), t.TempDir(), subject+"\n\n"+body, false)
+	message := cmd.Args[len(cmd.Args)-1]
+
+	prefix := "Sync 1 file (1 modified)\n\nModified files:\n  ~ notes.txt\n\nSynced-By: file-syncer\nSource-Host: build-01\nSync-Time: "
+	if !strings.HasPrefix(message, prefix) {
+		t.Fatalf("commit message = %q, want prefix %q", message, prefix)
+	}
+	syncTime, err := time.Parse(time.RFC3339, strings.TrimPrefix(message, prefix))
+	if err != nil {
+		t.Fatalf("Sync-Time is not RFC 3339: %v", err)
+	}
+	if syncTime.Before(before) {
+		t.Errorf("Sync-Time = %v, want the commit time", syncTime)
+	}
+}
+
+func TestPrefixSubject(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		message string
+		want    string
+	}{
+		{name: "no prefix", message: "Sync 1 file (1 added)", want: "Sync 1 file (1 added)"},
+		{name: "generated subject", prefix: "[skip ci]", message: "Sync 1 file (1 added)\n\nAdded files:\n  + a.txt", want: "[skip ci] Sync 1 file (1 added)\n\nAdded files:\n  + a.txt"},
+		{name: "custom message", prefix: "chore:", message: "Update configuration\n", want: "chore: Update configuration\n"},
+		{name: "extra spaces", prefix: "[skip ci]  ", message: "  Sync 1 file (1 added)", want: "[skip ci] Sync 1 file (1 added)"},
+		{name: "leading blank lines", prefix: "[skip ci]", message: "\n\nRelease notes\n\nDetails", want: "[skip ci] Release notes\n\nDetails"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prefixSubject(tt.prefix, tt.message); got != tt.want {
+				t.Errorf("prefixSubject(%q, %q) = %q, want %q", tt.prefix, tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitCommandPrefixesSubject(t *testing.T) {
+	config := Config{
+		Mode:           ModePush,
+		FolderPath:     "/tmp/test",
+		RepoURL:        "https://github.com/user/repo.git",
+		Branch:         "main",
+		MessagePrefix:  "[skip ci]",
+		CommitTrailers: []string{"Synced-By=file-syncer"},
+	}
+	s := newTestSyncer(t, config)
+
+	subject, body := generateCommitMessage(FileChangeStats{Added: []string{"a.txt"}}, 0)
+	cmd := s.commitCommand(context.Background(), t.TempDir(), subject+"\n\n"+body, false)
+	want := "[skip ci] Sync 1 file (1 added)\n\nAdded files:\n  + a.txt\n\nSynced-By: file-syncer"
+	if message := cmd.Args[len(cmd.Args)-1]; message != want {
+		t.Errorf("commit message = %q, want %q", message, want)
+	}
+}
+
+func TestAppendTrailers(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		trailers string
+		want     string
+	}{
+		{name: "no trailers", message: "Subject\n\nBody", want: "Subject\n\nBody"},
+		{name: "subject only", message: "Sync heartbeat", trailers: "A: 1", want: "Sync heartbeat\n\nA: 1"},
+		{name: "trailing newline", message: "Subject\n\nBody\n", trailers: "A: 1\nB: 2", want: "Subject\n\nBody\n\nA: 1\nB: 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appendTrailers(tt.message, tt.trailers); got != tt.want {
+				t.Errorf("appendTrailers() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,1066 @@
+package syncer
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Operation modes.
+const (
+	ModePush = "push"
+	ModePull = "pull"
+	// ModeSync merges changes in both directions.
+	ModeSync = "sync"
+)
+
+// Pull strategies.
+const (
+	// StrategyCopy walks the clone and copies it file by file.
+	StrategyCopy = "copy"
+	// StrategyArchive extracts the output of git archive.
+	StrategyArchive = "archive"
+)
+
+// GroupByExt commits the changes of each file extension separately
+// (-group-by ext).
+const GroupByExt = "ext"
+
+// Actions for files larger than Config.MaxFileSize.
+const (
+	OversizeSkip = "skip"
+	OversizeFail = "fail"
+)
+
+// What the CLI reports for a push or sync without changes (-on-no-changes).
+const (
+	// NoChangesExitCode exits with a distinct code, 2.
+	NoChangesExitCode = "exit-code"
+	// NoChangesExit0 exits with 0, like a push that pushed changes.
+	NoChangesExit0 = "exit0"
+)
+
+// Config holds the settings for a sync run.
+type Config struct {
+	Mode       string
+	FolderPath string
+	RepoURL    string
+	// RepoFile names a file holding the repository URL, which keeps a token
+	// embedded in the URL out of the process arguments
+	RepoFile string
+	// Branch is the branch to push to or pull from; BranchAuto picks the
+	// remote's default branch at the start of each run
+	Branch string
+	// Branches are further branches that push syncs the same content to,
+	// one after the other, from the clone of Branch (-branch repeated)
+	Branches []string
+	// BranchTemplate renders the name of a new branch per push from
+	// {{.Date}}, {{.Time}}, {{.Base}} and {{.RunID}}. It is created from
+	// Branch, which becomes the base branch, and pushed instead of it.
+	BranchTemplate string
+	SSHKeyPath     string
+	// SSHKeyData is the content of an SSH private key, written to a
+	// temporary file for the duration of a run
+	SSHKeyData string
+	Include    []string
+	Exclude    []string
+	SchemaPath string
+	// PlanOutPath writes a plan of the push instead of committing it
+	PlanOutPath string
+	// ApplyPlanPath pushes exactly the operations of a previously saved plan
+	ApplyPlanPath string
+	KeepEmptyDirs bool
+	// RateLimit is the human-readable copy throughput limit, e.g. "10MB"
+	RateLimit string
+	// CopyBuffer is the human-readable size of the buffer used to copy
+	// each file, e.g. "1MB". Empty uses io.Copy's default.
+	CopyBuffer string
+	// Strict fails the sync on FIFOs, sockets and devices, which are
+	// otherwise skipped with a warning
+	Strict bool
+	// ForceOverwrite replaces read-only files in the folder on pull and
+	// sync, keeping them read-only, instead of failing
+	ForceOverwrite bool
+	// CopyRetries is how many times a file copy that failed with a
+	// transient error such as EIO is retried. Zero fails at once.
+	CopyRetries int
+	// Sign GPG-signs the sync commit
+	Sign bool
+	// SigningKey selects the key used when Sign is set (optional)
+	SigningKey string
+	// Signoff adds a Signed-off-by trailer with the committer identity to
+	// the sync commits, as required by the Developer Certificate of Origin
+	Signoff bool
+	// Tag is an annotated tag created on the pushed commit
+	Tag string
+	// TagTemplate renders the tag name from {{.Date}} and {{.Commit}}
+	TagTemplate string
+	// ForceTag replaces an existing tag with the same name
+	ForceTag bool
+	// CommitPerFile commits every changed file separately
+	CommitPerFile bool
+	// Snapshot copies the folder to a staging directory first and pushes
+	// from that copy, so changes made during the run are not committed
+	Snapshot bool
+	// FromStdin pushes only the files whose paths, relative to FolderPath,
+	// are read line by line from stdin instead of walking the folder
+	FromStdin bool
+	// SquashAfter squashes the last SquashAfter commits into one when they
+	// are all sync commits made since the previous squash, then pushes with
+	// --force-with-lease. Zero disables squashing.
+	SquashAfter int
+	// Amend replaces the branch tip with the sync commit instead of adding
+	// a new one when the tip has the same author email, then pushes with
+	// --force-with-lease, so the branch holds a single snapshot commit
+	Amend bool
+	// GroupBy splits the changes into one commit per group. The only
+	// grouping is GroupByExt; empty makes a single commit.
+	GroupBy string
+	// Force overwrites divergent remote history using --force-with-lease
+	Force bool
+	// ForceUnsafe overwrites remote history unconditionally using --force
+	ForceUnsafe bool
+	// MirrorRemote is a second repository that every pushed branch and tag
+	// is also pushed to (-mirror-remote). A failure there is only logged,
+	// unless MirrorRequired is set.
+	MirrorRemote   string
+	MirrorRequired bool
+	// WorkDir is a persistent checkout reused across pushes or pulls
+	// instead of a fresh temporary clone
+	WorkDir string
+	// KeepTemp leaves the temporary clone in place after the run so it can
+	// be inspected
+	KeepTemp bool
+	// SkipUnchanged avoids rewriting destination files whose content
+	// already matches the repository during pull
+	SkipUnchanged bool
+	// HashCache keeps the digests of destination files in a cache file in
+	// the destination, so that SkipUnchanged only rehashes files whose size
+	// or modification time changed since the last pull (-hash-cache)
+	HashCache bool
+	// ChecksumAlgo is the hash used to compare file content and recorded
+	// in plans and sync manifests: ChecksumSHA256, ChecksumSHA512,
+	// ChecksumSHA1 or ChecksumBLAKE2b. Empty means ChecksumSHA256.
+	ChecksumAlgo string
+	// ScanConflictMarkers fails a pull whose files contain merge conflict
+	// markers, before any of them is synced (-scan-conflict-markers)
+	ScanConflictMarkers bool
+	// ArchiveOut is a path that a pull also writes the pulled files to, as
+	// a zstd-compressed tar archive (-archive-out)
+	ArchiveOut string
+	// RenderTemplates renders pulled files ending in TemplateSuffix with
+	// text/template, using the environment variables as data, and writes
+	// them without the suffix (-render-templates). TemplateSuffix defaults
+	// to DefaultTemplateSuffix.
+	RenderTemplates bool
+	TemplateSuffix  string
+	// InsecureHTTP allows plain http:// repository URLs
+	InsecureHTTP bool
+	// NoModeChanges makes git ignore executable-bit differences so that
+	// mode-only changes are never committed
+	NoModeChanges bool
+	// ExportIgnore skips paths marked export-ignore in the source folder's
+	// .gitattributes
+	ExportIgnore bool
+	// MaxFileSize is the human-readable size limit for pushed files, e.g. "100MB"
+	MaxFileSize string
+	// OnOversize is OversizeSkip or OversizeFail and decides what happens
+	// to files above MaxFileSize. Empty means OversizeSkip.
+	OnOversize string
+	// MaxDepth limits how many directory levels below the folder are
+	// synced; 0 syncs only the files directly in it. Nil means no limit.
+	MaxDepth *int
+	// Sparse limits pull to these directories of the repository, which is
+	// cloned without file contents and sparsely checked out (-sparse)
+	Sparse []string
+	// Depth makes pull clone only the last Depth commits. Zero clones the
+	// full history (-depth).
+	Depth int
+	// Jobs is the number of files hashed concurrently when building a plan.
+	// Zero uses one worker per CPU.
+	Jobs int
+	// PreHook is a shell command run in the folder before a push
+	PreHook string
+	// PostHook is a shell command run in the folder after a successful pull
+	PostHook string
+	// Progress logs periodic progress while files are copied
+	Progress bool
+	// CommitEmpty creates and pushes an empty heartbeat commit when there
+	// are no changes
+	CommitEmpty bool
+	// UseAgent authenticates SSH with the keys in the running ssh-agent
+	// instead of SSHKeyPath, e.g. for passphrase-protected keys
+	UseAgent bool
+	// UseNetrc authenticates HTTPS with the credentials in ~/.netrc and
+	// disables git's interactive credential prompt
+	UseNetrc bool
+	// Proxy is the URL of an HTTP or SOCKS proxy that git connects
+	// through, e.g. socks5://127.0.0.1:1080 (-proxy)
+	Proxy string
+	// RespectRepoGitignore skips source files ignored by the cloned
+	// repository's .gitignore
+	RespectRepoGitignore bool
+	// OnlyTracked commits only changes to files the repository already
+	// tracks, leaving new files unstaged (-only-tracked)
+	OnlyTracked bool
+	// OnNoChanges is NoChangesExitCode or NoChangesExit0 and decides the
+	// exit code of the CLI when a push or sync finds nothing to commit.
+	// Empty means NoChangesExitCode. Syncer methods return ErrNoChanges
+	// either way.
+	OnNoChanges string
+	// CommitMessageFile holds the full commit message (subject and body)
+	// and replaces the generated one
+	CommitMessageFile string
+	// MessagePrefix is prepended to the subject of every commit, generated
+	// or from CommitMessageFile, e.g. "[skip ci]" (-message-prefix)
+	MessagePrefix string
+	// AllowSingleFile lets FolderPath name a single file, which is pushed
+	// into the repository root
+	AllowSingleFile bool
+	// Clean removes destination files that are not in the repository,
+	// making pull an exact mirror
+	Clean bool
+	// LFS fetches Git LFS content on pull and uploads it on push when the
+	// repository's .gitattributes uses the LFS filter. Requires git-lfs.
+	LFS bool
+	// StrictLFS fails a push that would commit Git LFS pointer files,
+	// which is otherwise only logged as a warning
+	StrictLFS bool
+	// MaxChanges fails a push that would change more files than this, to
+	// catch mass changes such as a push from the wrong folder. Zero means
+	// no limit.
+	MaxChanges int
+	// ForceLarge pushes even when MaxChanges is exceeded
+	ForceLarge bool
+	// CommitDate is an RFC 3339 timestamp used as the author and committer
+	// date of sync commits
+	CommitDate string
+	// CommitTrailers are "key=value" trailers appended to every sync
+	// commit message, in order. A bare Sync-Time is filled with the time of
+	// the commit.
+	CommitTrailers []string
+	// SummaryLimit caps the number of files listed per category in the
+	// generated commit message body. Zero lists every file.
+	SummaryLimit int
+	// CommitPaths limits commits to pushes that change at least one path
+	// matching these glob patterns, or lying in a matching directory.
+	// Other changes alone count as no changes.
+	CommitPaths []string
+	// LongPaths lifts the 260-character MAX_PATH limit on Windows by
+	// writing files through \\?\ paths. It has no effect elsewhere.
+	LongPaths bool
+	// RecurseSubmodules checks out the repository's submodules so that
+	// pull delivers their files too
+	RecurseSubmodules bool
+	// PreserveOwner gives pulled files the owner and group of the
+	// repository's files. It requires root.
+	PreserveOwner bool
+	// PreserveXattrs copies the extended attributes of pulled files
+	// (Linux only)
+	PreserveXattrs bool
+	// RunID identifies the run in the RunResult and the metrics. The CLI
+	// generates one per run and adds it to every log line as run_id.
+	RunID string
+	// LockDir holds the per-folder lock files that keep concurrent runs
+	// apart. Empty uses a directory under os.TempDir().
+	LockDir string
+	// LockTimeout is how long to wait for another run on the same folder
+	// to finish. Zero fails immediately.
+	LockTimeout time.Duration
+	// Interval makes pull run repeatedly, waiting this long between cycles,
+	// until interrupted (-interval). Zero pulls once.
+	Interval time.Duration
+	// NoVerify skips git hooks: commits and pushes get --no-verify, and
+	// every git command runs with core.hooksPath pointing nowhere (-no-verify)
+	NoVerify bool
+	// DryRun stops a push before committing and reports what would change
+	DryRun bool
+	// NoCommit stops a push after staging the changes in WorkDir, leaving
+	// them there to be inspected and committed by hand
+	NoCommit bool
+	// ListChanges stops a push once the changes are staged in the clone,
+	// so that the caller can report RunResult.Stats (-list-changes)
+	ListChanges bool
+	// ValidateOnly checks the configuration and repository access with
+	// Syncer.Check instead of syncing (-validate-only)
+	ValidateOnly bool
+	// Diff prints the content changes of modified files during a dry run
+	Diff bool
+	// DiffContext is the number of unchanged lines shown around each
+	// change by Diff. Nil means DefaultDiffContext.
+	DiffContext *int
+	// MetricsFile receives Prometheus metrics about each run, e.g. for the
+	// node_exporter textfile collector
+	MetricsFile string
+	// Strategy is StrategyCopy or StrategyArchive and decides how pull
+	// writes the repository content. Empty means StrategyCopy.
+	Strategy string
+	// Ref is a tag, branch or commit to pull instead of the tip of Branch.
+	// It is checked out as a detached HEAD; Branch must then be empty.
+	Ref string
+}
+
+// Validate checks that the config is complete and consistent.
+func (c Config) Validate() error {
+	if c.Mode != ModePush && c.Mode != ModePull && c.Mode != ModeSync {
+		return fmt.Errorf("mode must be 'push', 'pull' or 'sync'")
+	}
+
+	if c.FolderPath == "" {
+		return fmt.Errorf("folder path is required")
+	}
+
+	repoURL := c.RepoURL
+	if c.RepoFile != "" {
+		if c.RepoURL != "" {
+			return fmt.Errorf("-repo and -repo-file cannot be used together")
+		}
+		url, err := readRepoFile(c.RepoFile)
+		if err != nil {
+			return err
+		}
+		repoURL = url
+	}
+	if repoURL == "" {
+		return fmt.Errorf("repository URL is required")
+	}
+	repoURL, err := normalizeRepoURL(repoURL)
+	if err != nil {
+		return err
+	}
+
+	if c.MirrorRemote != "" {
+		if c.Mode != ModePush {
+			return fmt.Errorf("-mirror-remote is only supported in push mode")
+		}
+		mirrorURL, err := normalizeRepoURL(c.MirrorRemote)
+		if err != nil {
+			return fmt.Errorf("invalid -mirror-remote: %w", err)
+		}
+		if mirrorURL == repoURL {
+			return fmt.Errorf("-mirror-remote must differ from the repository URL")
+		}
+		if isPlainHTTP(mirrorURL) && !c.InsecureHTTP {
+			return fmt.Errorf("mirror URL uses unencrypted http://, pass -insecure-http to allow it")
+		}
+	} else if c.MirrorRequired {
+		return fmt.Errorf("-mirror-required requires -mirror-remote")
+	}
+
+	if isPlainHTTP(repoURL) && !c.InsecureHTTP {
+		return fmt.Errorf("repository URL uses unencrypted http://, pass -insecure-http to allow it")
+	}
+
+	if c.UseNetrc && !isHTTPURL(repoURL) {
+		return fmt.Errorf("-use-netrc requires an http:// or https:// repository URL")
+	}
+
+	if c.Proxy != "" {
+		if err := validateProxyURL(c.Proxy); err != nil {
+			return err
+		}
+	}
+
+	if c.SSHKeyData != "" && c.SSHKeyPath != "" {
+		return fmt.Errorf("-ssh-key-data and -ssh-key cannot be used together")
+	}
+
+	if c.UseAgent {
+		if c.SSHKeyPath != "" || c.SSHKeyData != "" {
+			return fmt.Errorf("-use-agent cannot be used with -ssh-key or -ssh-key-data")
+		}
+		if os.Getenv("SSH_AUTH_SOCK") == "" {
+			return fmt.Errorf("-use-agent requires a running ssh-agent, but SSH_AUTH_SOCK is not set")
+		}
+	}
+
+	if c.RateLimit != "" {
+		limit, err := parseSize(c.RateLimit)
+		if err != nil {
+			return fmt.Errorf("invalid rate limit: %w", err)
+		}
+		if limit == 0 {
+			return fmt.Errorf("rate limit must be greater than zero")
+		}
+	}
+
+	if c.Branch != "" && !isValidBranchName(c.Branch) {
+		return fmt.Errorf("invalid branch name %q: branch names cannot contain spaces, '..' or any of ~^:?*[\\, and cannot start with '-' or '/' (see git check-ref-format)", c.Branch)
+	}
+
+	if len(c.Branches) > 0 {
+		if c.Mode != ModePush {
+			return fmt.Errorf("-branch can only be repeated in push mode")
+		}
+		seen := map[string]bool{c.Branch: true}
+		for _, branch := range c.Branches {
+			if !isValidBranchName(branch) {
+				return fmt.Errorf("invalid branch name %q", branch)
+			}
+			if branch == BranchAuto {
+				return fmt.Errorf("-branch %s can only be given once, as the first branch", BranchAuto)
+			}
+			if seen[branch] {
+				return fmt.Errorf("branch %s is listed more than once", branch)
+			}
+			seen[branch] = true
+		}
+		if c.WorkDir != "" || c.PlanOutPath != "" || c.ApplyPlanPath != "" {
+			return fmt.Errorf("pushing to several branches cannot be combined with -work-dir, -plan-out or -apply-plan")
+		}
+		if c.Tag != "" || c.TagTemplate != "" {
+			return fmt.Errorf("pushing to several branches cannot be combined with -tag or -tag-template")
+		}
+	}
+
+	if c.BranchTemplate != "" {
+		if c.Mode != ModePush {
+			return fmt.Errorf("-branch-template is only supported in push mode")
+		}
+		if _, err := parseBranchTemplate(c.BranchTemplate); err != nil {
+			return err
+		}
+		if len(c.Branches) > 0 || c.WorkDir != "" {
+			return fmt.Errorf("-branch-template cannot be combined with a repeated -branch or -work-dir")
+		}
+		if c.SquashAfter != 0 || c.Amend {
+			return fmt.Errorf("-branch-template cannot be combined with -squash-after or -amend, a new branch has no sync commits to rewrite")
+		}
+	}
+
+	if c.Ref != "" {
+		if c.Mode != ModePull {
+			return fmt.Errorf("-ref is only supported in pull mode")
+		}
+		if c.Branch != "" {
+			return fmt.Errorf("-ref and -branch cannot be used together")
+		}
+		if c.WorkDir != "" {
+			return fmt.Errorf("-ref and -work-dir cannot be used together")
+		}
+	}
+
+	if c.CopyBuffer != "" {
+		size, err := parseSize(c.CopyBuffer)
+		if err != nil {
+			return fmt.Errorf("invalid copy buffer size: %w", err)
+		}
+		if size == 0 {
+			return fmt.Errorf("copy buffer size must be greater than zero")
+		}
+	}
+
+	if c.CopyRetries < 0 {
+		return fmt.Errorf("-copy-retries must not be negative")
+	}
+
+	if c.WorkDir != "" && c.Mode == ModeSync {
+		return fmt.Errorf("-work-dir is not supported in sync mode")
+	}
+
+	if c.KeepTemp && c.WorkDir != "" {
+		return fmt.Errorf("-keep-temp and -work-dir cannot be used together, the work directory is always kept")
+	}
+
+	if c.MaxFileSize != "" {
+		if c.Mode != ModePush {
+			return fmt.Errorf("-max-file-size is only supported in push mode")
+		}
+		limit, err := parseSize(c.MaxFileSize)
+		if err != nil {
+			return fmt.Errorf("invalid max file size: %w", err)
+		}
+		if limit == 0 {
+			return fmt.Errorf("max file size must be greater than zero")
+		}
+	}
+
+	if c.OnOversize != "" && c.OnOversize != OversizeSkip && c.OnOversize != OversizeFail {
+		return fmt.Errorf("-on-oversize must be either '%s' or '%s'", OversizeSkip, OversizeFail)
+	}
+
+	if c.RespectRepoGitignore && c.Mode != ModePush {
+		return fmt.Errorf("-respect-repo-gitignore is only supported in push mode")
+	}
+
+	if c.OnlyTracked && c.Mode != ModePush {
+		return fmt.Errorf("-only-tracked is only supported in push mode")
+	}
+
+	if c.CommitEmpty && c.Mode != ModePush {
+		return fmt.Errorf("-commit-empty is only supported in push mode")
+	}
+
+	if c.OnNoChanges != "" && c.OnNoChanges != NoChangesExitCode && c.OnNoChanges != NoChangesExit0 {
+		return fmt.Errorf("-on-no-changes must be either '%s' or '%s'", NoChangesExit0, NoChangesExitCode)
+	}
+
+	if c.CommitMessageFile != "" {
+		if c.Mode != ModePush {
+			return fmt.Errorf("-commit-message-file is only supported in push mode")
+		}
+		if c.CommitPerFile {
+			return fmt.Errorf("-commit-message-file and -commit-per-file cannot be used together")
+		}
+		if c.GroupBy != "" {
+			return fmt.Errorf("-commit-message-file and -group-by cannot be used together")
+		}
+		data, err := os.ReadFile(c.CommitMessageFile)
+		if err != nil {
+			return fmt.Errorf("failed to read commit message file: %w", err)
+		}
+		if strings.TrimSpace(string(data)) == "" {
+			return fmt.Errorf("commit message file %s is empty", c.CommitMessageFile)
+		}
+	}
+
+	if c.MessagePrefix != "" {
+		if c.Mode == ModePull {
+			return fmt.Errorf("-message-prefix is not supported in pull mode")
+		}
+		if strings.ContainsAny(c.MessagePrefix, "\r\n") {
+			return fmt.Errorf("-message-prefix must be a single line")
+		}
+		if strings.TrimSpace(c.MessagePrefix) == "" {
+			return fmt.Errorf("-message-prefix must not be blank")
+		}
+	}
+
+	if len(c.CommitTrailers) > 0 {
+		if c.Mode == ModePull {
+			return fmt.Errorf("-commit-trailer is not supported in pull mode")
+		}
+		if c.CommitMessageFile != "" {
+			return fmt.Errorf("-commit-trailer and -commit-message-file cannot be used together")
+		}
+		for _, trailer := range c.CommitTrailers {
+			if _, _, err := parseTrailer(trailer); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.SummaryLimit < 0 {
+		return fmt.Errorf("-summary-limit must not be negative")
+	}
+
+	if len(c.CommitPaths) > 0 && c.Mode != ModePush {
+		return fmt.Errorf("-commit-paths is only supported in push mode")
+	}
+
+	if c.CommitDate != "" {
+		if c.Mode != ModePush {
+			return fmt.Errorf("-commit-date is only supported in push mode")
+		}
+		if _, err := time.Parse(time.RFC3339, c.CommitDate); err != nil {
+			return fmt.Errorf("invalid commit date, expected RFC 3339 such as 2024-01-02T15:04:05Z: %w", err)
+		}
+	}
+
+	if c.SquashAfter != 0 {
+		if c.Mode != ModePush {
+			return fmt.Errorf("-squash-after is only supported in push mode")
+		}
+		if c.SquashAfter < 2 {
+			return fmt.Errorf("-squash-after must be at least 2")
+		}
+		if c.CommitMessageFile != "" {
+			return fmt.Errorf("-squash-after and -commit-message-file cannot be used together, custom messages are not recognized as sync commits")
+		}
+	}
+
+	if c.Amend {
+		if c.Mode != ModePush {
+			return fmt.Errorf("-amend is only supported in push mode")
+		}
+		if c.CommitPerFile || c.GroupBy != "" || c.SquashAfter != 0 {
+			return fmt.Errorf("-amend cannot be used with -commit-per-file, -group-by or -squash-after, which make several commits")
+		}
+	}
+
+	if c.Snapshot && c.Mode != ModePush {
+		return fmt.Errorf("-snapshot is only supported in push mode")
+	}
+
+	if c.FromStdin {
+		if c.Mode != ModePush {
+			return fmt.Errorf("-from-stdin is only supported in push mode")
+		}
+		if c.AllowSingleFile {
+			return fmt.Errorf("-from-stdin and -allow-single-file cannot be used together")
+		}
+		if c.ApplyPlanPath != "" || c.PlanOutPath != "" {
+			return fmt.Errorf("-from-stdin cannot be used with -plan-out or -apply-plan")
+		}
+	}
+
+	if c.GroupBy != "" {
+		if c.GroupBy != GroupByExt {
+			return fmt.Errorf("-group-by must be '%s'", GroupByExt)
+		}
+		if c.Mode != ModePush {
+			return fmt.Errorf("-group-by is only supported in push mode")
+		}
+		if c.CommitPerFile {
+			return fmt.Errorf("-group-by and -commit-per-file cannot be used together")
+		}
+	}
+
+	if c.Strategy != "" && c.Strategy != StrategyCopy && c.Strategy != StrategyArchive {
+		return fmt.Errorf("-strategy must be either '%s' or '%s'", StrategyCopy, StrategyArchive)
+	}
+
+	if c.StrictLFS && c.Mode != ModePush {
+		return fmt.Errorf("-strict-lfs is only supported in push mode")
+	}
+
+	if c.MaxChanges < 0 {
+		return fmt.Errorf("-max-changes must not be negative")
+	}
+	if c.MaxChanges > 0 && c.Mode != ModePush {
+		return fmt.Errorf("-max-changes is only supported in push mode")
+	}
+	if c.ForceLarge && c.MaxChanges == 0 {
+		return fmt.Errorf("-force-large requires -max-changes")
+	}
+
+	if c.Strategy == StrategyArchive {
+		if c.Mode != ModePull {
+			return fmt.Errorf("-strategy archive is only supported in pull mode")
+		}
+		if c.LFS {
+			return fmt.Errorf("-strategy archive and -lfs cannot be used together")
+		}
+	}
+
+	if c.DryRun && c.Mode != ModePush {
+		return fmt.Errorf("-dry-run is only supported in push mode")
+	}
+
+	if c.Diff && !c.DryRun {
+		return fmt.Errorf("-diff requires -dry-run")
+	}
+
+	if c.DiffContext != nil {
+		if !c.Diff {
+			return fmt.Errorf("-diff-context requires -diff")
+		}
+		if *c.DiffContext < 0 {
+			return fmt.Errorf("-diff-context must not be negative")
+		}
+	}
+
+	if c.NoCommit {
+		if c.Mode != ModePush {
+			return fmt.Errorf("-no-commit is only supported in push mode")
+		}
+		if c.WorkDir == "" {
+			return fmt.Errorf("-no-commit requires -work-dir, where the staged changes are left")
+		}
+		if c.DryRun || c.PlanOutPath != "" {
+			return fmt.Errorf("-no-commit cannot be combined with -dry-run or -plan-out, which already stop before committing")
+		}
+		if c.Tag != "" || c.TagTemplate != "" || c.MirrorRemote != "" || c.Amend || c.SquashAfter != 0 || c.CommitPerFile || c.GroupBy != "" {
+			return fmt.Errorf("-no-commit cannot be combined with options that shape the commit or push, such as -tag, -mirror-remote, -amend, -squash-after, -commit-per-file or -group-by")
+		}
+	}
+
+	if c.ListChanges {
+		if c.Mode != ModePush {
+			return fmt.Errorf("-list-changes is only supported in push mode")
+		}
+		if c.DryRun || c.NoCommit || c.PlanOutPath != "" || c.ApplyPlanPath != "" || len(c.Branches) > 0 || c.BranchTemplate != "" {
+			return fmt.Errorf("-list-changes cannot be combined with -dry-run, -no-commit, -plan-out, -apply-plan, a repeated -branch or -branch-template")
+		}
+	}
+
+	if c.AllowSingleFile {
+		if c.Mode != ModePush {
+			return fmt.Errorf("-allow-single-file is only supported in push mode")
+		}
+		if c.PlanOutPath != "" || c.ApplyPlanPath != "" {
+			return fmt.Errorf("-allow-single-file cannot be used with -plan-out or -apply-plan")
+		}
+	}
+
+	if c.PreHook != "" && c.Mode != ModePush {
+		return fmt.Errorf("-pre-hook is only supported in push mode")
+	}
+
+	if c.RecurseSubmodules {
+		if c.Mode != ModePull {
+			return fmt.Errorf("-recurse-submodules is only supported in pull mode")
+		}
+		if c.Strategy == StrategyArchive {
+			return fmt.Errorf("-recurse-submodules cannot be used with -strategy archive, git archive leaves submodules out")
+		}
+	}
+
+	if c.PreserveOwner || c.PreserveXattrs {
+		if c.Mode != ModePull {
+			return fmt.Errorf("-preserve-owner and -preserve-xattrs are only supported in pull mode")
+		}
+		if c.Strategy == StrategyArchive {
+			return fmt.Errorf("-preserve-owner and -preserve-xattrs cannot be used with -strategy archive")
+		}
+	}
+
+	if _, ok := checksumAlgos[checksumAlgo(c.ChecksumAlgo)]; !ok {
+		return fmt.Errorf("-checksum-algo must be one of '%s', '%s', '%s' or '%s'", ChecksumSHA256, ChecksumSHA512, ChecksumSHA1, ChecksumBLAKE2b)
+	}
+
+	if c.HashCache {
+		if c.Mode != ModePull {
+			return fmt.Errorf("-hash-cache is only supported in pull mode")
+		}
+		if !c.SkipUnchanged {
+			return fmt.Errorf("-hash-cache requires -skip-unchanged")
+		}
+		if c.Strategy == StrategyArchive {
+			return fmt.Errorf("-hash-cache cannot be used with -strategy archive")
+		}
+	}
+
+	if c.RenderTemplates {
+		if c.Mode != ModePull {
+			return fmt.Errorf("-render-templates is only supported in pull mode")
+		}
+		if c.Strategy == StrategyArchive {
+			return fmt.Errorf("-render-templates cannot be used with -strategy archive")
+		}
+	} else if c.TemplateSuffix != "" {
+		return fmt.Errorf("-template-suffix requires -render-templates")
+	}
+
+	if c.Interval < 0 {
+		return fmt.Errorf("-interval must not be negative")
+	}
+	if c.Interval > 0 && c.Mode != ModePull {
+		return fmt.Errorf("-interval is only supported in pull mode")
+	}
+	if c.Interval > 0 && c.ValidateOnly {
+		return fmt.Errorf("-interval and -validate-only cannot be used together")
+	}
+
+	if c.ScanConflictMarkers && c.Mode != ModePull {
+		return fmt.Errorf("-scan-conflict-markers is only supported in pull mode")
+	}
+
+	if c.ArchiveOut != "" && c.Mode != ModePull {
+		return fmt.Errorf("-archive-out is only supported in pull mode")
+	}
+
+	if c.Clean && c.Mode != ModePull {
+		return fmt.Errorf("-clean is only supported in pull mode")
+	}
+
+	if c.PostHook != "" && c.Mode != ModePull {
+		return fmt.Errorf("-post-hook is only supported in pull mode")
+	}
+
+	if c.LockTimeout < 0 {
+		return fmt.Errorf("-lock-timeout must not be negative")
+	}
+
+	if len(c.Sparse) > 0 {
+		if c.Mode != ModePull {
+			return fmt.Errorf("-sparse is only supported in pull mode")
+		}
+		for _, dir := range c.Sparse {
+			if cleanSparsePath(dir) == "" {
+				return fmt.Errorf("invalid -sparse path %q: must be a directory inside the repository", dir)
+			}
+		}
+		if c.WorkDir != "" || c.Ref != "" {
+			return fmt.Errorf("-sparse cannot be combined with -work-dir or -ref")
+		}
+		if c.Strategy == StrategyArchive {
+			return fmt.Errorf("-sparse cannot be used with -strategy archive")
+		}
+	}
+
+	if c.Depth != 0 {
+		if c.Depth < 0 {
+			return fmt.Errorf("-depth must be at least 1")
+		}
+		if c.Mode != ModePull {
+			return fmt.Errorf("-depth is only supported in pull mode")
+		}
+		if c.WorkDir != "" || c.Ref != "" {
+			return fmt.Errorf("-depth cannot be combined with -work-dir or -ref")
+		}
+	}
+
+	if c.MaxDepth != nil && *c.MaxDepth < 0 {
+		return fmt.Errorf("-max-depth must not be negative")
+	}
+
+	if c.Jobs < 0 {
+		return fmt.Errorf("-jobs must not be negative")
+	}
+
+	if c.ExportIgnore && c.Mode != ModePush {
+		return fmt.Errorf("-export-ignore is only supported in push mode")
+	}
+
+	if c.NoModeChanges && c.Mode != ModePush {
+		return fmt.Errorf("-no-mode-changes is only supported in push mode")
+	}
+
+	if c.Force && c.ForceUnsafe {
+		return fmt.Errorf("-force and -force-unsafe cannot be used together")
+	}
+
+	if c.SigningKey != "" && !c.Sign {
+		return fmt.Errorf("-signing-key requires -sign")
+	}
+
+	if c.ForceOverwrite && c.Mode == ModePush {
+		return fmt.Errorf("-force-overwrite is not supported in push mode, which writes into a fresh clone")
+	}
+
+	if c.Signoff && c.Mode == ModePull {
+		return fmt.Errorf("-signoff is not supported in pull mode, which makes no commits")
+	}
+
+	if c.Tag != "" || c.TagTemplate != "" {
+		if c.Mode != ModePush {
+			return fmt.Errorf("-tag and -tag-template are only supported in push mode")
+		}
+		if c.Tag != "" && c.TagTemplate != "" {
+			return fmt.Errorf("-tag and -tag-template cannot be used together")
+		}
+		if c.TagTemplate != "" {
+			if _, err := parseTagTemplate(c.TagTemplate); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.PlanOutPath != "" || c.ApplyPlanPath != "" {
+		if c.Mode != ModePush {
+			return fmt.Errorf("-plan-out and -apply-plan are only supported in push mode")
+		}
+		if c.PlanOutPath != "" && c.ApplyPlanPath != "" {
+			return fmt.Errorf("-plan-out and -apply-plan cannot be used together")
+		}
+	}
+
+	return nil
+}
+
+// PrintsResult reports whether the run prints its result to stdout for
+// another program to read: the changes of -list-changes, or the branch
+// pushed by -branch-template. Logs and git's output then go to stderr.
+func (c Config) PrintsResult() bool {
+	return c.Mode == ModePush && (c.ListChanges || c.BranchTemplate != "")
+}
+
+// syncOptions returns the file selection options derived from the config.
+func (c Config) syncOptions() syncOptions {
+	opts := syncOptions{
+		Include:        c.Include,
+		Exclude:        c.Exclude,
+		AddGitKeep:     c.KeepEmptyDirs && c.Mode == ModePush,
+		StripGitKeep:   c.KeepEmptyDirs && c.Mode == ModePull,
+		SkipUnchanged:  c.SkipUnchanged && c.Mode == ModePull,
+		ChecksumAlgo:   c.ChecksumAlgo,
+		LongPaths:      c.LongPaths,
+		Submodules:     c.RecurseSubmodules && c.Mode == ModePull,
+		PreserveOwner:  c.PreserveOwner && c.Mode == ModePull,
+		PreserveXattrs: c.PreserveXattrs && c.Mode == ModePull,
+		ForceOverwrite: c.ForceOverwrite,
+		FailSpecial:    c.Strict,
+	}
+	// Push writes into its own clone, so only pull protects read-only
+	// files; sync does for the writes into the folder
+	opts.ProtectReadOnly = c.Mode == ModePull
+	if c.RenderTemplates && c.Mode == ModePull {
+		opts.TemplateSuffix = cmp.Or(c.TemplateSuffix, DefaultTemplateSuffix)
+		opts.TemplateData = environData()
+	}
+	if c.Mode == ModePull {
+		for _, dir := range c.Sparse {
+			opts.Sparse = append(opts.Sparse, cleanSparsePath(dir))
+		}
+	}
+	if c.MaxDepth != nil {
+		opts.LimitDepth = true
+		opts.MaxDepth = *c.MaxDepth
+	}
+	// The limits are checked by Validate, so parse errors can't occur here
+	if c.RateLimit != "" {
+		opts.RateLimit, _ = parseSize(c.RateLimit)
+	}
+	if c.CopyBuffer != "" {
+		size, _ := parseSize(c.CopyBuffer)
+		opts.CopyBuffer = int(size)
+	}
+	opts.CopyRetries = c.CopyRetries
+	if c.MaxFileSize != "" && c.Mode == ModePush {
+		opts.MaxFileSize, _ = parseSize(c.MaxFileSize)
+		opts.FailOversize = c.OnOversize == OversizeFail
+	}
+	return opts
+}
+
+// diffContext returns the number of context lines of -diff.
+func (c Config) diffContext() int {
+	if c.DiffContext != nil {
+		return *c.DiffContext
+	}
+	return DefaultDiffContext
+}
+
+// hashJobs returns the number of hashing workers to use.
+func (c Config) hashJobs() int {
+	if c.Jobs > 0 {
+		return c.Jobs
+	}
+	return runtime.NumCPU()
+}
+
+// loadSchema loads the configured schema, returning nil when none is set.
+func (c Config) loadSchema() (*Schema, error) {
+	if c.SchemaPath == "" {
+		return nil, nil
+	}
+	return loadSchema(c.SchemaPath)
+}
+
+// repoURLSchemes are the URL schemes git can clone from.
+var repoURLSchemes = []string{"https", "http", "ssh", "git+ssh", "ssh+git", "git", "file"}
+
+// normalizeRepoURL checks that repoURL is a URL git can clone from and
+// returns it in canonical form: surrounding whitespace is trimmed and the
+// scheme and host are lower-cased. Accepted are scheme URLs such as
+// https://host/path and ssh://user@host:port/path, the scp-like
+// user@host:path syntax and local paths. The scp-like form is kept rather
+// than rewritten to ssh://, as its path is relative to the home directory.
+func normalizeRepoURL(repoURL string) (string, error) {
+	repoURL = strings.TrimSpace(repoURL)
+	if repoURL == "" {
+		return "", fmt.Errorf("repository URL is required")
+	}
+	if strings.ContainsFunc(repoURL, unicode.IsSpace) {
+		return "", fmt.Errorf("invalid repository URL %q: it must not contain whitespace", SanitizeURL(repoURL))
+	}
+
+	if scheme, rest, ok := strings.Cut(repoURL, "://"); ok {
+		scheme = strings.ToLower(scheme)
+		if !slices.Contains(repoURLSchemes, scheme) {
+			return "", fmt.Errorf("unsupported repository URL scheme %q: use https://, ssh://, git@host:path or a local path", scheme)
+		}
+		if scheme == "file" {
+			if rest == "" {
+				return "", fmt.Errorf("invalid repository URL %q: missing path", repoURL)
+			}
+			return scheme + "://" + rest, nil
+		}
+		authority, path, _ := strings.Cut(rest, "/")
+		userinfo, host := "", authority
+		if i := strings.LastIndex(authority, "@"); i >= 0 {
+			userinfo, host = authority[:i+1], authority[i+1:]
+		}
+		if host == "" || strings.HasPrefix(host, ":") {
+			return "", fmt.Errorf("invalid repository URL %q: missing host", SanitizeURL(repoURL))
+		}
+		if strings.Trim(path, "/") == "" {
+			return "", fmt.Errorf("invalid repository URL %q: missing repository path", SanitizeURL(repoURL))
+		}
+		return scheme + "://" + userinfo + strings.ToLower(host) + "/" + path, nil
+	}
+
+	if authority, path, ok := cutSCPLike(repoURL); ok {
+		userinfo, host := "", authority
+		if i := strings.LastIndex(authority, "@"); i >= 0 {
+			userinfo, host = authority[:i+1], authority[i+1:]
+		}
+		if host == "" {
+			return "", fmt.Errorf("invalid repository URL %q: missing host", repoURL)
+		}
+		if strings.Trim(path, "/") == "" {
+			return "", fmt.Errorf("invalid repository URL %q: missing repository path", repoURL)
+		}
+		return userinfo + strings.ToLower(host) + ":" + path, nil
+	}
+
+	// Anything else is a local path, which git resolves itself
+	return repoURL, nil
+}
+
+// cutSCPLike splits an scp-like URL such as git@host:path into its
+// user@host and path parts. Like git, it treats a colon as the host
+// separator only if no slash precedes it, and a single drive letter before
+// the colon marks a Windows path instead.
+func cutSCPLike(repoURL string) (authority, path string, ok bool) {
+	colon := strings.Index(repoURL, ":")
+	if colon < 0 {
+		return "", "", false
+	}
+	if slash := strings.IndexAny(repoURL, `/\`); slash >= 0 && slash < colon {
+		return "", "", false
+	}
+	if colon == 1 && unicode.IsLetter(rune(repoURL[0])) {
+		return "", "", false
+	}
+	return repoURL[:colon], repoURL[colon+1:], true
+}
+
+// isHTTPURL reports whether url uses the http:// or https:// scheme.
+func isHTTPURL(url string) bool {
+	lower := strings.ToLower(url)
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://")
+}
+
+// isValidBranchName reports whether name is a valid branch name according to
+// git's ref naming rules (see git check-ref-format), so that a bad -branch is
+// rejected up front rather than by git in the middle of a clone.
+func isValidBranchName(name string) bool {
+	if name == "" || name == "@" || strings.HasPrefix(name, "-") {
+		return false
+	}
+	if strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") {
+		return false
+	}
+	for _, bad := range []string{"..", "//", "@{"} {
+		if strings.Contains(name, bad) {
+			return false
+		}
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return false
+		}
+	}
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+// isPlainHTTP reports whether url uses the unencrypted http:// scheme.
+func isPlainHTTP(url string) bool {
+	return strings.HasPrefix(strings.ToLower(url), "http://")
+}
+
+// readRepoFile returns the repository URL stored in the file at path,
+// without surrounding whitespace.
+func readRepoFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read repository file: %w", err)
+	}
+	url := strings.TrimSpace(string(data))
+	if url == "" {
+		return "", fmt.Errorf("repository file %s is empty", path)
+	}
+	return url, nil
+}
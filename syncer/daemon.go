@@ -0,0 +1,62 @@
+package syncer
+
+import (
+	"context"
+	"time"
+)
+
+// maxBackoffFactor caps how far consecutive failures stretch the wait
+// between pulls, as a multiple of the interval.
+const maxBackoffFactor = 8
+
+// PullLoop pulls every Config.Interval until ctx is cancelled (-interval).
+// A cycle that fails is logged and the loop carries on, waiting twice as
+// long after each consecutive failure, up to maxBackoffFactor times the
+// interval. Cancelling ctx does not interrupt a running pull: the loop stops
+// once the current cycle has finished, and then returns nil.
+func (s *Syncer) PullLoop(ctx context.Context) error {
+	logger := s.logger
+	logger.Info("Pulling periodically", "interval", s.config.Interval.String())
+
+	failures := 0
+	for cycle := 1; ; cycle++ {
+		// A stop request lets the current pull finish
+		err := s.Pull(context.WithoutCancel(ctx))
+		if err != nil {
+			failures++
+		} else {
+			failures = 0
+		}
+		delay := pullDelay(s.config.Interval, failures)
+		if err != nil {
+			logger.Warn("Pull cycle failed", "cycle", cycle, "error", err, "retry_in", delay.String())
+		} else {
+			logger.Info("Pull cycle completed", "cycle", cycle, "next_in", delay.String())
+		}
+		if s.cycleDone != nil {
+			s.cycleDone(cycle, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.Info("Stopping periodic pulls", "cycles", cycle)
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// pullDelay returns how long PullLoop waits before the next cycle after the
+// given number of consecutive failures.
+func pullDelay(interval time.Duration, failures int) time.Duration {
+	factor := 1
+	for range failures {
+		if factor >= maxBackoffFactor {
+			break
+		}
+		factor *= 2
+	}
+	return interval * time.Duration(factor)
+}
@@ -0,0 +1,25 @@
+package syncer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPullDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: time.Minute},
+		{failures: 1, want: 2 * time.Minute},
+		{failures: 2, want: 4 * time.Minute},
+		{failures: 3, want: 8 * time.Minute},
+		{failures: 10, want: 8 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := pullDelay(time.Minute, tt.failures); got != tt.want {
+			t.Errorf("pullDelay(1m, %d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
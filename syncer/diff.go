@@ -0,0 +1,211 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// DefaultDiffContext is the number of unchanged lines shown around each
+	// change unless Config.DiffContext says otherwise.
+	DefaultDiffContext = 3
+	// maxDiffLines truncates the diff of a single file.
+	maxDiffLines = 200
+	// maxDiffCells bounds the line-matching table; larger files are reported
+	// as too large to diff instead.
+	maxDiffCells = 4 << 20
+	// binarySniffLen is how much of a file is checked for NUL bytes, as git does.
+	binarySniffLen = 8000
+)
+
+// isBinary reports whether data looks like a binary file, i.e. contains a
+// NUL byte near the start.
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) >= 0
+}
+
+// fileDiff describes how the content of path changed from before to after:
+// a unified diff with context unchanged lines around each change for text
+// files, or a one-line note for binary or very large files. Diffs longer
+// than maxDiffLines are truncated.
+func fileDiff(path string, before, after []byte, context int) string {
+	if isBinary(before) || isBinary(after) {
+		return fmt.Sprintf("binary %s differs\n", path)
+	}
+
+	a, b := splitLines(before), splitLines(after)
+	hunks, ok := diffHunks(a, b, context)
+	if !ok {
+		return fmt.Sprintf("%s differs (too large to diff)\n", path)
+	}
+
+	lines := []string{"--- a/" + path, "+++ b/" + path}
+	lines = append(lines, hunks...)
+	if len(lines) > maxDiffLines {
+		more := len(lines) - maxDiffLines
+		lines = append(lines[:maxDiffLines], fmt.Sprintf("... diff truncated, %d more lines", more))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// splitLines splits data into lines without their line endings.
+func splitLines(data []byte) []string {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffOp is one line of an edit script: ' ' keeps, '-' deletes and '+' inserts.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// diffHunks returns the unified diff hunks turning a into b, with context
+// unchanged lines around each change. It reports false if the files are too
+// large to compare line by line.
+func diffHunks(a, b []string, context int) ([]string, bool) {
+	ops, ok := editScript(a, b)
+	if !ok {
+		return nil, false
+	}
+
+	var hunks []string
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// Extend the hunk while changes are close enough to share context
+		start := max(0, i-context)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			} else if j-end > 2*context {
+				break
+			}
+		}
+		end = min(len(ops), end+context+1)
+
+		// Line numbers of the hunk start in a and b
+		aLine, bLine := 1, 1
+		for _, op := range ops[:start] {
+			if op.kind != '+' {
+				aLine++
+			}
+			if op.kind != '-' {
+				bLine++
+			}
+		}
+		var aLen, bLen int
+		var body []string
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+			body = append(body, string(op.kind)+op.line)
+		}
+		hunks = append(hunks, fmt.Sprintf("@@ -%s +%s @@", hunkRange(aLine, aLen), hunkRange(bLine, bLen)))
+		hunks = append(hunks, body...)
+		i = end
+	}
+	return hunks, true
+}
+
+// hunkRange formats a hunk's line range; an empty range names the line
+// before it, as diff does.
+func hunkRange(start, length int) string {
+	if length == 0 {
+		start--
+	}
+	return fmt.Sprintf("%d,%d", start, length)
+}
+
+// editScript computes a shortest edit script from a to b using the longest
+// common subsequence of their lines.
+func editScript(a, b []string) ([]diffOp, bool) {
+	// Common prefix and suffix need no table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(midA), len(midB)
+	if (n+1)*(m+1) > maxDiffCells {
+		return nil, false
+	}
+
+	// lcs[i][j] is the LCS length of midA[i:] and midB[j:]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && midA[i] == midB[j]:
+			ops = append(ops, diffOp{' ', midA[i]})
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', midA[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', midB[j]})
+			j++
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops, true
+}
+
+// printDiffs writes the diff of every modified file in the clone at dir,
+// comparing the committed version with the synced one. Secrets are masked
+// like in git's output.
+func (s *Syncer) printDiffs(ctx context.Context, dir string, paths []string, w io.Writer) error {
+	for _, path := range paths {
+		before, err := s.command(ctx, dir, "git", "show", "HEAD:"+path).Output()
+		if err != nil {
+			return fmt.Errorf("failed to read committed version of %s: %w", path, err)
+		}
+		after, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, redact(fileDiff(path, before, after, s.config.diffContext()))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package syncer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFileDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   string
+	}{
+		{
+			name:   "changed line",
+			before: "one\ntwo\nthree\n",
+			after:  "one\nTWO\nthree\n",
+			want: "--- a/notes.txt\n+++ b/notes.txt\n" +
+				"@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three\n",
+		},
+		{
+			name:   "appended lines",
+			before: "a\nb\nc\nd\ne\nf\n",
+			after:  "a\nb\nc\nd\ne\nf\ng\n",
+			want: "--- a/notes.txt\n+++ b/notes.txt\n" +
+				"@@ -4,3 +4,4 @@\n d\n e\n f\n+g\n",
+		},
+		{
+			name:   "separate hunks",
+			before: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			after:  "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			want: "--- a/notes.txt\n+++ b/notes.txt\n" +
+				"@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+				"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+		{
+			name:   "binary file",
+			before: "PNG\x00\x01\x02",
+			after:  "PNG\x00\x01\x03",
+			want:   "binary notes.txt differs\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fileDiff("notes.txt", []byte(tt.before), []byte(tt.after), DefaultDiffContext); got != tt.want {
+				t.Errorf("fileDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileDiffTruncatesLongDiffs(t *testing.T) {
+	var after strings.Builder
+	for i := range 500 {
+		fmt.Fprintf(&after, "line %d\n", i)
+	}
+
+	got := fileDiff("big.txt", nil, []byte(after.String()), DefaultDiffContext)
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != maxDiffLines+1 {
+		t.Fatalf("diff has %d lines, want %d", len(lines), maxDiffLines+1)
+	}
+	if last := lines[len(lines)-1]; !strings.HasPrefix(last, "... diff truncated") {
+		t.Errorf("last line = %q, want a truncation note", last)
+	}
+}
+
+func TestFileDiffContext(t *testing.T) {
+	before := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	tests := []struct {
+		name    string
+		after   string
+		context int
+		want    string
+	}{
+		{
+			name:    "no context",
+			after:   "1\n2\n3\n4\n5\nsix\n7\n8\n9\n10\n11\n12\n",
+			context: 0,
+			want:    "@@ -6,1 +6,1 @@\n-6\n+six\n",
+		},
+		{
+			name:    "one line",
+			after:   "1\n2\n3\n4\n5\nsix\n7\n8\n9\n10\n11\n12\n",
+			context: 1,
+			want:    "@@ -5,3 +5,3 @@\n 5\n-6\n+six\n 7\n",
+		},
+		{
+			name:    "five lines",
+			after:   "1\n2\n3\n4\n5\nsix\n7\n8\n9\n10\n11\n12\n",
+			context: 5,
+			want:    "@@ -1,11 +1,11 @@\n 1\n 2\n 3\n 4\n 5\n-6\n+six\n 7\n 8\n 9\n 10\n 11\n",
+		},
+		{
+			name:    "nearby changes split without context",
+			after:   "1\ntwo\n3\nfour\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			context: 0,
+			want:    "@@ -2,1 +2,1 @@\n-2\n+two\n@@ -4,1 +4,1 @@\n-4\n+four\n",
+		},
+		{
+			name:    "nearby changes share context",
+			after:   "1\ntwo\n3\nfour\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			context: 1,
+			want:    "@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n-4\n+four\n 5\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := "--- a/app.conf\n+++ b/app.conf\n" + tt.want
+			if got := fileDiff("app.conf", []byte(before), []byte(tt.after), tt.context); got != want {
+				t.Errorf("fileDiff() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors returned by Syncer.Push and Syncer.Pull. Failures wrap one
+// of these (alongside the underlying cause) so callers can branch on the
+// category with errors.Is while still getting a descriptive message.
+var (
+	// ErrFolderMissing means the folder to push from does not exist.
+	ErrFolderMissing = errors.New("folder does not exist")
+	// ErrCloneFailed means the repository could not be cloned.
+	ErrCloneFailed = errors.New("failed to clone repository")
+	// ErrCommitFailed means git refused to create the sync commit.
+	ErrCommitFailed = errors.New("failed to commit changes")
+	// ErrPushRejected means the remote rejected the push, e.g. because the
+	// branch moved on in the meantime. Retrying with a fresh clone may succeed.
+	ErrPushRejected = errors.New("push rejected by remote")
+	// ErrBranchProtected means the remote refused the push because the
+	// branch is protected. Pushing to another branch is the usual remedy.
+	ErrBranchProtected = errors.New("branch is protected")
+	// ErrPushFailed means the push failed for any other reason.
+	ErrPushFailed = errors.New("failed to push changes")
+	// ErrNoChanges means the push found nothing to commit. The CLI does not
+	// log it as a failure and exits with code 2.
+	ErrNoChanges = errors.New("no changes to push")
+	// ErrTagExists means the tag to create already exists and -force-tag
+	// was not given.
+	ErrTagExists = errors.New("tag already exists")
+	// ErrPlanDrift means the source changed since the applied plan was created.
+	ErrPlanDrift = errors.New("source has drifted from plan")
+	// ErrFileTooLarge means a file exceeds -max-file-size and -on-oversize
+	// is set to fail.
+	ErrFileTooLarge = errors.New("file exceeds maximum size")
+	// ErrLocked means another run holds the lock on the folder and did not
+	// release it within -lock-timeout.
+	ErrLocked = errors.New("folder is locked by another sync")
+	// ErrPathTooLong means a destination path exceeds the operating
+	// system's path length limit.
+	ErrPathTooLong = errors.New("path too long")
+	// ErrSchemaViolation means the synced content does not conform to -schema.
+	ErrSchemaViolation = errors.New("folder does not conform to schema")
+	// ErrSyncConflict means a file changed both locally and in the
+	// repository since the last sync. The repository's version is kept next
+	// to the local one with a .conflict suffix.
+	ErrSyncConflict = errors.New("conflicting changes")
+	// ErrInvalidFileList means a path read by -from-stdin is outside the
+	// folder, does not exist or is not a regular file.
+	ErrInvalidFileList = errors.New("invalid file list")
+	// ErrConflictMarkers means pulled files contain merge conflict markers
+	// (-scan-conflict-markers).
+	ErrConflictMarkers = errors.New("conflict markers found")
+	// ErrCaseCollision means the repository holds files whose paths differ
+	// only in case and the folder is on a case-insensitive filesystem.
+	ErrCaseCollision = errors.New("paths collide on a case-insensitive filesystem")
+	// ErrLFSPointer means a push would commit Git LFS pointer files instead
+	// of their content and -strict-lfs is set.
+	ErrLFSPointer = errors.New("LFS pointer files found")
+	// ErrSpecialFile means the folder holds a FIFO, socket or device, which
+	// cannot be synced, and -strict is set.
+	ErrSpecialFile = errors.New("special file cannot be synced")
+	// ErrTooManyChanges means a push would change more files than
+	// -max-changes allows and -force-large was not given.
+	ErrTooManyChanges = errors.New("too many changes")
+	// ErrHookFailed means a -pre-hook or -post-hook command exited with an
+	// error.
+	ErrHookFailed = errors.New("hook failed")
+)
+
+// protectedBranchMessages are fragments of the messages GitHub, GitLab, Gitea
+// and Bitbucket print when a push to a protected branch is refused.
+var protectedBranchMessages = []string{
+	"protected branch",
+	"gh006",
+	"can only be modified through pull requests",
+}
+
+// classifyPushError wraps a failed git push with ErrBranchProtected when the
+// remote refused to update a protected branch, with ErrPushRejected when it
+// refused the update for another reason and with ErrPushFailed otherwise.
+func classifyPushError(output string, err error) error {
+	lower := strings.ToLower(output)
+	for _, msg := range protectedBranchMessages {
+		if strings.Contains(lower, msg) {
+			return fmt.Errorf("%w: %w (push to a different -branch and merge it through a pull request)", ErrBranchProtected, err)
+		}
+	}
+	if strings.Contains(output, "[rejected]") || strings.Contains(output, "[remote rejected]") {
+		return fmt.Errorf("%w: %w", ErrPushRejected, err)
+	}
+	return fmt.Errorf("%w: %w", ErrPushFailed, err)
+}
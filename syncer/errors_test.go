@@ -0,0 +1,126 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassifyPushError(t *testing.T) {
+	cause := errors.New("exit status 1")
+	tests := []struct {
+		name   string
+		output string
+		want   error
+	}{
+		{
+			name:   "non-fast-forward",
+			output: " ! [rejected]        main -> main (fetch first)\nerror: failed to push some refs",
+			want:   ErrPushRejected,
+		},
+		{
+			name:   "remote hook rejection",
+			output: " ! [remote rejected] main -> main (pre-receive hook declined)",
+			want:   ErrPushRejected,
+		},
+		{
+			name:   "GitHub protected branch",
+			output: "remote: error: GH006: Protected branch update failed for refs/heads/main.\n ! [remote rejected] main -> main (protected branch hook declined)",
+			want:   ErrBranchProtected,
+		},
+		{
+			name:   "GitLab protected branch",
+			output: "remote: GitLab: You are not allowed to push code to protected branches on this project.\n ! [remote rejected] main -> main (pre-receive hook declined)",
+			want:   ErrBranchProtected,
+		},
+		{
+			name:   "Gitea protected branch",
+			output: "remote: error: Not allowed to push to protected branch main\n ! [remote rejected] main -> main (pre-receive hook declined)",
+			want:   ErrBranchProtected,
+		},
+		{
+			name:   "Bitbucket branch restriction",
+			output: "remote: Branch refs/heads/main can only be modified through pull requests.\n ! [remote rejected] main -> main (pre-receive hook declined)",
+			want:   ErrBranchProtected,
+		},
+		{
+			name:   "network failure",
+			output: "fatal: unable to access 'https://example.com/repo.git/': Could not resolve host",
+			want:   ErrPushFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyPushError(tt.output, cause)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("classifyPushError() = %v, want errors.Is %v", err, tt.want)
+			}
+			if !errors.Is(err, cause) {
+				t.Errorf("classifyPushError() = %v, should wrap the underlying error", err)
+			}
+		})
+	}
+}
+
+func TestClassifyPushErrorProtectedBranchHint(t *testing.T) {
+	err := classifyPushError("remote: error: GH006: Protected branch update failed", errors.New("exit status 1"))
+	if errors.Is(err, ErrPushRejected) {
+		t.Error("a protected branch rejection should not be retried as ErrPushRejected")
+	}
+	if !strings.Contains(err.Error(), "-branch") {
+		t.Errorf("error should hint at using a different -branch: %v", err)
+	}
+}
+
+func TestPushReturnsErrFolderMissing(t *testing.T) {
+	config := Config{
+		Mode:       ModePush,
+		FolderPath: filepath.Join(t.TempDir(), "missing"),
+		RepoURL:    "https://github.com/user/repo.git",
+		Branch:     "main",
+	}
+
+	if _, err := newTestSyncer(t, config).Push(context.Background()); !errors.Is(err, ErrFolderMissing) {
+		t.Errorf("Push() error = %v, want ErrFolderMissing", err)
+	}
+}
+
+func TestPushRejectsSingleFileFolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("notes"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	config := Config{
+		Mode:       ModePush,
+		FolderPath: path,
+		RepoURL:    "https://github.com/user/repo.git",
+		Branch:     "main",
+	}
+
+	_, err := newTestSyncer(t, config).Push(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "folder must be a directory") {
+		t.Errorf("Push() error = %v, want a folder must be a directory error", err)
+	}
+}
+
+func TestSchemaValidateReturnsErrSchemaViolation(t *testing.T) {
+	schema := &Schema{Required: []string{"README.md"}}
+
+	if err := schema.Validate(t.TempDir()); !errors.Is(err, ErrSchemaViolation) {
+		t.Errorf("Validate() error = %v, want ErrSchemaViolation", err)
+	}
+}
+
+func TestPlanVerifySourceReturnsErrPlanDrift(t *testing.T) {
+	plan := &Plan{Operations: []PlanOperation{
+		{Action: PlanActionAdd, Path: "missing.txt", SHA256: "abc"},
+	}}
+
+	if err := plan.verifySource(t.TempDir()); !errors.Is(err, ErrPlanDrift) {
+		t.Errorf("verifySource() error = %v, want ErrPlanDrift", err)
+	}
+}
@@ -0,0 +1,64 @@
+package syncer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// gitAttributesFile is read from the source folder root for export-ignore
+// patterns.
+const gitAttributesFile = ".gitattributes"
+
+// parseExportIgnore returns the patterns that the .gitattributes content in r
+// marks with the export-ignore attribute. Only this attribute is understood:
+// a later "-export-ignore" or "!export-ignore" for the same pattern cancels
+// an earlier one, macros and quoted patterns are ignored.
+func parseExportIgnore(r io.Reader) ([]string, error) {
+	var patterns []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") ||
+			strings.HasPrefix(fields[0], "[attr]") || strings.HasPrefix(fields[0], `"`) {
+			continue
+		}
+
+		pattern := strings.TrimSuffix(fields[0], "/")
+		for _, attr := range fields[1:] {
+			switch attr {
+			case "export-ignore":
+				if !slices.Contains(patterns, pattern) {
+					patterns = append(patterns, pattern)
+				}
+			case "-export-ignore", "!export-ignore":
+				patterns = slices.DeleteFunc(patterns, func(p string) bool { return p == pattern })
+			}
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// loadExportIgnore reads the export-ignore patterns from the .gitattributes
+// file at the root of dir. A missing file yields no patterns.
+func loadExportIgnore(dir string) ([]string, error) {
+	f, err := os.Open(filepath.Join(dir, gitAttributesFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", gitAttributesFile, err)
+	}
+	defer f.Close()
+
+	patterns, err := parseExportIgnore(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", gitAttributesFile, err)
+	}
+	return patterns, nil
+}
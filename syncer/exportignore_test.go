@@ -0,0 +1,72 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseExportIgnore(t *testing.T) {
+	input := `# distribution rules
+*.go          text eol=lf
+/tests        export-ignore
+docs/         export-ignore
+*.secret      export-ignore diff
+[attr]binary  -diff -merge -text
+"quoted name" export-ignore
+keep.txt      export-ignore
+keep.txt      -export-ignore
+`
+	got, err := parseExportIgnore(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseExportIgnore() failed: %v", err)
+	}
+	want := []string{"/tests", "docs", "*.secret"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseExportIgnore() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadExportIgnoreMissingFile(t *testing.T) {
+	patterns, err := loadExportIgnore(t.TempDir())
+	if err != nil {
+		t.Fatalf("loadExportIgnore() failed: %v", err)
+	}
+	if patterns != nil {
+		t.Errorf("loadExportIgnore() = %v, want nil", patterns)
+	}
+}
+
+func TestSyncFilesSkipsExportIgnored(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{
+		".gitattributes":     "internal.txt export-ignore\n/tests export-ignore\n",
+		"README.md":          "readme",
+		"internal.txt":       "internal",
+		"docs/internal.txt":  "nested internal",
+		"tests/unit_test.go": "package tests",
+		"src/tests/keep.go":  "package tests",
+	})
+
+	patterns, err := loadExportIgnore(srcDir)
+	if err != nil {
+		t.Fatalf("loadExportIgnore() failed: %v", err)
+	}
+	if _, err := syncFiles(srcDir, dstDir, syncOptions{ExportIgnore: patterns}); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+
+	for _, path := range []string{".gitattributes", "README.md", "src/tests/keep.go"} {
+		if _, err := os.Stat(filepath.Join(dstDir, path)); err != nil {
+			t.Errorf("%s should be synced: %v", path, err)
+		}
+	}
+	for _, path := range []string{"internal.txt", "docs/internal.txt", "tests"} {
+		if _, err := os.Stat(filepath.Join(dstDir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s is marked export-ignore and should not be synced", path)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package syncer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readFileList reads newline-separated paths relative to root from r, as
+// piped to -from-stdin. Blank lines are ignored. Every path must stay inside
+// root and name an existing regular file, otherwise ErrInvalidFileList is
+// returned; duplicates are dropped.
+func readFileList(r io.Reader, root string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		relPath := filepath.Clean(filepath.FromSlash(line))
+		if !filepath.IsLocal(relPath) {
+			return nil, fmt.Errorf("%w: %s is outside the folder", ErrInvalidFileList, line)
+		}
+		info, err := os.Stat(filepath.Join(root, relPath))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFileList, err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%w: %s is not a regular file", ErrInvalidFileList, line)
+		}
+		if !seen[relPath] {
+			seen[relPath] = true
+			files = append(files, relPath)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read file list: %w", err)
+	}
+	return files, nil
+}
+
+// syncFileList copies the files listed in files, relative to srcDir, to the
+// same paths in dstDir instead of walking srcDir (push with -from-stdin). The
+// .git directory, exclude patterns and size limits still apply.
+func syncFileList(srcDir, dstDir string, files []string, opts syncOptions) (syncCounts, error) {
+	var counts syncCounts
+	for _, relPath := range files {
+		if opts.skipped(relPath, false) || !opts.included(relPath) {
+			continue
+		}
+		src := filepath.Join(srcDir, relPath)
+		info, err := os.Stat(src)
+		if err != nil {
+			return counts, err
+		}
+		if oversized, err := opts.oversized(relPath, info.Size()); oversized || err != nil {
+			if oversized {
+				counts.Oversized = append(counts.Oversized, relPath)
+			}
+			return counts, err
+		}
+
+		dstPath := filepath.Join(dstDir, relPath)
+		if err := opts.checkPathLength(relPath, dstPath); err != nil {
+			return counts, err
+		}
+		if opts.LongPaths {
+			dstPath = longPath(dstPath)
+		}
+		if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+			return counts, err
+		}
+		if _, err := os.Lstat(dstPath); os.IsNotExist(err) {
+			counts.Created++
+		}
+		if err := copyFile(src, dstPath, info.Mode(), opts); err != nil {
+			return counts, err
+		}
+		counts.Copied++
+	}
+	return counts, nil
+}
@@ -0,0 +1,107 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadFileList(t *testing.T) {
+	root := t.TempDir()
+	createTestFiles(t, root, map[string]string{
+		"a.txt":       "a",
+		"docs/b.md":   "b",
+		"docs/c.html": "c",
+	})
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "paths",
+			input: "a.txt\n./docs/b.md\r\n\n  \ndocs/b.md\n",
+			want:  []string{"a.txt", filepath.Join("docs", "b.md")},
+		},
+		{name: "empty list", input: ""},
+		{name: "outside folder", input: "../etc/passwd\n", wantErr: true},
+		{name: "absolute path", input: filepath.Join(root, "a.txt") + "\n", wantErr: true},
+		{name: "missing file", input: "missing.txt\n", wantErr: true},
+		{name: "directory", input: "docs\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readFileList(strings.NewReader(tt.input), root)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidFileList) {
+					t.Errorf("readFileList() error = %v, want ErrInvalidFileList", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readFileList() failed: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("readFileList() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncFileList(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{
+		"a.txt":       "a",
+		"docs/b.md":   "b",
+		"docs/c.html": "c",
+		"skip.log":    "log",
+	})
+
+	files := []string{"a.txt", filepath.Join("docs", "b.md"), "skip.log"}
+	counts, err := syncFileList(srcDir, dstDir, files, syncOptions{Exclude: []string{"*.log"}})
+	if err != nil {
+		t.Fatalf("syncFileList() failed: %v", err)
+	}
+	if counts.Copied != 2 {
+		t.Errorf("copied %d files, want 2", counts.Copied)
+	}
+	for _, path := range []string{"a.txt", "docs/b.md"} {
+		if _, err := os.Stat(filepath.Join(dstDir, path)); err != nil {
+			t.Errorf("%s should be synced: %v", path, err)
+		}
+	}
+	for _, path := range []string{"docs/c.html", "skip.log"} {
+		if _, err := os.Stat(filepath.Join(dstDir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be synced", path)
+		}
+	}
+}
+
+func TestPushFromStdinRejectsBadPathBeforeCloning(t *testing.T) {
+	logPath := installFakeGit(t, "")
+	folder := t.TempDir()
+
+	s := newTestSyncer(t, Config{
+		Mode:       ModePush,
+		FolderPath: folder,
+		RepoURL:    "https://github.com/user/repo.git",
+		Branch:     "main",
+		FromStdin:  true,
+	})
+	s.stdin = strings.NewReader("../outside.txt\n")
+
+	if _, err := s.Push(context.Background()); !errors.Is(err, ErrInvalidFileList) {
+		t.Fatalf("Push() error = %v, want ErrInvalidFileList", err)
+	}
+	if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+		t.Errorf("git was run before the file list was checked: %q", readFakeGitCalls(t, logPath))
+	}
+}
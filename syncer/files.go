@@ -0,0 +1,530 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// syncCounts tallies how many files syncFiles copied and how many it left
+// alone because the destination already matched. Created counts the copied
+// files that did not exist in the destination before. Oversized lists the
+// files skipped for exceeding the maximum file size, Special the FIFOs,
+// sockets and devices that were skipped.
+type syncCounts struct {
+	Copied    int
+	Created   int
+	Skipped   int
+	Oversized []string
+	Special   []string
+}
+
+func syncFiles(srcDir, dstDir string, opts syncOptions) (syncCounts, error) {
+	var counts syncCounts
+
+	var tracker *progressTracker
+	if opts.Progress != nil {
+		files, size, err := countSyncFiles(srcDir, opts)
+		if err != nil {
+			return counts, err
+		}
+		tracker = newProgressTracker(files, size, opts.Progress)
+	}
+
+	// Walk through source directory
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Get relative path
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+
+		// Skip root directory
+		if relPath == "." {
+			return nil
+		}
+
+		// Skip .git and excluded paths
+		if opts.skipped(relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		dstPath := filepath.Join(dstDir, relPath)
+		if err := opts.checkPathLength(relPath, dstPath); err != nil {
+			return err
+		}
+		if opts.LongPaths {
+			dstPath = longPath(dstPath)
+		}
+
+		if info.IsDir() && opts.AddGitKeep {
+			empty, err := isEmptyDir(path)
+			if err != nil {
+				return err
+			}
+			if empty {
+				return writeGitKeep(dstPath, info.Mode())
+			}
+		}
+
+		if info.IsDir() {
+			// With include or negated exclude patterns, directories are
+			// created on demand so that folders without matching files
+			// don't appear in the destination
+			if opts.dirsOnDemand() {
+				return nil
+			}
+			// Create directory
+			return opts.Staging.mkdirAll(dstPath, info.Mode())
+		}
+
+		if opts.StripGitKeep && info.Name() == gitKeepFile {
+			return removeGitKeep(dstPath, opts.Staging)
+		}
+
+		if !opts.included(relPath) {
+			return nil
+		}
+
+		if special, err := opts.special(relPath, path, info); special || err != nil {
+			if special {
+				counts.Special = append(counts.Special, relPath)
+			}
+			return err
+		}
+
+		if opts.dirsOnDemand() {
+			if err := opts.Staging.mkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+				return err
+			}
+		}
+
+		if oversized, err := opts.oversized(relPath, info.Size()); oversized || err != nil {
+			if oversized {
+				counts.Oversized = append(counts.Oversized, relPath)
+			}
+			return err
+		}
+
+		if target, ok := opts.templateTarget(relPath, dstPath); ok {
+			_, err := os.Lstat(target)
+			created := os.IsNotExist(err)
+			written, err := renderTemplate(path, target, info.Mode(), opts)
+			if err != nil {
+				return fmt.Errorf("%s: %w", relPath, err)
+			}
+			switch {
+			case !written:
+				counts.Skipped++
+			case created:
+				counts.Created++
+				fallthrough
+			default:
+				counts.Copied++
+			}
+			tracker.add(info.Size())
+			return nil
+		}
+
+		if opts.SkipUnchanged {
+			same, err := sameContent(path, dstPath, info, relPath, opts)
+			if err != nil {
+				return err
+			}
+			if same {
+				opts.preserveMetadata(relPath, path, dstPath, info)
+				counts.Skipped++
+				tracker.add(info.Size())
+				return nil
+			}
+		}
+
+		// Copy file
+		if _, err := os.Lstat(dstPath); os.IsNotExist(err) {
+			counts.Created++
+		}
+		if err := copyFile(path, dstPath, info.Mode(), opts); err != nil {
+			return err
+		}
+		opts.preserveMetadata(relPath, path, opts.Staging.target(dstPath), info)
+		counts.Copied++
+		tracker.add(info.Size())
+		return nil
+	})
+	if err == nil {
+		tracker.finish()
+	}
+	return counts, err
+}
+
+// syncFile copies the single file src into the root of dstDir, applying the
+// same exclude, include and size rules as syncFiles (push with -allow-single-file).
+func syncFile(src, dstDir string, opts syncOptions) (syncCounts, error) {
+	var counts syncCounts
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return counts, err
+	}
+	name := info.Name()
+	if opts.skipped(name, false) || !opts.included(name) {
+		return counts, nil
+	}
+	if special, err := opts.special(name, src, info); special || err != nil {
+		if special {
+			counts.Special = append(counts.Special, name)
+		}
+		return counts, err
+	}
+	if oversized, err := opts.oversized(name, info.Size()); oversized || err != nil {
+		if oversized {
+			counts.Oversized = append(counts.Oversized, name)
+		}
+		return counts, err
+	}
+
+	if err := copyFile(src, filepath.Join(dstDir, name), info.Mode(), opts); err != nil {
+		return counts, err
+	}
+	counts.Copied++
+	return counts, nil
+}
+
+// removeExtraneous deletes files and directories from dstDir that do not
+// exist in srcDir, making dstDir mirror srcDir (push, and pull with -clean).
+// Paths that syncFiles would skip, such as .git and excluded or non-included
+// paths, are left alone, as are the .gitkeep placeholders it writes. With
+// opts.Staging, the paths are only removed when the staged files are moved
+// into place. It returns the removed paths relative to dstDir.
+func removeExtraneous(srcDir, dstDir string, opts syncOptions) ([]string, error) {
+	var removed []string
+	err := filepath.Walk(dstDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dstDir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		if opts.skipped(relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if _, err := os.Lstat(filepath.Join(srcDir, relPath)); err == nil {
+			return nil
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		if !info.IsDir() {
+			if rendered, err := opts.templateSource(srcDir, relPath); rendered || err != nil {
+				return err
+			}
+			if opts.AddGitKeep && info.Name() == gitKeepFile {
+				if empty, err := isEmptyDir(filepath.Join(srcDir, filepath.Dir(relPath))); err == nil && empty {
+					return nil
+				}
+			}
+		}
+
+		if info.IsDir() {
+			// With include or negated exclude patterns the directory may
+			// hold files outside the sync, so only its matching files are
+			// removed
+			if opts.dirsOnDemand() {
+				return nil
+			}
+			if err := opts.Staging.remove(path); err != nil {
+				return err
+			}
+			removed = append(removed, relPath)
+			return filepath.SkipDir
+		}
+		if !opts.included(relPath) {
+			return nil
+		}
+		if err := opts.Staging.remove(path); err != nil {
+			return err
+		}
+		removed = append(removed, relPath)
+		return nil
+	})
+	return removed, err
+}
+
+// countSyncFiles counts the files, and their total size, that syncFiles
+// would process with opts. It is the first pass of progress reporting.
+func countSyncFiles(srcDir string, opts syncOptions) (int, int64, error) {
+	var files int
+	var size int64
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		if opts.skipped(relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || (opts.StripGitKeep && info.Name() == gitKeepFile) || !opts.included(relPath) {
+			return nil
+		}
+		if opts.MaxFileSize > 0 && info.Size() > opts.MaxFileSize {
+			return nil
+		}
+		if special, _ := opts.special(relPath, path, info); special {
+			return nil
+		}
+		files++
+		size += info.Size()
+		return nil
+	})
+	return files, size, err
+}
+
+// sameContent reports whether dst already holds the same bytes as src, with
+// the same permissions. Sizes are compared first so that only same-sized
+// files are hashed, with opts.ChecksumAlgo. The digest of dst, which is
+// relPath below the destination, is taken from opts.HashCache when it is
+// still valid.
+func sameContent(src, dst string, srcInfo os.FileInfo, relPath string, opts syncOptions) (bool, error) {
+	dstInfo, err := os.Stat(dst)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !dstInfo.Mode().IsRegular() || dstInfo.Size() != srcInfo.Size() {
+		return false, nil
+	}
+	// Rewrite files whose permissions differ, e.g. a lost executable bit
+	if !opts.samePerm(dstInfo.Mode().Perm(), srcInfo.Mode().Perm()) {
+		return false, nil
+	}
+
+	srcHash, err := hashFile(src, opts.ChecksumAlgo)
+	if err != nil {
+		return false, err
+	}
+	dstHash, err := opts.HashCache.fileHash(relPath, dst, dstInfo, opts.ChecksumAlgo)
+	if err != nil {
+		return false, err
+	}
+	return srcHash == dstHash, nil
+}
+
+// samePerm reports whether a destination file with permissions dst matches
+// a source file with src. A file made read-only in the user's folder
+// (ProtectReadOnly) keeps that mode when replaced, so its write bits are
+// not compared.
+func (o syncOptions) samePerm(dst, src os.FileMode) bool {
+	if o.ProtectReadOnly && dst&0200 == 0 {
+		return dst&^0222 == src&^0222
+	}
+	return dst == src
+}
+
+// gitKeepFile is the placeholder written into empty directories so git tracks them.
+const gitKeepFile = ".gitkeep"
+
+// isEmptyDir reports whether the directory at path has no entries.
+func isEmptyDir(path string) (bool, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return false, err
+	}
+	return len(entries) == 0, nil
+}
+
+// writeGitKeep creates dir and an empty .gitkeep placeholder inside it.
+func writeGitKeep(dir string, mode os.FileMode) error {
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, gitKeepFile), nil, 0644)
+}
+
+// removeGitKeep makes sure the directory holding a .gitkeep placeholder
+// exists in the destination without the placeholder itself. Both go through
+// staging.
+func removeGitKeep(path string, staging *stagedWrites) error {
+	if err := staging.mkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return staging.remove(path)
+}
+
+// copyRetryDelay is the pause before the first retry of a failed copy. It
+// grows with every further attempt.
+var copyRetryDelay = 500 * time.Millisecond
+
+func copyFile(src, dst string, mode os.FileMode, opts syncOptions) error {
+	var created bool
+	if opts.FileCopied != nil {
+		_, err := os.Lstat(dst)
+		created = os.IsNotExist(err)
+	}
+
+	perm, readOnly, err := opts.prepareOverwrite(dst)
+	if err != nil {
+		return err
+	}
+
+	// Create destination file, or its staged copy
+	target := opts.Staging.target(dst)
+	var n int64
+	err = retryCopy(opts.CopyRetries, func(attempt int, err error) {
+		if opts.CopyRetried != nil {
+			opts.CopyRetried(src, attempt, err)
+		}
+	}, func() error {
+		var err error
+		n, err = copyContents(src, target, mode, opts)
+		return err
+	})
+	if err != nil {
+		// A staged copy leaves restoring dst to the discard
+		if readOnly && opts.Staging == nil {
+			os.Chmod(dst, perm)
+		}
+		return err
+	}
+	if readOnly {
+		if err := os.Chmod(target, perm); err != nil {
+			return err
+		}
+	}
+	if opts.FileCopied != nil {
+		opts.FileCopied(dst, n, created)
+	}
+	return nil
+}
+
+// prepareOverwrite makes dst, which is about to be replaced, writable if it
+// is a read-only file. In the user's folder (ProtectReadOnly) that fails
+// with an error naming dst unless ForceOverwrite is set, and the
+// permissions of dst are returned for the caller to restore on the new
+// content. Files in a clone or work dir are simply overwritten.
+func (o syncOptions) prepareOverwrite(dst string) (os.FileMode, bool, error) {
+	info, err := os.Lstat(dst)
+	if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0200 != 0 {
+		return 0, false, nil
+	}
+	perm := info.Mode().Perm()
+	if o.ProtectReadOnly && !o.ForceOverwrite {
+		return 0, false, fmt.Errorf("%s is read-only, pass -force-overwrite to replace it", dst)
+	}
+	if err := os.Chmod(dst, perm|0200); err != nil {
+		return 0, false, fmt.Errorf("failed to make %s writable: %w", dst, err)
+	}
+	if !o.ProtectReadOnly {
+		return 0, false, nil
+	}
+	o.Staging.madeWritable(dst, perm)
+	return perm, true, nil
+}
+
+// retryCopy runs copyOnce, running it again up to retries times while it
+// fails with a transient error. retried is called before every retry.
+func retryCopy(retries int, retried func(attempt int, err error), copyOnce func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := copyOnce()
+		if err == nil || attempt > retries || !isTransientCopyError(err) {
+			return err
+		}
+		retried(attempt, err)
+		time.Sleep(time.Duration(attempt) * copyRetryDelay)
+	}
+}
+
+// isTransientCopyError reports whether err is an I/O error that a network
+// filesystem may recover from, such as EIO or EAGAIN. Errors like ENOSPC or
+// a missing file are permanent and not worth retrying.
+func isTransientCopyError(err error) bool {
+	return errors.Is(err, syscall.EIO) || errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.ETIMEDOUT)
+}
+
+// copyContents copies src into the file at target, creating or truncating
+// it with mode, and returns the number of bytes copied.
+func copyContents(src, target string, mode os.FileMode, opts syncOptions) (int64, error) {
+	// Open source file
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return 0, err
+	}
+	defer dstFile.Close()
+
+	// The create mode is masked by the umask and ignored for existing
+	// files, so set it explicitly to keep executables executable
+	if err := os.Chmod(target, mode.Perm()); err != nil {
+		return 0, err
+	}
+
+	// Copy contents, throttled if a rate limit is set
+	var reader io.Reader = srcFile
+	if opts.RateLimit > 0 {
+		reader = newRateLimitedReader(srcFile, opts.RateLimit)
+	}
+	var n int64
+	if opts.CopyBuffer <= 0 {
+		n, err = io.Copy(dstFile, reader)
+	} else {
+		buf := getCopyBuffer(opts.CopyBuffer)
+		defer copyBuffers.Put(buf)
+		// Hide the files' ReadFrom and WriteTo methods, which would otherwise
+		// let io.CopyBuffer bypass the buffer
+		n, err = io.CopyBuffer(struct{ io.Writer }{dstFile}, struct{ io.Reader }{reader}, *buf)
+	}
+	if err != nil {
+		return 0, err
+	}
+	// Report write errors that only surface when the file is closed
+	return n, dstFile.Close()
+}
+
+// copyBuffers pools copy buffers so that copying many files does not
+// allocate a new buffer per file.
+var copyBuffers sync.Pool
+
+// getCopyBuffer returns a pooled buffer of size bytes, allocating a new one
+// if the pool is empty or holds a buffer of another size.
+func getCopyBuffer(size int) *[]byte {
+	if buf, ok := copyBuffers.Get().(*[]byte); ok && len(*buf) == size {
+		return buf
+	}
+	buf := make([]byte, size)
+	return &buf
+}
@@ -0,0 +1,872 @@
+package syncer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSyncFiles(t *testing.T) {
+	// Create source directory with test files
+	srcDir, err := os.MkdirTemp("", "sync-src-*")
+	if err != nil {
+		t.Fatalf("failed to create temp source dir: %v", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	// Create destination directory
+	dstDir, err := os.MkdirTemp("", "sync-dst-*")
+	if err != nil {
+		t.Fatalf("failed to create temp destination dir: %v", err)
+	}
+	defer os.RemoveAll(dstDir)
+
+	// Create test files in source
+	testFile1 := filepath.Join(srcDir, "test1.txt")
+	if err := os.WriteFile(testFile1, []byte("test content 1"), 0644); err != nil {
+		t.Fatalf("failed to create test file 1: %v", err)
+	}
+
+	testDir := filepath.Join(srcDir, "subdir")
+	if err := os.Mkdir(testDir, 0755); err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+
+	testFile2 := filepath.Join(testDir, "test2.txt")
+	if err := os.WriteFile(testFile2, []byte("test content 2"), 0644); err != nil {
+		t.Fatalf("failed to create test file 2: %v", err)
+	}
+
+	// Sync files
+	if _, err := syncFiles(srcDir, dstDir, syncOptions{}); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+
+	// Verify files were synced
+	dstFile1 := filepath.Join(dstDir, "test1.txt")
+	content1, err := os.ReadFile(dstFile1)
+	if err != nil {
+		t.Errorf("failed to read destination file 1: %v", err)
+	}
+	if string(content1) != "test content 1" {
+		t.Errorf("file 1 content mismatch: got %q, want %q", string(content1), "test content 1")
+	}
+
+	dstFile2 := filepath.Join(dstDir, "subdir", "test2.txt")
+	content2, err := os.ReadFile(dstFile2)
+	if err != nil {
+		t.Errorf("failed to read destination file 2: %v", err)
+	}
+	if string(content2) != "test content 2" {
+		t.Errorf("file 2 content mismatch: got %q, want %q", string(content2), "test content 2")
+	}
+}
+
+func TestSyncFilesSkipsGitDirectory(t *testing.T) {
+	// Create source directory with .git directory
+	srcDir, err := os.MkdirTemp("", "sync-src-git-*")
+	if err != nil {
+		t.Fatalf("failed to create temp source dir: %v", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	// Create destination directory
+	dstDir, err := os.MkdirTemp("", "sync-dst-git-*")
+	if err != nil {
+		t.Fatalf("failed to create temp destination dir: %v", err)
+	}
+	defer os.RemoveAll(dstDir)
+
+	// Create .git directory in source
+	gitDir := filepath.Join(srcDir, ".git")
+	if err := os.Mkdir(gitDir, 0755); err != nil {
+		t.Fatalf("failed to create .git directory: %v", err)
+	}
+
+	gitFile := filepath.Join(gitDir, "config")
+	if err := os.WriteFile(gitFile, []byte("git config"), 0644); err != nil {
+		t.Fatalf("failed to create git config file: %v", err)
+	}
+
+	// Create regular file
+	testFile := filepath.Join(srcDir, "test.txt")
+	if err := os.WriteFile(testFile, []byte("test content"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	// Sync files
+	if _, err := syncFiles(srcDir, dstDir, syncOptions{}); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+
+	// Verify .git directory was not synced
+	dstGitDir := filepath.Join(dstDir, ".git")
+	if _, err := os.Stat(dstGitDir); !os.IsNotExist(err) {
+		t.Errorf(".git directory should not be synced, but it exists")
+	}
+
+	// Verify regular file was synced
+	dstFile := filepath.Join(dstDir, "test.txt")
+	if _, err := os.Stat(dstFile); err != nil {
+		t.Errorf("regular file should be synced: %v", err)
+	}
+}
+
+func TestCopyFile(t *testing.T) {
+	// Create temporary directory
+	tempDir, err := os.MkdirTemp("", "copy-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Create source file
+	srcFile := filepath.Join(tempDir, "source.txt")
+	testContent := "test file content"
+	if err := os.WriteFile(srcFile, []byte(testContent), 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// Copy file
+	dstFile := filepath.Join(tempDir, "destination.txt")
+	if err := copyFile(srcFile, dstFile, 0644, syncOptions{}); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+
+	// Verify destination file
+	content, err := os.ReadFile(dstFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+
+	if string(content) != testContent {
+		t.Errorf("content mismatch: got %q, want %q", string(content), testContent)
+	}
+}
+
+// flakyReader fails its first read with err, as a network filesystem might,
+// and reads from r afterwards.
+type flakyReader struct {
+	r      io.Reader
+	err    error
+	failed bool
+}
+
+func (f *flakyReader) Read(p []byte) (int, error) {
+	if !f.failed {
+		f.failed = true
+		return 0, f.err
+	}
+	return f.r.Read(p)
+}
+
+func TestRetryCopy(t *testing.T) {
+	defer func(delay time.Duration) { copyRetryDelay = delay }(copyRetryDelay)
+	copyRetryDelay = 0
+
+	tests := []struct {
+		name         string
+		retries      int
+		err          error
+		wantErr      bool
+		wantAttempts int
+	}{
+		{name: "transient error retried", retries: 2, err: syscall.EIO, wantAttempts: 2},
+		{name: "wrapped transient error retried", retries: 2, err: &os.PathError{Op: "read", Path: "file", Err: syscall.EAGAIN}, wantAttempts: 2},
+		{name: "no retries", retries: 0, err: syscall.EIO, wantErr: true, wantAttempts: 1},
+		{name: "permanent error not retried", retries: 2, err: syscall.ENOSPC, wantErr: true, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &flakyReader{r: strings.NewReader("content"), err: tt.err}
+			var out bytes.Buffer
+			attempts, retried := 0, 0
+			err := retryCopy(tt.retries, func(int, error) { retried++ }, func() error {
+				attempts++
+				out.Reset()
+				_, err := io.Copy(&out, reader)
+				return err
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("retryCopy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts || retried != attempts-1 {
+				t.Errorf("attempts = %d, retried = %d, want %d attempts", attempts, retried, tt.wantAttempts)
+			}
+			if !tt.wantErr && out.String() != "content" {
+				t.Errorf("copied %q, want %q", out.String(), "content")
+			}
+		})
+	}
+}
+
+func TestRetryCopyGivesUp(t *testing.T) {
+	defer func(delay time.Duration) { copyRetryDelay = delay }(copyRetryDelay)
+	copyRetryDelay = 0
+
+	attempts := 0
+	err := retryCopy(2, func(int, error) {}, func() error {
+		attempts++
+		return syscall.EIO
+	})
+	if !errors.Is(err, syscall.EIO) {
+		t.Errorf("retryCopy() error = %v, want EIO", err)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestSyncFilesIncludePatterns(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{
+		"README.md":        "readme",
+		"logo.png":         "png",
+		"main.go":          "package main",
+		"docs/guide.md":    "guide",
+		"scripts/build.sh": "#!/bin/sh",
+	})
+
+	opts := syncOptions{Include: []string{"*.md", "*.png"}}
+	if _, err := syncFiles(srcDir, dstDir, opts); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+
+	for _, path := range []string{"README.md", "logo.png", "docs/guide.md"} {
+		if _, err := os.Stat(filepath.Join(dstDir, path)); err != nil {
+			t.Errorf("%s should be synced: %v", path, err)
+		}
+	}
+	for _, path := range []string{"main.go", "scripts/build.sh", "scripts"} {
+		if _, err := os.Stat(filepath.Join(dstDir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be synced", path)
+		}
+	}
+}
+
+func TestSyncFilesIncludeAndExclude(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{
+		"README.md":         "readme",
+		"CHANGELOG.md":      "changes",
+		"drafts/notes.md":   "draft",
+		"docs/guide.md":     "guide",
+		"docs/internal.txt": "internal",
+	})
+
+	opts := syncOptions{
+		Include: []string{"*.md"},
+		Exclude: []string{"CHANGELOG.md", "drafts"},
+	}
+	if _, err := syncFiles(srcDir, dstDir, opts); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+
+	for _, path := range []string{"README.md", "docs/guide.md"} {
+		if _, err := os.Stat(filepath.Join(dstDir, path)); err != nil {
+			t.Errorf("%s should be synced: %v", path, err)
+		}
+	}
+	for _, path := range []string{"CHANGELOG.md", "drafts/notes.md", "docs/internal.txt"} {
+		if _, err := os.Stat(filepath.Join(dstDir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be synced", path)
+		}
+	}
+}
+
+func TestSyncFilesExcludePatterns(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{
+		"keep.txt":      "keep",
+		"debug.log":     "log",
+		"tmp/cache.bin": "cache",
+	})
+
+	opts := syncOptions{Exclude: []string{"*.log", "tmp"}}
+	if _, err := syncFiles(srcDir, dstDir, opts); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dstDir, "keep.txt")); err != nil {
+		t.Errorf("keep.txt should be synced: %v", err)
+	}
+	for _, path := range []string{"debug.log", "tmp"} {
+		if _, err := os.Stat(filepath.Join(dstDir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be synced", path)
+		}
+	}
+}
+
+func TestSyncFilesNegatedExcludePatterns(t *testing.T) {
+	files := map[string]string{
+		"app.txt":              "app",
+		"logs/app.log":         "log",
+		"logs/keep.log":        "keep",
+		"logs/archive/old.log": "old",
+		"tmp/cache.bin":        "cache",
+	}
+
+	tests := []struct {
+		name    string
+		exclude []string
+		synced  []string
+		skipped []string
+	}{
+		{
+			name:    "exclude then negate",
+			exclude: []string{"logs/**", "!logs/keep.log", "tmp"},
+			synced:  []string{"app.txt", "logs/keep.log"},
+			skipped: []string{"logs/app.log", "logs/archive", "tmp"},
+		},
+		{
+			name:    "negate then exclude",
+			exclude: []string{"!logs/keep.log", "logs/**", "tmp"},
+			synced:  []string{"app.txt"},
+			skipped: []string{"logs", "tmp"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcDir := t.TempDir()
+			dstDir := t.TempDir()
+			createTestFiles(t, srcDir, files)
+
+			if _, err := syncFiles(srcDir, dstDir, syncOptions{Exclude: tt.exclude}); err != nil {
+				t.Fatalf("syncFiles() failed: %v", err)
+			}
+			for _, path := range tt.synced {
+				if _, err := os.Stat(filepath.Join(dstDir, path)); err != nil {
+					t.Errorf("%s should be synced: %v", path, err)
+				}
+			}
+			for _, path := range tt.skipped {
+				if _, err := os.Stat(filepath.Join(dstDir, path)); !os.IsNotExist(err) {
+					t.Errorf("%s should not be synced", path)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncFilesKeepEmptyDirs(t *testing.T) {
+	// Push: empty directories get a .gitkeep placeholder
+	srcDir := t.TempDir()
+	repoDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{"docs/readme.md": "readme"})
+	if err := os.MkdirAll(filepath.Join(srcDir, "data", "cache", "empty"), 0755); err != nil {
+		t.Fatalf("failed to create empty directory: %v", err)
+	}
+
+	if _, err := syncFiles(srcDir, repoDir, syncOptions{AddGitKeep: true}); err != nil {
+		t.Fatalf("syncFiles() push failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(repoDir, "data", "cache", "empty", gitKeepFile)); err != nil {
+		t.Errorf("nested empty directory should contain %s: %v", gitKeepFile, err)
+	}
+	for _, dir := range []string{"docs", "data", filepath.Join("data", "cache")} {
+		if _, err := os.Stat(filepath.Join(repoDir, dir, gitKeepFile)); !os.IsNotExist(err) {
+			t.Errorf("non-empty directory %s should not contain %s", dir, gitKeepFile)
+		}
+	}
+
+	// Pull: placeholders are dropped and the empty directories recreated
+	dstDir := t.TempDir()
+	if _, err := syncFiles(repoDir, dstDir, syncOptions{StripGitKeep: true}); err != nil {
+		t.Fatalf("syncFiles() pull failed: %v", err)
+	}
+
+	emptyDir := filepath.Join(dstDir, "data", "cache", "empty")
+	empty, err := isEmptyDir(emptyDir)
+	if err != nil {
+		t.Fatalf("nested empty directory should be recreated: %v", err)
+	}
+	if !empty {
+		t.Errorf("recreated directory %s should be empty", emptyDir)
+	}
+}
+
+// createTestFiles writes the given files (keyed by slash-separated relative
+// path) below baseDir, creating parent directories as needed.
+func createTestFiles(t testing.TB, baseDir string, files map[string]string) {
+	t.Helper()
+
+	for path, content := range files {
+		fullPath := filepath.Join(baseDir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("failed to create directories for %s: %v", path, err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file %s: %v", path, err)
+		}
+	}
+}
+
+func TestSyncFilesSkipUnchanged(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{
+		"same.txt":    "unchanged",
+		"changed.txt": "new content",
+		"new.txt":     "brand new",
+	})
+	createTestFiles(t, dstDir, map[string]string{
+		"same.txt":    "unchanged",
+		"changed.txt": "old content",
+	})
+
+	// Backdate the identical file so a rewrite would be visible
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	samePath := filepath.Join(dstDir, "same.txt")
+	if err := os.Chtimes(samePath, old, old); err != nil {
+		t.Fatalf("failed to backdate file: %v", err)
+	}
+
+	counts, err := syncFiles(srcDir, dstDir, syncOptions{SkipUnchanged: true})
+	if err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+	if counts.Copied != 2 || counts.Skipped != 1 {
+		t.Errorf("counts = %+v, want 2 copied and 1 skipped", counts)
+	}
+
+	info, err := os.Stat(samePath)
+	if err != nil {
+		t.Fatalf("failed to stat same.txt: %v", err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("same.txt was rewritten: mtime %v, want %v", info.ModTime(), old)
+	}
+
+	for path, want := range map[string]string{
+		"changed.txt": "new content",
+		"new.txt":     "brand new",
+	} {
+		content, err := os.ReadFile(filepath.Join(dstDir, path))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		if string(content) != want {
+			t.Errorf("%s content = %q, want %q", path, string(content), want)
+		}
+	}
+}
+
+func TestSyncFilesCopiesEverythingWithoutSkipUnchanged(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{"same.txt": "unchanged"})
+	createTestFiles(t, dstDir, map[string]string{"same.txt": "unchanged"})
+
+	counts, err := syncFiles(srcDir, dstDir, syncOptions{})
+	if err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+	if counts.Copied != 1 || counts.Skipped != 0 {
+		t.Errorf("counts = %+v, want 1 copied and 0 skipped", counts)
+	}
+}
+
+func TestSyncFilesMaxFileSize(t *testing.T) {
+	newSource := func(t *testing.T) string {
+		srcDir := t.TempDir()
+		createTestFiles(t, srcDir, map[string]string{
+			"small.txt":      "12345",
+			"dumps/huge.bin": "0123456789",
+		})
+		return srcDir
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		dstDir := t.TempDir()
+		counts, err := syncFiles(newSource(t), dstDir, syncOptions{MaxFileSize: 5})
+		if err != nil {
+			t.Fatalf("syncFiles() failed: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dstDir, "small.txt")); err != nil {
+			t.Errorf("small.txt is within the limit and should be synced: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dstDir, "dumps", "huge.bin")); !os.IsNotExist(err) {
+			t.Error("huge.bin exceeds the limit and should be skipped")
+		}
+		if len(counts.Oversized) != 1 || counts.Oversized[0] != filepath.Join("dumps", "huge.bin") {
+			t.Errorf("Oversized = %v, want [dumps/huge.bin]", counts.Oversized)
+		}
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		_, err := syncFiles(newSource(t), t.TempDir(), syncOptions{MaxFileSize: 5, FailOversize: true})
+		if !errors.Is(err, ErrFileTooLarge) {
+			t.Errorf("syncFiles() error = %v, want ErrFileTooLarge", err)
+		}
+	})
+
+	t.Run("under limit", func(t *testing.T) {
+		counts, err := syncFiles(newSource(t), t.TempDir(), syncOptions{MaxFileSize: 10, FailOversize: true})
+		if err != nil {
+			t.Fatalf("syncFiles() failed: %v", err)
+		}
+		if counts.Copied != 2 || len(counts.Oversized) != 0 {
+			t.Errorf("counts = %+v, want both files copied", counts)
+		}
+	})
+}
+
+func TestSyncFile(t *testing.T) {
+	srcDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{"notes.txt": "notes", "other.txt": "other"})
+
+	dstDir := t.TempDir()
+	counts, err := syncFile(filepath.Join(srcDir, "notes.txt"), dstDir, syncOptions{})
+	if err != nil {
+		t.Fatalf("syncFile() failed: %v", err)
+	}
+	if counts.Copied != 1 {
+		t.Errorf("Copied = %d, want 1", counts.Copied)
+	}
+	content, err := os.ReadFile(filepath.Join(dstDir, "notes.txt"))
+	if err != nil || string(content) != "notes" {
+		t.Errorf("notes.txt = %q, %v; want the source content", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "other.txt")); !os.IsNotExist(err) {
+		t.Error("only the given file should be synced")
+	}
+
+	counts, err = syncFile(filepath.Join(srcDir, "notes.txt"), t.TempDir(), syncOptions{Exclude: []string{"*.txt"}})
+	if err != nil || counts.Copied != 0 {
+		t.Errorf("syncFile() = %+v, %v; want an excluded file to be skipped", counts, err)
+	}
+}
+
+func TestRemoveExtraneous(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        syncOptions
+		wantRemoved []string
+		wantKept    []string
+	}{
+		{
+			name:        "mirror",
+			wantRemoved: []string{"old", "stale.txt"},
+			wantKept:    []string{"keep.txt", "docs/guide.md", "local.env", ".git/config"},
+		},
+		{
+			name:        "exclude keeps paths",
+			opts:        syncOptions{Exclude: []string{"stale.txt"}},
+			wantRemoved: []string{"old"},
+			wantKept:    []string{"keep.txt", "stale.txt", ".git/config"},
+		},
+		{
+			name:        "include limits removals",
+			opts:        syncOptions{Include: []string{"*.txt"}},
+			wantRemoved: []string{filepath.Join("old", "notes.txt"), "stale.txt"},
+			wantKept:    []string{"keep.txt", "old/photo.png", "local.env"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcDir := t.TempDir()
+			createTestFiles(t, srcDir, map[string]string{
+				"keep.txt":      "keep",
+				"docs/guide.md": "guide",
+				"local.env":     "env",
+			})
+			dstDir := t.TempDir()
+			createTestFiles(t, dstDir, map[string]string{
+				"keep.txt":      "keep",
+				"docs/guide.md": "guide",
+				"local.env":     "env",
+				"stale.txt":     "stale",
+				"old/notes.txt": "notes",
+				"old/photo.png": "photo",
+				".git/config":   "config",
+			})
+
+			removed, err := removeExtraneous(srcDir, dstDir, tt.opts)
+			if err != nil {
+				t.Fatalf("removeExtraneous() failed: %v", err)
+			}
+			slices.Sort(removed)
+			if !slices.Equal(removed, tt.wantRemoved) {
+				t.Errorf("removed = %q, want %q", removed, tt.wantRemoved)
+			}
+			for _, path := range tt.wantRemoved {
+				if _, err := os.Stat(filepath.Join(dstDir, path)); !os.IsNotExist(err) {
+					t.Errorf("%s should have been removed", path)
+				}
+			}
+			for _, path := range tt.wantKept {
+				if _, err := os.Stat(filepath.Join(dstDir, path)); err != nil {
+					t.Errorf("%s should have been kept: %v", path, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCopyFileReadOnlyDestination(t *testing.T) {
+	tests := []struct {
+		name    string
+		force   bool
+		staged  bool
+		clone   bool
+		wantErr bool
+	}{
+		{name: "without -force-overwrite", wantErr: true},
+		{name: "with -force-overwrite", force: true},
+		{name: "staged without -force-overwrite", staged: true, wantErr: true},
+		{name: "staged with -force-overwrite", staged: true, force: true},
+		// Push overwrites the files of its own clone
+		{name: "clone", clone: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcDir, dstDir := t.TempDir(), t.TempDir()
+			createTestFiles(t, srcDir, map[string]string{"locked.txt": "new"})
+			createTestFiles(t, dstDir, map[string]string{"locked.txt": "old"})
+			dst := filepath.Join(dstDir, "locked.txt")
+			if err := os.Chmod(dst, 0444); err != nil {
+				t.Fatalf("failed to make destination read-only: %v", err)
+			}
+			// Let the temporary directory be removed
+			t.Cleanup(func() { os.Chmod(dst, 0644) })
+
+			opts := syncOptions{ProtectReadOnly: !tt.clone, ForceOverwrite: tt.force}
+			if tt.staged {
+				staging, err := newStagedWrites(dstDir)
+				if err != nil {
+					t.Fatalf("newStagedWrites() failed: %v", err)
+				}
+				opts.Staging = staging
+			}
+			err := copyFile(filepath.Join(srcDir, "locked.txt"), dst, 0644, opts)
+			if err == nil {
+				err = opts.Staging.commit()
+			}
+
+			want, wantPerm := "new", os.FileMode(0444)
+			if tt.clone {
+				wantPerm = 0644
+			}
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), dst) || !strings.Contains(err.Error(), "-force-overwrite") {
+					t.Fatalf("copyFile() error = %v, want one naming %s and -force-overwrite", err, dst)
+				}
+				want = "old"
+			} else if err != nil {
+				t.Fatalf("copyFile() failed: %v", err)
+			}
+
+			content, err := os.ReadFile(dst)
+			if err != nil {
+				t.Fatalf("failed to read destination: %v", err)
+			}
+			if string(content) != want {
+				t.Errorf("destination = %q, want %q", content, want)
+			}
+			info, err := os.Stat(dst)
+			if err != nil {
+				t.Fatalf("failed to stat destination: %v", err)
+			}
+			if info.Mode().Perm() != wantPerm {
+				t.Errorf("destination mode = %v, want %v", info.Mode().Perm(), wantPerm)
+			}
+		})
+	}
+}
+
+func TestSyncFilesSkipsIdenticalReadOnlyFile(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{"locked.txt": "same"})
+	createTestFiles(t, dstDir, map[string]string{"locked.txt": "same"})
+	dst := filepath.Join(dstDir, "locked.txt")
+	if err := os.Chmod(dst, 0444); err != nil {
+		t.Fatalf("failed to make destination read-only: %v", err)
+	}
+	// Let the temporary directory be removed
+	t.Cleanup(func() { os.Chmod(dst, 0644) })
+
+	// Without -force-overwrite, the unchanged file must not even be written
+	opts := syncOptions{SkipUnchanged: true, ProtectReadOnly: true}
+	counts, err := syncFiles(srcDir, dstDir, opts)
+	if err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+	if counts.Skipped != 1 || counts.Copied != 0 {
+		t.Errorf("counts = %+v, want the read-only file skipped", counts)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("failed to stat destination: %v", err)
+	}
+	if info.Mode().Perm() != 0444 {
+		t.Errorf("destination mode = %v, want 0444", info.Mode().Perm())
+	}
+}
+
+func TestCopyFileWithBuffer(t *testing.T) {
+	content := make([]byte, 3*1024+17)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	src := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+
+	for _, size := range []int{0, 1, 1000, 1024 * 1024} {
+		t.Run(fmt.Sprintf("buffer=%d", size), func(t *testing.T) {
+			dst := filepath.Join(t.TempDir(), "data.bin")
+			if err := copyFile(src, dst, 0644, syncOptions{CopyBuffer: size}); err != nil {
+				t.Fatalf("copyFile() failed: %v", err)
+			}
+			got, err := os.ReadFile(dst)
+			if err != nil {
+				t.Fatalf("failed to read copy: %v", err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Error("copied content differs from the source")
+			}
+		})
+	}
+}
+
+func BenchmarkCopyFile(b *testing.B) {
+	src := filepath.Join(b.TempDir(), "large.bin")
+	if err := os.WriteFile(src, make([]byte, 64*1024*1024), 0644); err != nil {
+		b.Fatalf("failed to write source: %v", err)
+	}
+	dst := filepath.Join(b.TempDir(), "large.bin")
+
+	for _, bench := range []struct {
+		name string
+		size int
+	}{
+		{name: "default", size: 0},
+		{name: "1MB", size: 1024 * 1024},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.SetBytes(64 * 1024 * 1024)
+			for b.Loop() {
+				if err := copyFile(src, dst, 0644, syncOptions{CopyBuffer: bench.size}); err != nil {
+					b.Fatalf("copyFile() failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncFilesSyncsGitignore(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{
+		".gitignore": "*.log\n",
+		".git/HEAD":  "ref: refs/heads/main\n",
+	})
+
+	if _, err := syncFiles(srcDir, dstDir, syncOptions{}); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dstDir, ".gitignore")); err != nil {
+		t.Errorf(".gitignore should be synced: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, ".git")); !os.IsNotExist(err) {
+		t.Error(".git should not be synced")
+	}
+}
+
+func TestSyncFilesSkipsOnlyGitDirectory(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{
+		".gitignore":               "*.log\n",
+		".gitattributes":           "* text=auto\n",
+		".github/workflows/ci.yml": "name: ci\n",
+		".git/config":              "[core]\n",
+	})
+
+	if _, err := syncFiles(srcDir, dstDir, syncOptions{}); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+
+	for _, path := range []string{".gitignore", ".gitattributes", ".github/workflows/ci.yml"} {
+		if _, err := os.Stat(filepath.Join(dstDir, filepath.FromSlash(path))); err != nil {
+			t.Errorf("%s should be synced: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, ".git", "config")); !os.IsNotExist(err) {
+		t.Error(".git/config should not be synced")
+	}
+}
+
+func TestSyncFilesMaxDepth(t *testing.T) {
+	files := map[string]string{
+		"top.txt":         "top",
+		"a/one.txt":       "one",
+		"a/b/two.txt":     "two",
+		"a/b/c/three.txt": "three",
+	}
+
+	tests := []struct {
+		name   string
+		opts   syncOptions
+		synced []string
+		absent []string
+	}{
+		{
+			name:   "depth 0",
+			opts:   syncOptions{LimitDepth: true, MaxDepth: 0},
+			synced: []string{"top.txt"},
+			absent: []string{"a"},
+		},
+		{
+			name:   "depth 1",
+			opts:   syncOptions{LimitDepth: true, MaxDepth: 1},
+			synced: []string{"top.txt", "a/one.txt"},
+			absent: []string{"a/b"},
+		},
+		{
+			name:   "unlimited",
+			opts:   syncOptions{},
+			synced: []string{"top.txt", "a/one.txt", "a/b/two.txt", "a/b/c/three.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcDir := t.TempDir()
+			dstDir := t.TempDir()
+			createTestFiles(t, srcDir, files)
+
+			if _, err := syncFiles(srcDir, dstDir, tt.opts); err != nil {
+				t.Fatalf("syncFiles() failed: %v", err)
+			}
+			for _, path := range tt.synced {
+				if _, err := os.Stat(filepath.Join(dstDir, path)); err != nil {
+					t.Errorf("%s should be synced: %v", path, err)
+				}
+			}
+			for _, path := range tt.absent {
+				if _, err := os.Stat(filepath.Join(dstDir, path)); !os.IsNotExist(err) {
+					t.Errorf("%s should not be synced", path)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+//go:build unix
+
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// createFIFO makes a named pipe at path. Opening it for reading blocks until
+// a writer appears, so a sync that tried to copy it would hang.
+func createFIFO(t *testing.T, path string) {
+	t.Helper()
+	if err := syscall.Mkfifo(path, 0644); err != nil {
+		t.Skipf("cannot create FIFO: %v", err)
+	}
+}
+
+func TestSyncFilesSkipsSpecialFiles(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{"notes.txt": "notes"})
+	createFIFO(t, filepath.Join(srcDir, "pipe"))
+	if err := os.Symlink("pipe", filepath.Join(srcDir, "link")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	counts, err := syncFiles(srcDir, dstDir, syncOptions{})
+	if err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+	if want := []string{"link", "pipe"}; !slices.Equal(counts.Special, want) {
+		t.Errorf("Special = %v, want %v", counts.Special, want)
+	}
+	if counts.Copied != 1 {
+		t.Errorf("Copied = %d, want 1", counts.Copied)
+	}
+	for _, name := range []string{"pipe", "link"} {
+		if _, err := os.Lstat(filepath.Join(dstDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be synced", name)
+		}
+	}
+	if got := readTestFiles(t, dstDir, "notes.txt"); got["notes.txt"] != "notes" {
+		t.Errorf("notes.txt = %q, want %q", got["notes.txt"], "notes")
+	}
+}
+
+func TestSyncFilesStrictFailsOnSpecialFiles(t *testing.T) {
+	srcDir := t.TempDir()
+	createFIFO(t, filepath.Join(srcDir, "pipe"))
+
+	_, err := syncFiles(srcDir, t.TempDir(), syncOptions{FailSpecial: true})
+	if !errors.Is(err, ErrSpecialFile) {
+		t.Fatalf("syncFiles() error = %v, want ErrSpecialFile", err)
+	}
+	if !strings.Contains(err.Error(), "pipe is a FIFO") {
+		t.Errorf("error = %q, want it to name the FIFO", err)
+	}
+}
+
+func TestPushWarnsAboutSpecialFiles(t *testing.T) {
+	installFakeGit(t, ":")
+	folder := t.TempDir()
+	createFIFO(t, filepath.Join(folder, "pipe"))
+
+	var logs bytes.Buffer
+	s := newTestSyncer(t, Config{Mode: ModePush, FolderPath: folder, RepoURL: "https://github.com/user/repo.git", Branch: "main"})
+	s.logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	src := pushSource{absPath: folder, opts: s.config.syncOptions()}
+	if _, err := s.pushBranch(context.Background(), t.TempDir(), src); !errors.Is(err, ErrNoChanges) {
+		t.Fatalf("pushBranch() error = %v, want ErrNoChanges", err)
+	}
+	if !strings.Contains(logs.String(), "level=WARN msg=\"Skipping special file") || !strings.Contains(logs.String(), "path=pipe") {
+		t.Errorf("missing warning about the FIFO in logs:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,336 @@
+package syncer
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// matchPattern reports whether relPath matches the glob pattern.
+// relPath must use forward slashes. Patterns without a slash are matched
+// against the base name at any depth (so "*.md" matches "docs/readme.md"),
+// while patterns containing a slash are anchored at the sync root.
+// A "**" segment matches any number of path segments.
+func matchPattern(pattern, relPath string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, err := path.Match(pattern, path.Base(relPath))
+		return err == nil && ok
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(relPath, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		ok, err := path.Match(pattern[0], segments[0])
+		if err != nil || !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// matchAny reports whether relPath matches at least one of the patterns.
+func matchAny(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchExclude evaluates exclude patterns in order against relPath, which
+// must use forward slashes. A pattern matches relPath or any of its parent
+// directories; a "!"-prefixed pattern re-includes what it matches. The last
+// matching pattern decides, so "logs/**" followed by "!logs/keep.log"
+// excludes the logs directory except for keep.log. It also returns the
+// index of the deciding pattern, or -1 if none matched.
+func matchExclude(patterns []string, relPath string) (bool, int) {
+	excluded, last := false, -1
+	for i, pattern := range patterns {
+		pattern, negate := strings.CutPrefix(pattern, "!")
+		if matchPathOrParent(pattern, relPath) {
+			excluded, last = !negate, i
+		}
+	}
+	return excluded, last
+}
+
+// matchPathOrParent reports whether pattern matches slashPath or one of its
+// parent directories.
+func matchPathOrParent(pattern, slashPath string) bool {
+	for i, c := range slashPath {
+		if c == '/' && matchPattern(pattern, slashPath[:i]) {
+			return true
+		}
+	}
+	return matchPattern(pattern, slashPath)
+}
+
+// reincludesBelow reports whether a "!" pattern could match a path inside
+// the directory dir, in which case an excluded dir must still be walked.
+// Patterns without a slash match at any depth, so they always could.
+func reincludesBelow(patterns []string, dir string) bool {
+	for _, pattern := range patterns {
+		negated, ok := strings.CutPrefix(pattern, "!")
+		if !ok {
+			continue
+		}
+		if !strings.Contains(negated, "/") {
+			return true
+		}
+		negated = strings.TrimPrefix(negated, "/")
+		if matchLeadingSegments(strings.Split(negated, "/"), strings.Split(dir, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLeadingSegments reports whether segments can be followed by more
+// segments to form a path that matches pattern.
+func matchLeadingSegments(pattern, segments []string) bool {
+	for ; len(segments) > 0; pattern, segments = pattern[1:], segments[1:] {
+		if len(pattern) == 0 {
+			return false
+		}
+		if pattern[0] == "**" {
+			return true
+		}
+		if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+			return false
+		}
+	}
+	return len(pattern) > 0
+}
+
+// hasNegation reports whether any of the exclude patterns starts with "!".
+func hasNegation(patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			return true
+		}
+	}
+	return false
+}
+
+// syncOptions controls which files syncFiles copies and how.
+type syncOptions struct {
+	// Include limits the sync to files matching at least one pattern.
+	// Directories are always traversed so nested matches are found.
+	Include []string
+	// Exclude skips matching files and directories. Exclude wins over Include.
+	// Patterns starting with "!" re-include paths, see matchExclude.
+	Exclude []string
+	// AddGitKeep writes a .gitkeep placeholder into every empty source
+	// directory so that git tracks it (push with -keep-empty-dirs).
+	AddGitKeep bool
+	// StripGitKeep skips .gitkeep placeholders and removes them from the
+	// destination, leaving the empty directories behind (pull with -keep-empty-dirs).
+	StripGitKeep bool
+	// RateLimit caps file copy throughput in bytes per second. Zero means unlimited.
+	RateLimit int64
+	// CopyBuffer is the size of the pooled buffer each file is copied
+	// through (-copy-buffer). Zero uses io.Copy.
+	CopyBuffer int
+	// SkipUnchanged leaves destination files alone when their content
+	// already matches the source (pull with -skip-unchanged).
+	SkipUnchanged bool
+	// HashCache, if set, supplies the digests of unchanged destination
+	// files for SkipUnchanged (pull with -hash-cache).
+	HashCache *hashCache
+	// ChecksumAlgo is the algorithm SkipUnchanged compares content with
+	// (-checksum-algo). Empty means SHA-256.
+	ChecksumAlgo string
+	// TemplateSuffix, if set, marks files that are rendered with
+	// TemplateData as text/template data instead of copied, and written
+	// without the suffix (pull with -render-templates).
+	TemplateSuffix string
+	TemplateData   map[string]string
+	// ExportIgnore holds the source's .gitattributes export-ignore
+	// patterns, which are skipped like exclude patterns (push with -export-ignore).
+	ExportIgnore []string
+	// MaxFileSize skips files larger than this many bytes, or fails the
+	// sync with ErrFileTooLarge if FailOversize is set. Zero means no limit.
+	MaxFileSize  int64
+	FailOversize bool
+	// Ignore holds the cloned repository's .gitignore rules; ignored paths
+	// are never written to the work tree (push with -respect-repo-gitignore).
+	Ignore ignoreRules
+	// Progress, if set, receives periodic progress updates (-progress).
+	Progress func(syncProgress)
+	// Staging, if set, collects the written files in a staging directory
+	// until the caller commits them (pull).
+	Staging *stagedWrites
+	// FileCopied, if set, is called for every file written to the
+	// destination dst, with its size and whether it was newly created.
+	FileCopied func(dst string, size int64, created bool)
+	// CopyRetries is how many times a file copy that failed with a
+	// transient error is retried (-copy-retries). CopyRetried, if set, is
+	// called before every retry.
+	CopyRetries int
+	CopyRetried func(src string, attempt int, err error)
+	// FailSpecial fails the sync with ErrSpecialFile on FIFOs, sockets and
+	// devices instead of skipping them (-strict).
+	FailSpecial bool
+	// ProtectReadOnly refuses to replace read-only destination files, as
+	// the destination is the user's folder (pull and sync). ForceOverwrite
+	// replaces them anyway, keeping their permissions (-force-overwrite).
+	ProtectReadOnly bool
+	ForceOverwrite  bool
+	// LongPaths writes through \\?\ paths on Windows, raising the path length
+	// limit from MAX_PATH to 32767 characters (-long-paths).
+	LongPaths bool
+	// Submodules also skips the .git files that link checked-out
+	// submodules to their repositories (pull with -recurse-submodules).
+	Submodules bool
+	// PreserveOwner and PreserveXattrs copy each file's owner and extended
+	// attributes to the destination (pull with -preserve-owner and
+	// -preserve-xattrs). Failures are reported to MetadataWarning instead
+	// of failing the sync.
+	PreserveOwner   bool
+	PreserveXattrs  bool
+	MetadataWarning func(relPath string, err error)
+	// MaxDepth skips files more than MaxDepth directories below the root
+	// if LimitDepth is set (-max-depth). Depth 0 keeps only top-level files.
+	MaxDepth   int
+	LimitDepth bool
+	// Sparse limits the sync to these slash-separated directories, the
+	// only ones a sparse checkout materializes (pull with -sparse).
+	Sparse []string
+}
+
+// skipped reports whether the walk should ignore relPath entirely: the .git
+// directory, submodule .git links, the hash cache, sync manifest and staging
+// directory, paths below MaxDepth, paths outside the sparse checkout, excluded
+// paths and paths ignored by the repository.
+func (o syncOptions) skipped(relPath string, isDir bool) bool {
+	// Compare the first path element so that .gitignore and the like are
+	// synced; ToSlash makes this work with Windows separators too
+	slashPath := filepath.ToSlash(relPath)
+	first, _, _ := strings.Cut(slashPath, "/")
+	if first == ".git" {
+		return true
+	}
+	if o.Submodules && path.Base(slashPath) == ".git" {
+		return true
+	}
+	if slashPath == hashCacheFile || slashPath == syncManifestFile || slashPath == stagingDir {
+		return true
+	}
+	if o.tooDeep(slashPath, isDir) || o.outsideSparse(slashPath, isDir) {
+		return true
+	}
+	return o.excluded(relPath, isDir) || o.Ignore.ignored(relPath, isDir)
+}
+
+// tooDeep reports whether slashPath lies beyond MaxDepth. A directory at
+// MaxDepth is already too deep, as everything in it is one level further down.
+func (o syncOptions) tooDeep(slashPath string, isDir bool) bool {
+	if !o.LimitDepth {
+		return false
+	}
+	depth := strings.Count(slashPath, "/")
+	if isDir {
+		depth++
+	}
+	return depth > o.MaxDepth
+}
+
+// oversized reports whether a file of size bytes exceeds MaxFileSize. With
+// FailOversize set it returns ErrFileTooLarge instead.
+func (o syncOptions) oversized(relPath string, size int64) (bool, error) {
+	if o.MaxFileSize <= 0 || size <= o.MaxFileSize {
+		return false, nil
+	}
+	if o.FailOversize {
+		return false, fmt.Errorf("%w: %s is %d bytes, the limit is %d", ErrFileTooLarge, relPath, size, o.MaxFileSize)
+	}
+	return true, nil
+}
+
+// special reports whether the file at path, relPath below the root, is a
+// FIFO, socket, device or other special file, or a symlink to one. Copying
+// it would block or fail, so it is skipped, unless FailSpecial is set, in
+// which case it returns ErrSpecialFile.
+func (o syncOptions) special(relPath, path string, info os.FileInfo) (bool, error) {
+	mode := info.Mode()
+	if mode&os.ModeSymlink != 0 {
+		target, err := os.Stat(path)
+		if err != nil {
+			// A broken link fails when it is copied
+			return false, nil
+		}
+		mode = target.Mode()
+	}
+	if mode.IsRegular() || mode.IsDir() {
+		return false, nil
+	}
+	if o.FailSpecial {
+		return false, fmt.Errorf("%w: %s is a %s", ErrSpecialFile, relPath, specialKind(mode))
+	}
+	return true, nil
+}
+
+// specialKind names the type of a special file for error messages.
+func specialKind(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return "FIFO"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	default:
+		return "special file"
+	}
+}
+
+// excluded reports whether relPath should be skipped because it matches an
+// exclude pattern or is marked export-ignore. An excluded directory that
+// holds paths a "!" pattern may re-include is not skipped, so that the walk
+// reaches them; its other files are excluded one by one.
+func (o syncOptions) excluded(relPath string, isDir bool) bool {
+	relPath = filepath.ToSlash(relPath)
+	if matchAny(o.ExportIgnore, relPath) {
+		return true
+	}
+	excluded, last := matchExclude(o.Exclude, relPath)
+	if !excluded {
+		return false
+	}
+	// Only negations after the pattern that excluded the directory count
+	return !isDir || !reincludesBelow(o.Exclude[last+1:], relPath)
+}
+
+// dirsOnDemand reports whether directories are created only for the files
+// synced into them rather than as they are walked. With include patterns or
+// re-included excludes, a walked directory may hold no synced file at all.
+func (o syncOptions) dirsOnDemand() bool {
+	return len(o.Include) > 0 || hasNegation(o.Exclude)
+}
+
+// included reports whether a file at relPath passes the include patterns.
+// With no include patterns every file is included.
+func (o syncOptions) included(relPath string) bool {
+	return len(o.Include) == 0 || matchAny(o.Include, filepath.ToSlash(relPath))
+}
@@ -0,0 +1,158 @@
+package syncer
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		path    string
+		want    bool
+	}{
+		{name: "base name at root", pattern: "*.md", path: "README.md", want: true},
+		{name: "base name nested", pattern: "*.md", path: "docs/guide.md", want: true},
+		{name: "base name mismatch", pattern: "*.md", path: "docs/guide.txt", want: false},
+		{name: "anchored path", pattern: "docs/*.md", path: "docs/guide.md", want: true},
+		{name: "anchored path not nested", pattern: "docs/*.md", path: "other/docs/guide.md", want: false},
+		{name: "leading slash anchors", pattern: "/build", path: "build", want: true},
+		{name: "leading slash does not match nested", pattern: "/build", path: "src/build", want: false},
+		{name: "double star suffix", pattern: "logs/**", path: "logs/app/today.log", want: true},
+		{name: "double star prefix", pattern: "**/cache/*", path: "a/b/cache/item", want: true},
+		{name: "double star matches zero segments", pattern: "**/cache/*", path: "cache/item", want: true},
+		{name: "double star middle", pattern: "src/**/*.go", path: "src/pkg/sub/file.go", want: true},
+		{name: "double star mismatch", pattern: "src/**/*.go", path: "lib/file.go", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchPattern(tt.pattern, tt.path); got != tt.want {
+				t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchExclude(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		want     bool
+	}{
+		{name: "exclude", patterns: []string{"logs/**"}, path: "logs/app.log", want: true},
+		{name: "exclude then negate", patterns: []string{"logs/**", "!logs/keep.log"}, path: "logs/keep.log", want: false},
+		{name: "negation leaves other files excluded", patterns: []string{"logs/**", "!logs/keep.log"}, path: "logs/app.log", want: true},
+		{name: "negate then exclude", patterns: []string{"!logs/keep.log", "logs/**"}, path: "logs/keep.log", want: true},
+		{name: "negation without exclude", patterns: []string{"!logs/keep.log"}, path: "logs/keep.log", want: false},
+		{name: "excluded parent directory", patterns: []string{"build"}, path: "build/out/app", want: true},
+		{name: "base name negation below excluded directory", patterns: []string{"build", "!*.txt"}, path: "build/out/notes.txt", want: false},
+		{name: "base name negation leaves other files", patterns: []string{"build", "!*.txt"}, path: "build/out/app", want: true},
+		{name: "exclude again after negation", patterns: []string{"*.log", "!keep.log", "logs/**"}, path: "logs/keep.log", want: true},
+		{name: "negation of the directory", patterns: []string{"logs/**", "!logs"}, path: "logs/app.log", want: false},
+		{name: "escaped exclamation mark", patterns: []string{`\!important.txt`}, path: "!important.txt", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := matchExclude(tt.patterns, tt.path); got != tt.want {
+				t.Errorf("matchExclude(%q, %q) = %v, want %v", tt.patterns, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkippedKeepsDirectoriesWithReincludedFiles(t *testing.T) {
+	opts := syncOptions{Exclude: []string{"logs/**", "!logs/keep.log"}}
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		// logs is walked to reach keep.log, but nothing else in it
+		{path: "logs", isDir: true, want: false},
+		{path: "logs/keep.log", want: false},
+		{path: "logs/app.log", want: true},
+		{path: "logs/archive", isDir: true, want: true},
+		{path: "src/main.go", want: false},
+	}
+	for _, tt := range tests {
+		if got := opts.skipped(filepath.FromSlash(tt.path), tt.isDir); got != tt.want {
+			t.Errorf("skipped(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	// Without a later negation, the directory is skipped as a whole
+	opts = syncOptions{Exclude: []string{"!logs/keep.log", "logs"}}
+	if !opts.skipped("logs", true) {
+		t.Error("skipped(logs) = false, want the directory excluded when the negation comes first")
+	}
+}
+
+func TestSkippedSubmoduleGitLinks(t *testing.T) {
+	opts := syncOptions{Submodules: true}
+	tests := []struct {
+		relPath string
+		want    bool
+	}{
+		{relPath: ".git", want: true},
+		{relPath: filepath.Join("lib", ".git"), want: true},
+		{relPath: filepath.Join("lib", "nested", ".git"), want: true},
+		{relPath: filepath.Join("lib", ".gitmodules"), want: false},
+		{relPath: filepath.Join("lib", "main.go"), want: false},
+		{relPath: ".gitmodules", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := opts.skipped(tt.relPath, false); got != tt.want {
+			t.Errorf("skipped(%q) = %v, want %v", tt.relPath, got, tt.want)
+		}
+	}
+}
+
+func TestSkippedOnlyMatchesGitDirectory(t *testing.T) {
+	tests := []struct {
+		relPath string
+		want    bool
+	}{
+		{relPath: ".git", want: true},
+		{relPath: filepath.Join(".git", "config"), want: true},
+		{relPath: ".git/objects/pack", want: true},
+		{relPath: ".gitignore", want: false},
+		{relPath: ".gitattributes", want: false},
+		{relPath: filepath.Join(".github", "workflows", "ci.yml"), want: false},
+		{relPath: filepath.Join("docs", ".git"), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := (syncOptions{}).skipped(tt.relPath, false); got != tt.want {
+			t.Errorf("skipped(%q) = %v, want %v", tt.relPath, got, tt.want)
+		}
+	}
+}
+
+func TestSkippedOutsideSparse(t *testing.T) {
+	opts := syncOptions{Sparse: []string{"services/api", "docs"}}
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{path: "services", isDir: true, want: false},
+		{path: "services/api", isDir: true, want: false},
+		{path: "services/api/main.go", want: false},
+		{path: "docs/guide/intro.md", want: false},
+		{path: "services/web", isDir: true, want: true},
+		{path: "services/README.md", want: true},
+		{path: "services/api-old", isDir: true, want: true},
+		{path: "README.md", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := opts.skipped(filepath.FromSlash(tt.path), tt.isDir); got != tt.want {
+			t.Errorf("skipped(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// escapeShellArg escapes a string for safe use as a shell argument in GIT_SSH_COMMAND.
+// It uses backslash escaping for special shell characters to prevent command injection
+// while maintaining compatibility with how shells process environment variables.
+//
+// Example: "/path/with spaces/key.pem" -> "/path/with\\ spaces/key.pem"
+func escapeShellArg(s string) string {
+	// Characters that need escaping in shell
+	needsEscape := " \t\n\r\"'`$\\|&;<>(){}[]!*?"
+	var result strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(needsEscape, c) {
+			result.WriteRune('\\')
+		}
+		result.WriteRune(c)
+	}
+	return result.String()
+}
+
+// buildGitSSHCommand creates the GIT_SSH_COMMAND environment variable value
+// with the specified SSH key path, properly escaped for shell execution.
+// It includes StrictHostKeyChecking=accept-new to automatically accept new host keys
+// without prompting, while still verifying known hosts for security.
+func buildGitSSHCommand(sshKeyPath string) string {
+	return fmt.Sprintf("ssh -i %s -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new", escapeShellArg(sshKeyPath))
+}
+
+// buildAgentSSHCommand creates the GIT_SSH_COMMAND value used with -use-agent.
+// No identity file is passed, so ssh authenticates with the keys loaded into
+// the agent at SSH_AUTH_SOCK. BatchMode makes ssh fail instead of prompting
+// for a passphrase if the agent can't provide a usable key.
+func buildAgentSSHCommand() string {
+	return "ssh -o BatchMode=yes -o StrictHostKeyChecking=accept-new"
+}
+
+// command builds an exec.Cmd running in dir with the environment needed for
+// git operations.
+func (s *Syncer) command(ctx context.Context, dir string, name string, args ...string) *exec.Cmd {
+	s.logger.Debug("Running command", "dir", dir, "argv", redactArgs(append([]string{name}, args...)))
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	// Ensure environment is inherited for git credentials
+	cmd.Env = os.Environ()
+	// Set GIT_SSH_COMMAND if an SSH key, the agent or a proxy is used
+	var sshCommand string
+	switch {
+	case s.config.UseAgent:
+		sshCommand = buildAgentSSHCommand()
+	case s.sshKeyPath() != "":
+		sshCommand = buildGitSSHCommand(s.sshKeyPath())
+	}
+	if s.config.Proxy != "" {
+		if sshCommand == "" {
+			sshCommand = "ssh"
+		}
+		sshCommand += " -o " + escapeShellArg("ProxyCommand="+buildProxyCommand(s.config.Proxy))
+		cmd.Env = append(cmd.Env, proxyEnv(s.config.Proxy)...)
+	}
+	if sshCommand != "" {
+		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND="+sshCommand)
+	}
+	// Fail instead of prompting when the netrc has no matching credentials
+	if s.config.UseNetrc {
+		cmd.Env = append(cmd.Env, "GIT_TERMINAL_PROMPT=0")
+	}
+	// Run no repository hooks at all, including those a template installs
+	// into the clone or a global core.hooksPath points to. The setting is
+	// added after any the environment already passes through GIT_CONFIG_*.
+	if s.config.NoVerify {
+		n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+		cmd.Env = append(cmd.Env,
+			fmt.Sprintf("GIT_CONFIG_COUNT=%d", n+1),
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=core.hooksPath", n),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", n, os.DevNull))
+	}
+	return cmd
+}
+
+// runCommand runs a command, streaming its output to stdout and stderr with
+// credentials redacted.
+func (s *Syncer) runCommand(ctx context.Context, dir string, name string, args ...string) error {
+	_, err := s.runCommandStderr(ctx, dir, name, args...)
+	return err
+}
+
+// runCommandStderr runs a command like runCommand, streaming its output, and
+// additionally returns everything it wrote to stderr so failures can be
+// classified. The returned output is redacted as well.
+func (s *Syncer) runCommandStderr(ctx context.Context, dir string, name string, args ...string) (string, error) {
+	return s.runStderr(s.command(ctx, dir, name, args...))
+}
+
+// runStderr runs cmd like runCommandStderr, for callers that adjust the
+// command before it runs. When the run prints its result to stdout, the
+// command's output goes to stderr instead.
+func (s *Syncer) runStderr(cmd *exec.Cmd) (string, error) {
+	var stderr bytes.Buffer
+	out := os.Stdout
+	if s.config.PrintsResult() {
+		out = os.Stderr
+	}
+	stdout := newRedactWriter(out)
+	errOut := newRedactWriter(os.Stderr)
+	cmd.Stdout = stdout
+	cmd.Stderr = io.MultiWriter(errOut, &stderr)
+	err := cmd.Run()
+	stdout.Flush()
+	errOut.Flush()
+	return redact(stderr.String()), err
+}
+
+// runCommandOutput runs a command and returns its combined output with
+// credentials redacted.
+func (s *Syncer) runCommandOutput(ctx context.Context, dir string, name string, args ...string) (string, error) {
+	output, err := s.command(ctx, dir, name, args...).CombinedOutput()
+	return redact(string(output)), err
+}
+
+// pushArgs builds the git arguments that push the branch to origin. -force
+// uses --force-with-lease, which refuses to overwrite remote commits that
+// haven't been fetched; -force-unsafe overwrites unconditionally. -no-verify
+// skips the pre-push hook. A branch created by -branch-template is pushed
+// with --set-upstream.
+func pushArgs(config Config) []string {
+	args := []string{"push"}
+	switch {
+	case config.ForceUnsafe:
+		args = append(args, "--force")
+	case config.Force:
+		args = append(args, "--force-with-lease")
+	}
+	if config.NoVerify {
+		args = append(args, "--no-verify")
+	}
+	if config.BranchTemplate != "" {
+		args = append(args, "--set-upstream")
+	}
+	return append(args, "origin", config.Branch)
+}
+
+// indexArgs returns the git arguments args of a command that compares the
+// working tree with the index. With NoModeChanges, core.fileMode=false makes
+// git disregard executable-bit differences: tracked files keep their
+// recorded mode and new files are added as 100644. The setting is passed on
+// the command line so that a reused work dir keeps its own configuration.
+func (s *Syncer) indexArgs(args ...string) []string {
+	if s.config.NoModeChanges {
+		return append([]string{"-c", "core.fileMode=false"}, args...)
+	}
+	return args
+}
+
+// remoteBranches asks the remote which branches it has. An empty result
+// means the repository has no commits yet. An error means the remote could
+// not be queried at all, e.g. because authentication failed or the host is
+// unreachable.
+func (s *Syncer) remoteBranches(ctx context.Context, dir string) ([]string, error) {
+	output, err := s.runCommandOutput(ctx, dir, "git", "ls-remote", "--heads", s.config.RepoURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query remote branches: %w: %s", err, strings.TrimSpace(output))
+	}
+	return parseBranchRefs(output), nil
+}
+
+// parseBranchRefs returns the branch names listed in git ls-remote output.
+func parseBranchRefs(output string) []string {
+	var branches []string
+	for _, line := range strings.Split(output, "\n") {
+		if _, ref, ok := strings.Cut(strings.TrimSpace(line), "\t"); ok {
+			if branch, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+				branches = append(branches, branch)
+			}
+		}
+	}
+	return branches
+}
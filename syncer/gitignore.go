@@ -0,0 +1,94 @@
+package syncer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitignoreFile is read from the root of the cloned repository for
+// -respect-repo-gitignore.
+const gitignoreFile = ".gitignore"
+
+// ignoreRule is a single .gitignore pattern.
+type ignoreRule struct {
+	pattern string
+	// negate re-includes paths matched by an earlier rule ("!pattern").
+	negate bool
+	// dirOnly limits the rule to directories ("pattern/").
+	dirOnly bool
+}
+
+// ignoreRules are .gitignore patterns in file order.
+type ignoreRules []ignoreRule
+
+// parseGitignore reads .gitignore rules from r. Blank lines and comments are
+// skipped, a leading "!" negates a rule and a trailing "/" restricts it to
+// directories. Patterns are matched like -exclude patterns.
+func parseGitignore(r io.Reader) (ignoreRules, error) {
+	var rules ignoreRules
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// ignored reports whether relPath is ignored. As in git, the last matching
+// rule wins.
+func (rules ignoreRules) ignored(relPath string, isDir bool) bool {
+	relPath = filepath.ToSlash(relPath)
+	ignored := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matchPattern(rule.pattern, relPath) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// loadGitignore reads the .gitignore at the root of dir. A missing file
+// yields no rules.
+func loadGitignore(dir string) (ignoreRules, error) {
+	f, err := os.Open(filepath.Join(dir, gitignoreFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", gitignoreFile, err)
+	}
+	defer f.Close()
+
+	rules, err := parseGitignore(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", gitignoreFile, err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,91 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGitignore(t *testing.T) {
+	input := "# build output\n*.tmp\n\nbuild/\n!keep.tmp\n\\!important\n/root-only.txt   \n"
+	rules, err := parseGitignore(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseGitignore() failed: %v", err)
+	}
+
+	want := ignoreRules{
+		{pattern: "*.tmp"},
+		{pattern: "build", dirOnly: true},
+		{pattern: "keep.tmp", negate: true},
+		{pattern: "!important"},
+		{pattern: "/root-only.txt"},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("parseGitignore() = %+v, want %+v", rules, want)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("rule %d = %+v, want %+v", i, rules[i], want[i])
+		}
+	}
+}
+
+func TestIgnoreRulesIgnored(t *testing.T) {
+	rules := ignoreRules{
+		{pattern: "*.tmp"},
+		{pattern: "build", dirOnly: true},
+		{pattern: "keep.tmp", negate: true},
+		{pattern: "/root-only.txt"},
+	}
+
+	tests := []struct {
+		relPath string
+		isDir   bool
+		want    bool
+	}{
+		{relPath: "scratch.tmp", want: true},
+		{relPath: "nested/dir/scratch.tmp", want: true},
+		{relPath: "keep.tmp", want: false},
+		{relPath: "build", isDir: true, want: true},
+		{relPath: "build", isDir: false, want: false},
+		{relPath: "root-only.txt", want: true},
+		{relPath: "sub/root-only.txt", want: false},
+		{relPath: "notes.txt", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := rules.ignored(tt.relPath, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, %v) = %v, want %v", tt.relPath, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestSyncFilesSkipsRepoIgnoredFiles(t *testing.T) {
+	repoDir := t.TempDir()
+	srcDir := t.TempDir()
+	createTestFiles(t, repoDir, map[string]string{".gitignore": "*.tmp\ncache/\n"})
+	createTestFiles(t, srcDir, map[string]string{
+		"notes.txt":       "notes",
+		"scratch.tmp":     "scratch",
+		"docs/draft.tmp":  "draft",
+		"cache/entry.bin": "cached",
+	})
+
+	rules, err := loadGitignore(repoDir)
+	if err != nil {
+		t.Fatalf("loadGitignore() failed: %v", err)
+	}
+	if _, err := syncFiles(srcDir, repoDir, syncOptions{Ignore: rules}); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(repoDir, "notes.txt")); err != nil {
+		t.Errorf("notes.txt should be synced: %v", err)
+	}
+	for _, path := range []string{"scratch.tmp", "docs/draft.tmp", "cache"} {
+		if _, err := os.Stat(filepath.Join(repoDir, filepath.FromSlash(path))); !os.IsNotExist(err) {
+			t.Errorf("%s is ignored by the repository and should not be written", path)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package syncer
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Checksum algorithms for Config.ChecksumAlgo (-checksum-algo).
+const (
+	ChecksumSHA256  = "sha256"
+	ChecksumSHA512  = "sha512"
+	ChecksumSHA1    = "sha1"
+	ChecksumBLAKE2b = "blake2b"
+)
+
+// checksumAlgos maps each checksum algorithm to its hash constructor.
+var checksumAlgos = map[string]func() hash.Hash{
+	ChecksumSHA256: sha256.New,
+	ChecksumSHA512: sha512.New,
+	ChecksumSHA1:   sha1.New,
+	ChecksumBLAKE2b: func() hash.Hash {
+		// New512 only fails for keys longer than 64 bytes
+		h, _ := blake2b.New512(nil)
+		return h
+	},
+}
+
+// checksumAlgo returns the canonical name of algo, which is ChecksumSHA256
+// when algo is empty, so that manifests written before -checksum-algo
+// existed compare equal to ones written with the default.
+func checksumAlgo(algo string) string {
+	if algo == "" {
+		return ChecksumSHA256
+	}
+	return algo
+}
+
+// hashFile returns the hex-encoded digest of the file at path, using the
+// checksum algorithm algo (SHA-256 if empty).
+func hashFile(path, algo string) (string, error) {
+	newHash, ok := checksumAlgos[checksumAlgo(algo)]
+	if !ok {
+		return "", fmt.Errorf("unknown checksum algorithm %q", algo)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFiles hashes paths with algo using up to jobs concurrent workers. The
+// returned digests are in the same order as paths. If any file fails to
+// hash, the error for the first such path is returned.
+func hashFiles(paths []string, algo string, jobs int) ([]string, error) {
+	hashes := make([]string, len(paths))
+	errs := make([]error, len(paths))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range max(1, min(jobs, len(paths))) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				hashes[i], errs[i] = hashFile(paths[i], algo)
+			}
+		}()
+	}
+	for i := range paths {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %w", paths[i], err)
+		}
+	}
+	return hashes, nil
+}
@@ -0,0 +1,110 @@
+package syncer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHashFilesKeepsOrder(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i := range 20 {
+		name := fmt.Sprintf("file%02d.txt", i)
+		createTestFiles(t, dir, map[string]string{name: strings.Repeat("x", i)})
+		paths = append(paths, filepath.Join(dir, name))
+	}
+
+	hashes, err := hashFiles(paths, "", 4)
+	if err != nil {
+		t.Fatalf("hashFiles() failed: %v", err)
+	}
+	for i, path := range paths {
+		want, err := hashFile(path, "")
+		if err != nil {
+			t.Fatalf("hashFile() failed: %v", err)
+		}
+		if hashes[i] != want {
+			t.Errorf("hashes[%d] = %s, want %s", i, hashes[i], want)
+		}
+	}
+}
+
+func TestHashFilesReportsMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir, map[string]string{"present.txt": "content"})
+	paths := []string{filepath.Join(dir, "present.txt"), filepath.Join(dir, "missing.txt")}
+
+	_, err := hashFiles(paths, "", 2)
+	if err == nil || !strings.Contains(err.Error(), "missing.txt") {
+		t.Errorf("hashFiles() error = %v, want an error naming missing.txt", err)
+	}
+}
+
+func TestHashFilesEmpty(t *testing.T) {
+	hashes, err := hashFiles(nil, "", 8)
+	if err != nil || len(hashes) != 0 {
+		t.Errorf("hashFiles(nil) = %v, %v, want no hashes and no error", hashes, err)
+	}
+}
+
+// benchmarkTree creates a synthetic tree of 200 files of 256KiB each.
+func benchmarkTree(b *testing.B) []string {
+	b.Helper()
+
+	dir := b.TempDir()
+	content := strings.Repeat("0123456789abcdef", 16*1024)
+	var paths []string
+	for i := range 200 {
+		name := fmt.Sprintf("dir%02d/file%03d.bin", i%10, i)
+		createTestFiles(b, dir, map[string]string{name: content})
+		paths = append(paths, filepath.Join(dir, filepath.FromSlash(name)))
+	}
+	return paths
+}
+
+func BenchmarkHashFiles(b *testing.B) {
+	paths := benchmarkTree(b)
+	for _, jobs := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			for b.Loop() {
+				if _, err := hashFiles(paths, "", jobs); err != nil {
+					b.Fatalf("hashFiles() failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestHashFileAlgorithms(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir, map[string]string{"abc.txt": "abc"})
+	path := filepath.Join(dir, "abc.txt")
+
+	tests := []struct {
+		algo string
+		want string
+	}{
+		{"", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{ChecksumSHA256, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{ChecksumSHA1, "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{ChecksumSHA512, "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f"},
+		{ChecksumBLAKE2b, "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algo, func(t *testing.T) {
+			got, err := hashFile(path, tt.algo)
+			if err != nil {
+				t.Fatalf("hashFile() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("hashFile(%q) = %s, want %s", tt.algo, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := hashFile(path, "md5"); err == nil {
+		t.Error("hashFile() with an unknown algorithm should fail")
+	}
+}
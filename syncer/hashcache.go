@@ -0,0 +1,104 @@
+package syncer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// hashCacheFile caches, in the root of a pull destination, the digest of
+// every file along with the size and modification time it had when it was
+// hashed (pull with -hash-cache).
+const hashCacheFile = ".file-syncer-hashes.json"
+
+// hashCacheEntry is the cached digest of one file.
+type hashCacheEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	Hash    string `json:"hash"`
+	// Algo is the checksum algorithm of Hash; empty means SHA-256.
+	Algo string `json:"algo,omitempty"`
+}
+
+// hashCache looks up file digests by path, size and modification time, and
+// only hashes files whose size or modification time changed since they
+// were cached. A nil *hashCache hashes every file.
+type hashCache struct {
+	mu sync.Mutex
+	// entries are the cached digests keyed by slash-separated path
+	entries map[string]hashCacheEntry
+	// used holds the entries looked up in this run; only these are saved,
+	// so that files which are gone drop out of the cache
+	used map[string]hashCacheEntry
+	// hash computes a digest on a cache miss. Tests replace it to count calls.
+	hash func(path, algo string) (string, error)
+}
+
+// loadHashCache reads the cache at path. A missing or unreadable cache
+// yields an empty one, as it is rebuilt by hashing.
+func loadHashCache(path string) (*hashCache, error) {
+	cache := &hashCache{
+		entries: map[string]hashCacheEntry{},
+		used:    map[string]hashCacheEntry{},
+		hash:    hashFile,
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hash cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &cache.entries); err != nil || cache.entries == nil {
+		cache.entries = map[string]hashCacheEntry{}
+	}
+	return cache, nil
+}
+
+// fileHash returns the algo digest of the file at path, which is relPath
+// below the destination, reusing the cached digest if info still matches it
+// and it was computed with the same algorithm.
+func (c *hashCache) fileHash(relPath, path string, info os.FileInfo, algo string) (string, error) {
+	if c == nil {
+		return hashFile(path, algo)
+	}
+	algo = checksumAlgo(algo)
+	key := filepath.ToSlash(relPath)
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && entry.Size == info.Size() && entry.ModTime == info.ModTime().UnixNano() && checksumAlgo(entry.Algo) == algo {
+		c.mu.Lock()
+		c.used[key] = entry
+		c.mu.Unlock()
+		return entry.Hash, nil
+	}
+
+	hash, err := c.hash(path, algo)
+	if err != nil {
+		return "", err
+	}
+	entry = hashCacheEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Hash: hash, Algo: algo}
+	c.mu.Lock()
+	c.entries[key] = entry
+	c.used[key] = entry
+	c.mu.Unlock()
+	return hash, nil
+}
+
+// save writes the entries used in this run to path.
+func (c *hashCache) save(path string) error {
+	c.mu.Lock()
+	data, err := json.Marshal(c.used)
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode hash cache: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write hash cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countHashes makes cache count the files it hashes.
+func countHashes(cache *hashCache) *int {
+	calls := 0
+	cache.hash = func(path, algo string) (string, error) {
+		calls++
+		return hashFile(path, algo)
+	}
+	return &calls
+}
+
+func TestHashCacheRehashesOnlyChangedFiles(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	files := map[string]string{"a.txt": "aaa", "dir/b.txt": "bbb"}
+	createTestFiles(t, srcDir, files)
+	createTestFiles(t, dstDir, files)
+	cachePath := filepath.Join(dstDir, hashCacheFile)
+
+	cache, err := loadHashCache(cachePath)
+	if err != nil {
+		t.Fatalf("loadHashCache() failed: %v", err)
+	}
+	calls := countHashes(cache)
+	if _, err := syncFiles(srcDir, dstDir, syncOptions{SkipUnchanged: true, HashCache: cache}); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+	if *calls != 2 {
+		t.Errorf("first pull hashed %d destination files, want 2", *calls)
+	}
+	if err := cache.save(cachePath); err != nil {
+		t.Fatalf("save() failed: %v", err)
+	}
+
+	// Change b.txt without changing its size
+	bPath := filepath.Join(dstDir, "dir", "b.txt")
+	if err := os.WriteFile(bPath, []byte("xxx"), 0644); err != nil {
+		t.Fatalf("failed to modify b.txt: %v", err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(bPath, later, later); err != nil {
+		t.Fatalf("failed to touch b.txt: %v", err)
+	}
+
+	cache, err = loadHashCache(cachePath)
+	if err != nil {
+		t.Fatalf("loadHashCache() failed: %v", err)
+	}
+	calls = countHashes(cache)
+	counts, err := syncFiles(srcDir, dstDir, syncOptions{SkipUnchanged: true, HashCache: cache})
+	if err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+	if *calls != 1 {
+		t.Errorf("second pull hashed %d destination files, want only the changed one", *calls)
+	}
+	if counts.Copied != 1 || counts.Skipped != 1 {
+		t.Errorf("counts = %+v, want 1 copied and 1 skipped", counts)
+	}
+	content, err := os.ReadFile(bPath)
+	if err != nil {
+		t.Fatalf("failed to read b.txt: %v", err)
+	}
+	if string(content) != "bbb" {
+		t.Errorf("b.txt = %q, want it restored to bbb", content)
+	}
+}
+
+func TestHashCacheDropsMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir, map[string]string{"a.txt": "a"})
+	cachePath := filepath.Join(dir, hashCacheFile)
+	if err := os.WriteFile(cachePath, []byte(`{"gone.txt":{"size":1,"mtime":1,"hash":"x"}}`), 0644); err != nil {
+		t.Fatalf("failed to write cache: %v", err)
+	}
+
+	cache, err := loadHashCache(cachePath)
+	if err != nil {
+		t.Fatalf("loadHashCache() failed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "a.txt"))
+	if err != nil {
+		t.Fatalf("failed to stat a.txt: %v", err)
+	}
+	if _, err := cache.fileHash("a.txt", filepath.Join(dir, "a.txt"), info, ""); err != nil {
+		t.Fatalf("fileHash() failed: %v", err)
+	}
+	if err := cache.save(cachePath); err != nil {
+		t.Fatalf("save() failed: %v", err)
+	}
+
+	cache, err = loadHashCache(cachePath)
+	if err != nil {
+		t.Fatalf("loadHashCache() failed: %v", err)
+	}
+	if _, ok := cache.entries["gone.txt"]; ok {
+		t.Error("the entry of a file that was not seen was kept")
+	}
+	if _, ok := cache.entries["a.txt"]; !ok {
+		t.Error("the entry of a.txt was not saved")
+	}
+}
+
+func TestLoadHashCacheIgnoresCorruptCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), hashCacheFile)
+	if err := os.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatalf("failed to write cache: %v", err)
+	}
+	cache, err := loadHashCache(path)
+	if err != nil {
+		t.Fatalf("loadHashCache() error = %v, want a corrupt cache to be discarded", err)
+	}
+	if len(cache.entries) != 0 {
+		t.Errorf("entries = %v, want none", cache.entries)
+	}
+}
+
+func TestSyncFilesSkipsHashCacheFile(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{hashCacheFile: "{}", "a.txt": "a"})
+
+	if _, err := syncFiles(srcDir, dstDir, syncOptions{}); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, hashCacheFile)); !os.IsNotExist(err) {
+		t.Errorf("the hash cache file was synced: %v", err)
+	}
+}
+
+func TestHashCacheRehashesOtherAlgorithm(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir, map[string]string{"a.txt": "aaa"})
+	path := filepath.Join(dir, "a.txt")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat a.txt: %v", err)
+	}
+
+	cache, err := loadHashCache(filepath.Join(dir, hashCacheFile))
+	if err != nil {
+		t.Fatalf("loadHashCache() failed: %v", err)
+	}
+	calls := countHashes(cache)
+	sha256Hash, err := cache.fileHash("a.txt", path, info, "")
+	if err != nil {
+		t.Fatalf("fileHash() failed: %v", err)
+	}
+	sha512Hash, err := cache.fileHash("a.txt", path, info, ChecksumSHA512)
+	if err != nil {
+		t.Fatalf("fileHash() failed: %v", err)
+	}
+	if *calls != 2 || sha512Hash == sha256Hash {
+		t.Errorf("switching to sha512 hashed %d times and returned %s, want a fresh sha512 digest", *calls, sha512Hash)
+	}
+}
@@ -0,0 +1,51 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// hookCommand returns the shell invocation used to run a hook command line.
+func hookCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// hookEnv returns the environment variables describing the run that are
+// passed to hooks in addition to the inherited environment.
+func hookEnv(config Config, folder, commit string) []string {
+	return []string{
+		"FILE_SYNCER_MODE=" + config.Mode,
+		"FILE_SYNCER_FOLDER=" + folder,
+		"FILE_SYNCER_REPO=" + redact(config.RepoURL),
+		"FILE_SYNCER_BRANCH=" + config.Branch,
+		"FILE_SYNCER_COMMIT=" + commit,
+	}
+}
+
+// runHook runs command through the shell in folder, logging its output. A
+// non-zero exit fails with ErrHookFailed.
+func (s *Syncer) runHook(ctx context.Context, name, command, folder, commit string) error {
+	s.logger.Info("Running hook", "hook", name, "command", command)
+
+	cmd := hookCommand(ctx, command)
+	cmd.Dir = folder
+	cmd.Env = append(os.Environ(), hookEnv(s.config, folder, commit)...)
+	output, err := cmd.CombinedOutput()
+
+	for _, line := range strings.Split(strings.TrimRight(redact(string(output)), "\n"), "\n") {
+		if line != "" {
+			s.logger.Info("Hook output", "hook", name, "output", line)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrHookFailed, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package syncer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// usesLFS reports whether the .gitattributes at the root of dir routes any
+// path through the Git LFS filter.
+func usesLFS(dir string) (bool, error) {
+	f, err := os.Open(filepath.Join(dir, gitAttributesFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", gitAttributesFile, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if fields := strings.Fields(line); slices.Contains(fields[1:], "filter=lfs") {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", gitAttributesFile, err)
+	}
+	return false, nil
+}
+
+// checkLFS makes sure the git-lfs extension is installed.
+func (s *Syncer) checkLFS(ctx context.Context) error {
+	if _, err := s.runCommandOutput(ctx, "", "git", "lfs", "version"); err != nil {
+		return fmt.Errorf("-lfs requires git-lfs, but it is not installed: %w", err)
+	}
+	return nil
+}
+
+// setupLFS configures the LFS filters in the clone at dir so that git add
+// stores LFS-tracked files as pointers.
+func (s *Syncer) setupLFS(ctx context.Context, dir string) error {
+	if err := s.runCommand(ctx, dir, "git", "lfs", "install", "--local"); err != nil {
+		return fmt.Errorf("failed to set up git-lfs: %w", err)
+	}
+	return nil
+}
+
+// lfsPull replaces the LFS pointer files in the clone at dir with their content.
+func (s *Syncer) lfsPull(ctx context.Context, dir string) error {
+	s.logger.Info("Fetching LFS objects")
+	if err := s.runCommand(ctx, dir, "git", "lfs", "pull"); err != nil {
+		return fmt.Errorf("failed to fetch LFS objects: %w", err)
+	}
+	return nil
+}
+
+// lfsPush uploads the LFS objects of the branch. It runs before the branch
+// itself is pushed, since hosts reject commits whose LFS objects are missing.
+func (s *Syncer) lfsPush(ctx context.Context, dir string) error {
+	s.logger.Info("Pushing LFS objects", "branch", s.config.Branch)
+	if err := s.runCommand(ctx, dir, "git", "lfs", "push", "origin", s.config.Branch); err != nil {
+		return fmt.Errorf("%w: failed to push LFS objects: %w", ErrPushFailed, err)
+	}
+	return nil
+}
+
+// lfsPointerSignature starts every Git LFS pointer file.
+const lfsPointerSignature = "version https://git-lfs.github.com/spec/v1\n"
+
+// lfsPointerMaxSize is the size limit for pointer files in the Git LFS
+// specification. Larger files are never pointers.
+const lfsPointerMaxSize = 1024
+
+// isLFSPointer reports whether the file at path is a Git LFS pointer: a
+// small text file with the pointer signature and an oid line.
+func isLFSPointer(path string) (bool, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() || info.Size() > lfsPointerMaxSize {
+		return false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	text := string(data)
+	return strings.HasPrefix(text, lfsPointerSignature) && strings.Contains(text, "\noid sha256:"), nil
+}
+
+// checkLFSPointers looks for Git LFS pointer files among the files that the
+// changes in stats add or modify in the clone at dir, before they are
+// committed. A folder cloned without git-lfs holds pointers instead of the
+// content, and pushing it would replace the content in the repository. Any
+// pointers are logged as a warning, or fail the push with ErrLFSPointer
+// when -strict-lfs is set.
+func (s *Syncer) checkLFSPointers(dir string, stats FileChangeStats) error {
+	paths := slices.Concat(stats.Added, stats.Modified)
+	for _, rename := range stats.Renamed {
+		paths = append(paths, rename[1])
+	}
+	var pointers []string
+	for _, path := range paths {
+		pointer, err := isLFSPointer(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			return fmt.Errorf("failed to check for LFS pointers: %w", err)
+		}
+		if pointer {
+			pointers = append(pointers, path)
+		}
+	}
+	if len(pointers) == 0 {
+		return nil
+	}
+	slices.Sort(pointers)
+	if s.config.StrictLFS {
+		return fmt.Errorf("%w: %s (was the folder cloned without git-lfs?)", ErrLFSPointer, strings.Join(pointers, ", "))
+	}
+	s.logger.Warn("Committing Git LFS pointer files instead of their content, was the folder cloned without git-lfs?", "paths", pointers)
+	return nil
+}
@@ -0,0 +1,124 @@
+package syncer
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUsesLFS(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes string
+		want       bool
+	}{
+		{name: "no attributes file", want: false},
+		{name: "lfs filter", attributes: "*.psd filter=lfs diff=lfs merge=lfs -text\n", want: true},
+		{name: "other attributes", attributes: "*.go text eol=lf\n/tests export-ignore\n", want: false},
+		{name: "commented out", attributes: "# *.bin filter=lfs diff=lfs merge=lfs -text\n", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.attributes != "" {
+				if err := os.WriteFile(filepath.Join(dir, gitAttributesFile), []byte(tt.attributes), 0644); err != nil {
+					t.Fatalf("failed to write .gitattributes: %v", err)
+				}
+			}
+
+			got, err := usesLFS(dir)
+			if err != nil {
+				t.Fatalf("usesLFS() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("usesLFS() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+const testLFSPointer = "version https://git-lfs.github.com/spec/v1\n" +
+	"oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\n" +
+	"size 12345\n"
+
+func TestIsLFSPointer(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "pointer", content: testLFSPointer, want: true},
+		{name: "normal file", content: "just some text\n"},
+		{name: "signature without oid", content: "version https://git-lfs.github.com/spec/v1\nsize 1\n"},
+		{name: "signature later in the file", content: "see\n" + testLFSPointer},
+		{name: "too large", content: testLFSPointer + strings.Repeat("x", lfsPointerMaxSize)},
+		{name: "empty", content: ""},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "file")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			got, err := isLFSPointer(path)
+			if err != nil {
+				t.Fatalf("isLFSPointer() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("isLFSPointer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckLFSPointers(t *testing.T) {
+	dir := t.TempDir()
+	createTestFiles(t, dir, map[string]string{
+		"assets/logo.png": testLFSPointer,
+		"assets/new.bin":  testLFSPointer,
+		"README.md":       "# Assets",
+		"unchanged.bin":   testLFSPointer,
+	})
+	stats := FileChangeStats{
+		Added:    []string{"README.md"},
+		Modified: []string{"assets/logo.png"},
+		Renamed:  [][2]string{{"old.bin", "assets/new.bin"}},
+	}
+	config := Config{Mode: ModePush, FolderPath: dir, RepoURL: "https://github.com/user/repo.git", Branch: "main"}
+
+	// Without -strict-lfs the pointers are only logged
+	var logs bytes.Buffer
+	s, err := New(config, slog.New(slog.NewTextHandler(&logs, nil)))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := s.checkLFSPointers(dir, stats); err != nil {
+		t.Fatalf("checkLFSPointers() failed: %v", err)
+	}
+	if !strings.Contains(logs.String(), "level=WARN") || !strings.Contains(logs.String(), "paths=\"[assets/logo.png assets/new.bin]\"") {
+		t.Errorf("log should warn about the changed pointers:\n%s", logs.String())
+	}
+
+	// With -strict-lfs they fail the push
+	config.StrictLFS = true
+	s = newTestSyncer(t, config)
+	err = s.checkLFSPointers(dir, stats)
+	if !errors.Is(err, ErrLFSPointer) {
+		t.Fatalf("checkLFSPointers() error = %v, want ErrLFSPointer", err)
+	}
+	if !strings.Contains(err.Error(), "assets/logo.png, assets/new.bin") || strings.Contains(err.Error(), "unchanged.bin") {
+		t.Errorf("error %q should name the changed pointers only", err)
+	}
+
+	// Normal files pass
+	if err := s.checkLFSPointers(dir, FileChangeStats{Added: []string{"README.md"}}); err != nil {
+		t.Errorf("checkLFSPointers() of a normal file error = %v, want nil", err)
+	}
+}
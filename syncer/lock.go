@@ -0,0 +1,78 @@
+package syncer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockPollInterval is how often a held lock is retried while waiting for
+// -lock-timeout.
+const lockPollInterval = 100 * time.Millisecond
+
+// lockPath returns the lock file for folder inside lockDir. The folder's
+// absolute path is hashed so that any path maps to a valid file name.
+func lockPath(lockDir, folder string) string {
+	sum := sha256.Sum256([]byte(folder))
+	return filepath.Join(lockDir, hex.EncodeToString(sum[:8])+".lock")
+}
+
+// acquireLock takes an exclusive advisory lock on folder, waiting up to
+// timeout for another run to release it. It returns ErrLocked if the lock
+// is still held after timeout; a zero timeout fails immediately. The
+// returned function releases the lock.
+func acquireLock(ctx context.Context, lockDir, folder string, timeout time.Duration) (func(), error) {
+	if err := os.MkdirAll(lockDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	path := lockPath(lockDir, folder)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if locked {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%w: %s (lock file %s)", ErrLocked, folder, path)
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	return func() {
+		unlock(f)
+		f.Close()
+	}, nil
+}
+
+// lock takes the run lock for the folder at absPath as configured.
+func (s *Syncer) lock(ctx context.Context, absPath string) (func(), error) {
+	lockDir := s.config.LockDir
+	if lockDir == "" {
+		lockDir = filepath.Join(os.TempDir(), "file-syncer-locks")
+	}
+	release, err := acquireLock(ctx, lockDir, absPath, s.config.LockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Debug("Acquired folder lock", "folder", absPath, "lock_dir", lockDir)
+	return release, nil
+}
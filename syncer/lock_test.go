@@ -0,0 +1,83 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAcquireLockAllowsOneRun(t *testing.T) {
+	lockDir := t.TempDir()
+	const folder = "/data/myfiles"
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	results := make(chan error, 2)
+	releases := make(chan func(), 2)
+	for range 2 {
+		wg.Go(func() {
+			<-start
+			release, err := acquireLock(context.Background(), lockDir, folder, 0)
+			if err == nil {
+				releases <- release
+			}
+			results <- err
+		})
+	}
+	close(start)
+	wg.Wait()
+	close(results)
+	close(releases)
+
+	var acquired, locked int
+	for err := range results {
+		switch {
+		case err == nil:
+			acquired++
+		case errors.Is(err, ErrLocked):
+			locked++
+		default:
+			t.Fatalf("acquireLock() unexpected error: %v", err)
+		}
+	}
+	if acquired != 1 || locked != 1 {
+		t.Errorf("acquired = %d, locked = %d; want exactly one run to proceed", acquired, locked)
+	}
+	for release := range releases {
+		release()
+	}
+}
+
+func TestAcquireLockWaitsForRelease(t *testing.T) {
+	lockDir := t.TempDir()
+	const folder = "/data/myfiles"
+
+	release, err := acquireLock(context.Background(), lockDir, folder, 0)
+	if err != nil {
+		t.Fatalf("acquireLock() failed: %v", err)
+	}
+	time.AfterFunc(200*time.Millisecond, release)
+
+	second, err := acquireLock(context.Background(), lockDir, folder, 5*time.Second)
+	if err != nil {
+		t.Fatalf("acquireLock() with timeout failed: %v", err)
+	}
+	second()
+}
+
+func TestAcquireLockSeparatesFolders(t *testing.T) {
+	lockDir := t.TempDir()
+
+	first, err := acquireLock(context.Background(), lockDir, "/data/a", 0)
+	if err != nil {
+		t.Fatalf("acquireLock() failed: %v", err)
+	}
+	defer first()
+	second, err := acquireLock(context.Background(), lockDir, "/data/b", 0)
+	if err != nil {
+		t.Fatalf("a different folder should not be locked: %v", err)
+	}
+	second()
+}
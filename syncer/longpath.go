@@ -0,0 +1,31 @@
+package syncer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// maxNameLen is the longest file name common file systems accept (NAME_MAX).
+const maxNameLen = 255
+
+// checkPathLength returns ErrPathTooLong if dstPath, where relPath is written,
+// exceeds the platform's path length limit or relPath has a name longer than
+// maxNameLen. Catching this up front gives a clearer error than the one the
+// failing copy would report.
+func (o syncOptions) checkPathLength(relPath, dstPath string) error {
+	limit := maxPathLen
+	if o.LongPaths {
+		limit = maxLongPathLen
+	}
+	// The limits count the terminating NUL
+	if len(dstPath) >= limit {
+		return fmt.Errorf("%w: %s (%d characters in the destination, the limit is %d)", ErrPathTooLong, relPath, len(dstPath), limit-1)
+	}
+	for _, name := range strings.Split(relPath, string(filepath.Separator)) {
+		if len(name) > maxNameLen {
+			return fmt.Errorf("%w: %s (name %q has %d characters, the limit is %d)", ErrPathTooLong, relPath, name, len(name), maxNameLen)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package syncer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCheckPathLength(t *testing.T) {
+	dir := string(filepath.Separator) + "dst"
+	// Pad the file name so that the full destination is exactly n characters
+	pathOfLen := func(n int) (string, string) {
+		var segments []string
+		remaining := n - len(dir)
+		for remaining > maxNameLen+1 {
+			segments = append(segments, strings.Repeat("d", maxNameLen))
+			remaining -= maxNameLen + 1
+		}
+		segments = append(segments, strings.Repeat("f", remaining-1))
+		relPath := filepath.Join(segments...)
+		return relPath, filepath.Join(dir, relPath)
+	}
+
+	relPath, dstPath := pathOfLen(maxPathLen - 1)
+	if len(dstPath) != maxPathLen-1 {
+		t.Fatalf("test path has %d characters, want %d", len(dstPath), maxPathLen-1)
+	}
+	if err := (syncOptions{}).checkPathLength(relPath, dstPath); err != nil {
+		t.Errorf("path just below the limit rejected: %v", err)
+	}
+
+	relPath, dstPath = pathOfLen(maxPathLen)
+	err := (syncOptions{}).checkPathLength(relPath, dstPath)
+	if !errors.Is(err, ErrPathTooLong) || !strings.Contains(err.Error(), relPath) {
+		t.Errorf("path at the limit: error = %v, want ErrPathTooLong naming the path", err)
+	}
+	if maxLongPathLen > maxPathLen {
+		if err := (syncOptions{LongPaths: true}).checkPathLength(relPath, dstPath); err != nil {
+			t.Errorf("long path rejected with LongPaths: %v", err)
+		}
+	}
+
+	longName := strings.Repeat("n", maxNameLen+1)
+	err = (syncOptions{}).checkPathLength(longName, filepath.Join(dir, longName))
+	if !errors.Is(err, ErrPathTooLong) {
+		t.Errorf("name above %d characters: error = %v, want ErrPathTooLong", maxNameLen, err)
+	}
+}
+
+func TestSyncFilesRejectsTooLongPath(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("builds a tree close to Linux's PATH_MAX")
+	}
+
+	// The source path stays below PATH_MAX while the destination, nested
+	// more deeply, exceeds it
+	srcDir := t.TempDir()
+	var segments []string
+	for len(filepath.Join(append([]string{srcDir}, segments...)...)) < maxPathLen-2*maxNameLen {
+		segments = append(segments, strings.Repeat("d", 200))
+	}
+	relPath := filepath.Join(append(segments, "file.txt")...)
+	createTestFiles(t, srcDir, map[string]string{filepath.ToSlash(relPath): "deep"})
+
+	dstDir := filepath.Join(t.TempDir(), strings.Repeat("x", 250), strings.Repeat("y", 250), strings.Repeat("z", 250))
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first directory past the limit is reported
+	_, err := syncFiles(srcDir, dstDir, syncOptions{})
+	if !errors.Is(err, ErrPathTooLong) || !strings.Contains(err.Error(), segments[0]) {
+		t.Errorf("syncFiles() error = %v, want ErrPathTooLong naming the deep path", err)
+	}
+}
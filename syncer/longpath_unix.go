@@ -0,0 +1,22 @@
+//go:build unix
+
+package syncer
+
+import "runtime"
+
+// maxPathLen is PATH_MAX, which counts the terminating NUL: 4096 on Linux
+// and 1024 on macOS and the BSDs.
+var maxPathLen = func() int {
+	if runtime.GOOS == "linux" {
+		return 4096
+	}
+	return 1024
+}()
+
+// maxLongPathLen equals maxPathLen, since Unix has no way around PATH_MAX.
+var maxLongPathLen = maxPathLen
+
+// longPath returns path unchanged; the prefix only exists on Windows.
+func longPath(path string) string {
+	return path
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package syncer
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// maxPathLen is MAX_PATH, which counts the terminating NUL.
+const maxPathLen = 260
+
+// maxLongPathLen is the limit for paths with the \\?\ prefix.
+const maxLongPathLen = 32767
+
+// longPath adds the \\?\ prefix that lifts the MAX_PATH limit to an absolute
+// path, using the \\?\UNC\ form for network shares.
+func longPath(path string) string {
+	if strings.HasPrefix(path, `\\?\`) || !filepath.IsAbs(path) {
+		return path
+	}
+	if strings.HasPrefix(path, `\\`) {
+		return `\\?\UNC\` + path[2:]
+	}
+	return `\\?\` + path
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package syncer
+
+import "testing"
+
+func TestLongPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: `C:\data\file.txt`, want: `\\?\C:\data\file.txt`},
+		{path: `\\server\share\file.txt`, want: `\\?\UNC\server\share\file.txt`},
+		{path: `\\?\C:\data\file.txt`, want: `\\?\C:\data\file.txt`},
+		{path: `data\file.txt`, want: `data\file.txt`},
+	}
+
+	for _, tt := range tests {
+		if got := longPath(tt.path); got != tt.want {
+			t.Errorf("longPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package syncer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checkConflictMarkers fails with ErrConflictMarkers, listing the offending
+// files, if any text file below root that a sync with opts copies contains
+// merge conflict markers (pull with -scan-conflict-markers).
+func checkConflictMarkers(root string, opts syncOptions) error {
+	var found []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		if opts.skipped(relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || !opts.included(relPath) {
+			return nil
+		}
+		markers, err := hasConflictMarkers(path)
+		if err != nil {
+			return err
+		}
+		if markers {
+			found = append(found, filepath.ToSlash(relPath))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan for conflict markers: %w", err)
+	}
+	if len(found) > 0 {
+		return fmt.Errorf("%w: %s", ErrConflictMarkers, strings.Join(found, ", "))
+	}
+	return nil
+}
+
+// hasConflictMarkers reports whether the file at path has a line starting
+// with <<<<<<< followed later by a line starting with >>>>>>>. Binary files
+// are never reported.
+func hasConflictMarkers(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, binarySniffLen)
+	// Peek returns what there is for files shorter than the sniff length
+	head, _ := r.Peek(binarySniffLen)
+	if isBinary(head) {
+		return false, nil
+	}
+
+	opened := false
+	lineStart := true
+	for {
+		line, err := r.ReadSlice('\n')
+		if lineStart {
+			if !opened && bytes.HasPrefix(line, []byte("<<<<<<<")) {
+				opened = true
+			} else if opened && bytes.HasPrefix(line, []byte(">>>>>>>")) {
+				return true, nil
+			}
+		}
+		// Lines longer than the buffer are read in pieces
+		lineStart = err != bufio.ErrBufferFull
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return false, err
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package syncer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckConflictMarkers(t *testing.T) {
+	conflicted := "a: 1\n<<<<<<< HEAD\nb: 2\n=======\nb: 3\n>>>>>>> feature\n"
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:  "clean files",
+			files: map[string]string{"config.yml": "a: 1\nb: 2\n", "notes.md": "Title\n=======\n"},
+		},
+		{
+			name:    "conflict markers",
+			files:   map[string]string{"config.yml": conflicted, "dir/other.txt": conflicted, "clean.txt": "fine"},
+			wantErr: "config.yml, dir/other.txt",
+		},
+		{
+			name:  "markers not at the start of a line",
+			files: map[string]string{"doc.md": "use <<<<<<< and\nthen >>>>>>> in prose\n"},
+		},
+		{
+			name:  "opening marker only",
+			files: map[string]string{"doc.md": "<<<<<<< HEAD\ntext\n"},
+		},
+		{
+			name:  "binary file",
+			files: map[string]string{"blob.bin": "\x00" + conflicted},
+		},
+		{
+			name:  "excluded file",
+			files: map[string]string{"skip/config.yml": conflicted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			createTestFiles(t, dir, tt.files)
+
+			err := checkConflictMarkers(dir, syncOptions{Exclude: []string{"skip"}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkConflictMarkers() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrConflictMarkers) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkConflictMarkers() error = %v, want ErrConflictMarkers listing %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHasConflictMarkersLongLines(t *testing.T) {
+	// A marker-like sequence in the middle of a line longer than the read
+	// buffer must not count as the start of a line
+	long := strings.Repeat("x", 2*binarySniffLen) + "<<<<<<< HEAD\n" + strings.Repeat("y", 2*binarySniffLen) + ">>>>>>> b\n"
+	path := filepath.Join(t.TempDir(), "long.txt")
+	if err := os.WriteFile(path, []byte(long), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	found, err := hasConflictMarkers(path)
+	if err != nil {
+		t.Fatalf("hasConflictMarkers() failed: %v", err)
+	}
+	if found {
+		t.Error("hasConflictMarkers() = true for markers in the middle of long lines")
+	}
+}
@@ -0,0 +1,56 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errMetadataUnsupported means the platform cannot preserve the requested
+// file metadata.
+var errMetadataUnsupported = errors.New("not supported on this platform")
+
+// preserveMetadata gives dst the owner and extended attributes of the source
+// file described by info, as far as opts asks for them (pull with
+// -preserve-owner and -preserve-xattrs).
+func preserveMetadata(src, dst string, info os.FileInfo, opts syncOptions) error {
+	var errs []error
+	if opts.PreserveOwner {
+		if err := copyOwner(dst, info); err != nil {
+			errs = append(errs, fmt.Errorf("owner: %w", err))
+		}
+	}
+	if opts.PreserveXattrs {
+		if err := copyXattrs(src, dst); err != nil {
+			errs = append(errs, fmt.Errorf("extended attributes: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// preserveMetadata applies preserveMetadata to a synced file if opts asks
+// for it, reporting a failure to MetadataWarning.
+func (o syncOptions) preserveMetadata(relPath, src, dst string, info os.FileInfo) {
+	if !o.PreserveOwner && !o.PreserveXattrs {
+		return
+	}
+	if err := preserveMetadata(src, dst, info, o); err != nil && o.MetadataWarning != nil {
+		o.MetadataWarning(relPath, err)
+	}
+}
+
+// metadataWarner returns a callback for files whose metadata could not be
+// preserved. Missing privileges or platform support usually affect every
+// file, so only the first failure is logged as a warning and the rest at
+// debug level.
+func (s *Syncer) metadataWarner() func(relPath string, err error) {
+	warned := false
+	return func(relPath string, err error) {
+		if warned {
+			s.logger.Debug("Could not preserve file metadata", "path", relPath, "error", err)
+			return
+		}
+		warned = true
+		s.logger.Warn("Could not preserve file metadata, continuing without it", "path", relPath, "error", err)
+	}
+}
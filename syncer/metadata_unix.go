@@ -0,0 +1,18 @@
+//go:build unix
+
+package syncer
+
+import (
+	"os"
+	"syscall"
+)
+
+// copyOwner sets the owner and group of dst to those of the source file
+// described by info. Changing the owner requires root.
+func copyOwner(dst string, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errMetadataUnsupported
+	}
+	return os.Lchown(dst, int(st.Uid), int(st.Gid))
+}
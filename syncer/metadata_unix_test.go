@@ -0,0 +1,114 @@
+//go:build unix
+
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestSyncFilesPreservesMode(t *testing.T) {
+	// A restrictive umask would strip the executable bit from the create mode
+	oldMask := syscall.Umask(0077)
+	t.Cleanup(func() { syscall.Umask(oldMask) })
+
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	script := filepath.Join(srcDir, "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	if err := os.Chmod(script, 0755); err != nil {
+		t.Fatalf("failed to chmod script: %v", err)
+	}
+	// An existing destination keeps its mode when opened for writing
+	stale := filepath.Join(dstDir, "run.sh")
+	if err := os.WriteFile(stale, []byte("old"), 0600); err != nil {
+		t.Fatalf("failed to write stale destination: %v", err)
+	}
+
+	for _, opts := range []syncOptions{{}, {SkipUnchanged: true}} {
+		if _, err := syncFiles(srcDir, dstDir, opts); err != nil {
+			t.Fatalf("syncFiles() failed: %v", err)
+		}
+		info, err := os.Stat(stale)
+		if err != nil {
+			t.Fatalf("failed to stat synced script: %v", err)
+		}
+		if got := info.Mode().Perm(); got != 0755 {
+			t.Errorf("mode = %o, want 755", got)
+		}
+		// Lose the executable bit again; -skip-unchanged must restore it
+		if err := os.Chmod(stale, 0644); err != nil {
+			t.Fatalf("failed to chmod destination: %v", err)
+		}
+	}
+}
+
+func TestSyncFilesPreservesOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing file ownership requires root")
+	}
+
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{"app.conf": "key=value"})
+	const uid, gid = 12345, 23456
+	if err := os.Chown(filepath.Join(srcDir, "app.conf"), uid, gid); err != nil {
+		t.Fatalf("failed to chown source: %v", err)
+	}
+
+	var warnings int
+	opts := syncOptions{
+		PreserveOwner:   true,
+		MetadataWarning: func(string, error) { warnings++ },
+	}
+	if _, err := syncFiles(srcDir, dstDir, opts); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dstDir, "app.conf"))
+	if err != nil {
+		t.Fatalf("failed to stat synced file: %v", err)
+	}
+	st := info.Sys().(*syscall.Stat_t)
+	if st.Uid != uid || st.Gid != gid {
+		t.Errorf("owner = %d:%d, want %d:%d", st.Uid, st.Gid, uid, gid)
+	}
+	if warnings != 0 {
+		t.Errorf("got %d metadata warnings, want none", warnings)
+	}
+}
+
+func TestPreserveMetadataReportsFailure(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can change file ownership")
+	}
+
+	dir := t.TempDir()
+	createTestFiles(t, dir, map[string]string{"app.conf": "key=value"})
+	dst := filepath.Join(dir, "app.conf")
+
+	var gotPath string
+	var gotErr error
+	opts := syncOptions{
+		PreserveOwner: true,
+		MetadataWarning: func(relPath string, err error) {
+			gotPath, gotErr = relPath, err
+		},
+	}
+	// Giving a file to root fails without privileges
+	opts.preserveMetadata("app.conf", dst, dst, fakeOwnerInfo{})
+
+	if gotPath != "app.conf" || gotErr == nil {
+		t.Errorf("MetadataWarning got (%q, %v), want a failure for app.conf", gotPath, gotErr)
+	}
+}
+
+// fakeOwnerInfo is an os.FileInfo whose owner is root.
+type fakeOwnerInfo struct{ os.FileInfo }
+
+func (fakeOwnerInfo) Sys() any { return &syscall.Stat_t{} }
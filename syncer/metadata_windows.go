@@ -0,0 +1,11 @@
+//go:build windows
+
+package syncer
+
+import "os"
+
+// copyOwner is not supported on Windows, where files have ACLs instead of
+// a uid and gid.
+func copyOwner(dst string, info os.FileInfo) error {
+	return errMetadataUnsupported
+}
@@ -0,0 +1,86 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runMetrics summarizes a run for the -metrics-file output.
+type runMetrics struct {
+	Mode     string
+	RunID    string
+	Start    time.Time
+	Duration time.Duration
+	Added    int
+	Modified int
+	Deleted  int
+	Renamed  int
+	Success  bool
+}
+
+// formatMetrics renders m in the Prometheus text exposition format.
+func formatMetrics(m runMetrics) string {
+	var b strings.Builder
+	gauge := func(name, help, labels string, value any) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s%s %v\n", name, help, name, name, labels, value)
+	}
+
+	success := 0
+	if m.Success {
+		success = 1
+	}
+	if m.RunID != "" {
+		gauge("file_syncer_run_info", "Identifies the last run; run_id matches its log lines.", fmt.Sprintf("{run_id=%q}", m.RunID), 1)
+	}
+	gauge("file_syncer_last_run_timestamp", "Unix time the last run started.", "", m.Start.Unix())
+	gauge("file_syncer_duration_seconds", "Duration of the last run in seconds.", "", m.Duration.Seconds())
+	gauge("file_syncer_success", "Whether the last run succeeded (1) or failed (0).", fmt.Sprintf("{mode=%q}", m.Mode), success)
+	gauge("file_syncer_files_added", "Files added by the last run.", "", m.Added)
+	gauge("file_syncer_files_modified", "Files modified by the last run.", "", m.Modified)
+	gauge("file_syncer_files_deleted", "Files deleted by the last run.", "", m.Deleted)
+	gauge("file_syncer_files_renamed", "Files renamed by the last run.", "", m.Renamed)
+	return b.String()
+}
+
+// writeMetrics writes m to path atomically, so that a collector reading the
+// file never sees a partial write.
+func writeMetrics(path string, m runMetrics) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".file-syncer-metrics-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.WriteString(formatMetrics(m))
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// recordMetrics writes the metrics of a finished run if -metrics-file is set.
+// A failure to write them is logged but does not fail the run.
+func (s *Syncer) recordMetrics(m runMetrics, err error) {
+	if s.config.MetricsFile == "" {
+		return
+	}
+	m.Mode = s.config.Mode
+	m.RunID = s.config.RunID
+	m.Duration = time.Since(m.Start)
+	m.Success = err == nil || errors.Is(err, ErrNoChanges)
+	if err := writeMetrics(s.config.MetricsFile, m); err != nil {
+		s.logger.Warn("Failed to write metrics file", "path", s.config.MetricsFile, "error", err)
+	}
+}
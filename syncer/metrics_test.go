@@ -0,0 +1,99 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseMetrics reads the samples of a Prometheus text file into a map from
+// metric name, including labels, to value.
+func parseMetrics(t *testing.T, text string) map[string]string {
+	t.Helper()
+
+	samples := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, " ")
+		if !ok {
+			t.Fatalf("malformed sample line %q", line)
+		}
+		samples[name] = value
+	}
+	return samples
+}
+
+func TestWriteMetrics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file_syncer.prom")
+	m := runMetrics{
+		Mode:     ModePush,
+		RunID:    "0123456789abcdef",
+		Start:    time.Unix(1700000000, 0),
+		Duration: 1500 * time.Millisecond,
+		Added:    3,
+		Modified: 2,
+		Deleted:  1,
+		Success:  true,
+	}
+	if err := writeMetrics(path, m); err != nil {
+		t.Fatalf("writeMetrics() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+	got := parseMetrics(t, string(data))
+	want := map[string]string{
+		`file_syncer_run_info{run_id="0123456789abcdef"}`: "1",
+		"file_syncer_last_run_timestamp":                  "1700000000",
+		"file_syncer_duration_seconds":                    "1.5",
+		`file_syncer_success{mode="push"}`:                "1",
+		"file_syncer_files_added":                         "3",
+		"file_syncer_files_modified":                      "2",
+		"file_syncer_files_deleted":                       "1",
+		"file_syncer_files_renamed":                       "0",
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s = %q, want %q", name, got[name], value)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d samples, want %d: %v", len(got), len(want), got)
+	}
+
+	// Only the metrics file is left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("failed to list directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the metrics file, found %d entries", len(entries))
+	}
+}
+
+func TestRecordMetricsOnFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file_syncer.prom")
+	config := Config{
+		Mode:        ModePull,
+		FolderPath:  "/tmp/test",
+		RepoURL:     "https://github.com/user/repo.git",
+		Branch:      "main",
+		MetricsFile: path,
+	}
+
+	newTestSyncer(t, config).recordMetrics(runMetrics{Start: time.Now()}, ErrCloneFailed)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+	if got := parseMetrics(t, string(data))[`file_syncer_success{mode="pull"}`]; got != "0" {
+		t.Errorf(`file_syncer_success{mode="pull"} = %q, want "0"`, got)
+	}
+}
@@ -0,0 +1,58 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// mirrorRemote is the name of the remote that -mirror-remote adds to the clone.
+const mirrorRemote = "mirror"
+
+// pushMirror pushes the branch, and tag if not empty, from the clone at dir
+// to Config.MirrorRemote after the primary push succeeded. The mirror
+// follows the primary, so it is force-pushed whenever the primary push may
+// have rewritten history. A failure is only logged, unless -mirror-required
+// is set.
+func (s *Syncer) pushMirror(ctx context.Context, dir, tag string, rewritten bool) error {
+	config := s.config
+	logger := s.logger.With("mirror", SanitizeURL(config.MirrorRemote))
+
+	// A reused work directory already has the remote
+	if _, err := s.runCommandOutput(ctx, dir, "git", "remote", "get-url", mirrorRemote); err == nil {
+		err = s.runCommand(ctx, dir, "git", "remote", "set-url", mirrorRemote, config.MirrorRemote)
+		if err != nil {
+			return s.mirrorFailed(logger, fmt.Errorf("failed to update mirror remote: %w", err))
+		}
+	} else if err := s.runCommand(ctx, dir, "git", "remote", "add", mirrorRemote, config.MirrorRemote); err != nil {
+		return s.mirrorFailed(logger, fmt.Errorf("failed to add mirror remote: %w", err))
+	}
+
+	args := []string{"push"}
+	if rewritten || config.Force || config.ForceUnsafe {
+		args = append(args, "--force")
+	}
+	if config.NoVerify {
+		args = append(args, "--no-verify")
+	}
+	args = append(args, mirrorRemote, "refs/heads/"+config.Branch)
+	if tag != "" {
+		args = append(args, "refs/tags/"+tag)
+	}
+
+	logger.Info("Pushing to mirror", "branch", config.Branch)
+	if output, err := s.runCommandStderr(ctx, dir, "git", args...); err != nil {
+		return s.mirrorFailed(logger, fmt.Errorf("mirror push failed: %w", classifyPushError(output, err)))
+	}
+	logger.Info("Mirror push completed")
+	return nil
+}
+
+// mirrorFailed returns err if the mirror is required and logs it otherwise.
+func (s *Syncer) mirrorFailed(logger *slog.Logger, err error) error {
+	if s.config.MirrorRequired {
+		return err
+	}
+	logger.Warn("Mirror push failed, the primary push succeeded", "error", err)
+	return nil
+}
@@ -0,0 +1,50 @@
+package syncer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// netrcPath returns the netrc file that git's HTTP transport reads
+// credentials from.
+func netrcPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+	return filepath.Join(home, name), nil
+}
+
+// netrcProblem describes what is wrong with the netrc file at path, or
+// returns an empty string if it exists and is readable only by its owner.
+func netrcProblem(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Sprintf("cannot use %s: %v", path, err)
+	}
+	// Windows has no Unix permission bits to check
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return fmt.Sprintf("%s has mode %#o, it should be 0600 or 0400 so that other users cannot read the credentials", path, info.Mode().Perm())
+	}
+	return ""
+}
+
+// checkNetrc warns if -use-netrc is given but the netrc file is missing or
+// readable by other users. git still runs, since credentials may come from
+// elsewhere.
+func (s *Syncer) checkNetrc() {
+	path, err := netrcPath()
+	if err != nil {
+		s.logger.Warn("Cannot locate netrc file", "error", err)
+		return
+	}
+	if problem := netrcProblem(path); problem != "" {
+		s.logger.Warn("Netrc file check failed", "problem", problem)
+	}
+}
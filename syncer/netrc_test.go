@@ -0,0 +1,100 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCommandNetrcEnvironment(t *testing.T) {
+	for _, useNetrc := range []bool{false, true} {
+		s := &Syncer{config: Config{UseNetrc: useNetrc}, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+		cmd := s.command(context.Background(), t.TempDir(), "git", "fetch")
+
+		if got := slices.Contains(cmd.Env, "GIT_TERMINAL_PROMPT=0"); got != useNetrc {
+			t.Errorf("UseNetrc=%v: GIT_TERMINAL_PROMPT=0 set = %v", useNetrc, got)
+		}
+		// Credential helpers configured by the user must stay in effect
+		for _, env := range cmd.Env[len(os.Environ()):] {
+			if strings.HasPrefix(env, "GIT_CONFIG") {
+				t.Errorf("UseNetrc=%v: command overrides git config with %q", useNetrc, env)
+			}
+		}
+	}
+}
+
+func TestValidateConfigUseNetrc(t *testing.T) {
+	tests := []struct {
+		name    string
+		repoURL string
+		wantErr bool
+	}{
+		{name: "https", repoURL: "https://github.com/user/repo.git"},
+		{name: "ssh", repoURL: "git@github.com:user/repo.git", wantErr: true},
+		{name: "ssh URL", repoURL: "ssh://git@github.com/user/repo.git", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{Mode: ModePush, FolderPath: "/tmp/test", RepoURL: tt.repoURL, Branch: "main", UseNetrc: true}
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNetrcProblem(t *testing.T) {
+	dir := t.TempDir()
+	private := filepath.Join(dir, "private")
+	if err := os.WriteFile(private, []byte("machine github.com login u password p\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	shared := filepath.Join(dir, "shared")
+	if err := os.WriteFile(shared, []byte("machine github.com login u password p\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if problem := netrcProblem(private); problem != "" {
+		t.Errorf("netrcProblem() of a 0600 file = %q, want none", problem)
+	}
+	if err := os.Chmod(private, 0400); err != nil {
+		t.Fatal(err)
+	}
+	if problem := netrcProblem(private); problem != "" {
+		t.Errorf("netrcProblem() of a 0400 file = %q, want none", problem)
+	}
+	if problem := netrcProblem(filepath.Join(dir, "missing")); problem == "" {
+		t.Error("netrcProblem() should report a missing file")
+	}
+	if runtime.GOOS != "windows" {
+		if problem := netrcProblem(shared); !strings.Contains(problem, "0600") {
+			t.Errorf("netrcProblem() of a 0644 file = %q, want a mode warning", problem)
+		}
+	}
+}
+
+func TestNewWarnsAboutMissingNetrc(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	var logs bytes.Buffer
+	config := Config{Mode: ModePull, FolderPath: "/tmp/test", RepoURL: "https://github.com/user/repo.git", Branch: "main", UseNetrc: true}
+	if _, err := New(config, slog.New(slog.NewTextHandler(&logs, nil))); err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if !strings.Contains(logs.String(), "level=WARN") || !strings.Contains(logs.String(), "netrc") {
+		t.Errorf("expected a netrc warning, got:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,195 @@
+package syncer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Plan actions recorded for each file operation.
+const (
+	PlanActionAdd    = "add"
+	PlanActionModify = "modify"
+	PlanActionDelete = "delete"
+)
+
+// PlanOperation is a single file change captured in a plan.
+type PlanOperation struct {
+	Action string `json:"action"`
+	Path   string `json:"path"`
+	// SHA256 is the hash of the source file when the plan was created,
+	// computed with the plan's Algorithm despite the name, which predates
+	// -checksum-algo. It is empty for deletions.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// Plan is a reviewable description of a push: the file operations to apply
+// and the commit message to use. A saved plan can be applied later with
+// -apply-plan as long as the planned source files have not changed.
+type Plan struct {
+	RepoURL   string    `json:"repoUrl"`
+	Branch    string    `json:"branch"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Algorithm is the checksum algorithm of the operation hashes. Empty
+	// means SHA-256, for plans written before -checksum-algo.
+	Algorithm  string          `json:"algorithm,omitempty"`
+	Subject    string          `json:"subject"`
+	Body       string          `json:"body,omitempty"`
+	Operations []PlanOperation `json:"operations"`
+}
+
+// buildPlan creates a plan from the change statistics of a synced clone,
+// recording the hash of each added or modified file in sourceDir. Files are
+// hashed by config.hashJobs() workers; operations are sorted by path so the
+// plan is the same regardless of the number of workers.
+func buildPlan(config Config, sourceDir string, stats FileChangeStats, subject, body string) (*Plan, error) {
+	plan := &Plan{
+		RepoURL:    config.RepoURL,
+		Branch:     config.Branch,
+		CreatedAt:  time.Now().UTC(),
+		Algorithm:  checksumAlgo(config.ChecksumAlgo),
+		Subject:    subject,
+		Body:       body,
+		Operations: []PlanOperation{},
+	}
+
+	for _, file := range stats.Added {
+		plan.Operations = append(plan.Operations, PlanOperation{Action: PlanActionAdd, Path: file})
+	}
+	for _, file := range stats.Modified {
+		plan.Operations = append(plan.Operations, PlanOperation{Action: PlanActionModify, Path: file})
+	}
+	for _, file := range stats.Deleted {
+		plan.Operations = append(plan.Operations, PlanOperation{Action: PlanActionDelete, Path: file})
+	}
+	// A rename is applied as deleting the old path and adding the new one
+	for _, rename := range stats.Renamed {
+		plan.Operations = append(plan.Operations,
+			PlanOperation{Action: PlanActionDelete, Path: rename[0]},
+			PlanOperation{Action: PlanActionAdd, Path: rename[1]})
+	}
+	sort.SliceStable(plan.Operations, func(i, j int) bool {
+		return plan.Operations[i].Path < plan.Operations[j].Path
+	})
+
+	var paths []string
+	var hashed []*PlanOperation
+	for i := range plan.Operations {
+		if op := &plan.Operations[i]; op.Action != PlanActionDelete {
+			paths = append(paths, filepath.Join(sourceDir, filepath.FromSlash(op.Path)))
+			hashed = append(hashed, op)
+		}
+	}
+	hashes, err := hashFiles(paths, plan.Algorithm, config.hashJobs())
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range hashed {
+		op.SHA256 = hashes[i]
+	}
+
+	return plan, nil
+}
+
+// writePlan saves the plan as indented JSON.
+func writePlan(path string, plan *Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	return nil
+}
+
+// readPlan loads a plan previously written by writePlan.
+func readPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan %s: %w", path, err)
+	}
+	return &plan, nil
+}
+
+// checkTarget ensures the plan was created for the configured repository and branch.
+func (p *Plan) checkTarget(config Config) error {
+	if p.RepoURL != config.RepoURL || p.Branch != config.Branch {
+		return fmt.Errorf("plan targets %s (branch %s), not %s (branch %s)", SanitizeURL(p.RepoURL), p.Branch, SanitizeURL(config.RepoURL), config.Branch)
+	}
+	return nil
+}
+
+// verifySource checks that every planned file in sourceDir still has the hash
+// recorded in the plan and that planned deletions are still absent. Files
+// are hashed with the plan's algorithm, whatever -checksum-algo is now.
+func (p *Plan) verifySource(sourceDir string) error {
+	if _, ok := checksumAlgos[checksumAlgo(p.Algorithm)]; !ok {
+		return fmt.Errorf("plan uses unknown checksum algorithm %q", p.Algorithm)
+	}
+	var drifted []string
+	for _, op := range p.Operations {
+		srcPath := filepath.Join(sourceDir, filepath.FromSlash(op.Path))
+		switch op.Action {
+		case PlanActionAdd, PlanActionModify:
+			hash, err := hashFile(srcPath, p.Algorithm)
+			if err != nil {
+				drifted = append(drifted, fmt.Sprintf("%s (unreadable: %v)", op.Path, err))
+				continue
+			}
+			if hash != op.SHA256 {
+				drifted = append(drifted, fmt.Sprintf("%s (content changed)", op.Path))
+			}
+		case PlanActionDelete:
+			if _, err := os.Stat(srcPath); err == nil {
+				drifted = append(drifted, fmt.Sprintf("%s (reappeared)", op.Path))
+			}
+		default:
+			return fmt.Errorf("plan contains unknown action %q for %s", op.Action, op.Path)
+		}
+	}
+
+	if len(drifted) > 0 {
+		return fmt.Errorf("%w: %s", ErrPlanDrift, strings.Join(drifted, ", "))
+	}
+	return nil
+}
+
+// apply performs the planned operations, copying files from sourceDir into
+// workDir and removing planned deletions from workDir.
+func (p *Plan) apply(sourceDir, workDir string, opts syncOptions) error {
+	for _, op := range p.Operations {
+		srcPath := filepath.Join(sourceDir, filepath.FromSlash(op.Path))
+		dstPath := filepath.Join(workDir, filepath.FromSlash(op.Path))
+
+		switch op.Action {
+		case PlanActionAdd, PlanActionModify:
+			info, err := os.Stat(srcPath)
+			if err != nil {
+				return fmt.Errorf("failed to stat %s: %w", op.Path, err)
+			}
+			if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+				return fmt.Errorf("failed to create directory for %s: %w", op.Path, err)
+			}
+			if err := copyFile(srcPath, dstPath, info.Mode(), opts); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", op.Path, err)
+			}
+		case PlanActionDelete:
+			if err := os.Remove(dstPath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to delete %s: %w", op.Path, err)
+			}
+		default:
+			return fmt.Errorf("plan contains unknown action %q for %s", op.Action, op.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package syncer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildPlan(t *testing.T) {
+	sourceDir := t.TempDir()
+	createTestFiles(t, sourceDir, map[string]string{
+		"new.txt":     "new content",
+		"dir/mod.txt": "modified content",
+	})
+
+	config := Config{RepoURL: "https://github.com/user/repo.git", Branch: "main"}
+	stats := FileChangeStats{
+		Added:    []string{"new.txt"},
+		Modified: []string{"dir/mod.txt"},
+		Deleted:  []string{"old.txt"},
+	}
+
+	plan, err := buildPlan(config, sourceDir, stats, "subject", "body")
+	if err != nil {
+		t.Fatalf("buildPlan() failed: %v", err)
+	}
+
+	if plan.Subject != "subject" || plan.Body != "body" {
+		t.Errorf("unexpected commit message: %q / %q", plan.Subject, plan.Body)
+	}
+	if len(plan.Operations) != 3 {
+		t.Fatalf("expected 3 operations, got %d", len(plan.Operations))
+	}
+
+	wantHash, err := hashFile(filepath.Join(sourceDir, "new.txt"), "")
+	if err != nil {
+		t.Fatalf("hashFile() failed: %v", err)
+	}
+	// Operations are sorted by path
+	if op := plan.Operations[0]; op.Action != PlanActionModify || op.Path != "dir/mod.txt" || op.SHA256 == "" {
+		t.Errorf("unexpected modify operation: %+v", op)
+	}
+	if op := plan.Operations[1]; op.Action != PlanActionAdd || op.Path != "new.txt" || op.SHA256 != wantHash {
+		t.Errorf("unexpected add operation: %+v", op)
+	}
+	if op := plan.Operations[2]; op.Action != PlanActionDelete || op.Path != "old.txt" || op.SHA256 != "" {
+		t.Errorf("unexpected delete operation: %+v", op)
+	}
+}
+
+func TestBuildPlanRename(t *testing.T) {
+	sourceDir := t.TempDir()
+	createTestFiles(t, sourceDir, map[string]string{"new.txt": "moved"})
+
+	config := Config{RepoURL: "https://github.com/user/repo.git", Branch: "main"}
+	stats := FileChangeStats{Renamed: [][2]string{{"old.txt", "new.txt"}}}
+	plan, err := buildPlan(config, sourceDir, stats, "subject", "")
+	if err != nil {
+		t.Fatalf("buildPlan() failed: %v", err)
+	}
+
+	if len(plan.Operations) != 2 {
+		t.Fatalf("expected 2 operations, got %+v", plan.Operations)
+	}
+	if op := plan.Operations[0]; op.Action != PlanActionAdd || op.Path != "new.txt" || op.SHA256 == "" {
+		t.Errorf("unexpected add operation: %+v", op)
+	}
+	if op := plan.Operations[1]; op.Action != PlanActionDelete || op.Path != "old.txt" {
+		t.Errorf("unexpected delete operation: %+v", op)
+	}
+}
+
+func TestBuildPlanIsIndependentOfJobs(t *testing.T) {
+	sourceDir := t.TempDir()
+	files := map[string]string{}
+	var stats FileChangeStats
+	for i := range 50 {
+		path := fmt.Sprintf("dir%d/file%02d.txt", i%5, i)
+		files[path] = strings.Repeat(path, i+1)
+		if i%2 == 0 {
+			stats.Added = append(stats.Added, path)
+		} else {
+			stats.Modified = append(stats.Modified, path)
+		}
+	}
+	stats.Deleted = []string{"dir0/removed.txt", "a-removed.txt"}
+	createTestFiles(t, sourceDir, files)
+
+	var want []byte
+	for _, jobs := range []int{1, 4, 16} {
+		config := Config{RepoURL: "https://github.com/user/repo.git", Branch: "main", Jobs: jobs}
+		plan, err := buildPlan(config, sourceDir, stats, "subject", "body")
+		if err != nil {
+			t.Fatalf("buildPlan() with %d jobs failed: %v", jobs, err)
+		}
+		plan.CreatedAt = time.Time{}
+
+		path := filepath.Join(t.TempDir(), "plan.json")
+		if err := writePlan(path, plan); err != nil {
+			t.Fatalf("writePlan() failed: %v", err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read plan: %v", err)
+		}
+		if want == nil {
+			want = got
+		} else if !bytes.Equal(got, want) {
+			t.Errorf("plan written with %d jobs differs from the plan written with 1 job", jobs)
+		}
+	}
+}
+
+func TestWriteAndReadPlan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	plan := &Plan{
+		RepoURL:    "https://github.com/user/repo.git",
+		Branch:     "main",
+		Subject:    "Sync 1 file (1 added)",
+		Operations: []PlanOperation{{Action: PlanActionAdd, Path: "a.txt", SHA256: "abc"}},
+	}
+
+	if err := writePlan(path, plan); err != nil {
+		t.Fatalf("writePlan() failed: %v", err)
+	}
+	got, err := readPlan(path)
+	if err != nil {
+		t.Fatalf("readPlan() failed: %v", err)
+	}
+
+	if got.RepoURL != plan.RepoURL || got.Subject != plan.Subject || len(got.Operations) != 1 || got.Operations[0] != plan.Operations[0] {
+		t.Errorf("round-tripped plan mismatch: got %+v, want %+v", got, plan)
+	}
+}
+
+func TestPlanVerifySourceAndApply(t *testing.T) {
+	sourceDir := t.TempDir()
+	workDir := t.TempDir()
+	createTestFiles(t, sourceDir, map[string]string{"docs/a.txt": "planned"})
+	createTestFiles(t, workDir, map[string]string{"old.txt": "stale"})
+
+	hash, err := hashFile(filepath.Join(sourceDir, "docs", "a.txt"), "")
+	if err != nil {
+		t.Fatalf("hashFile() failed: %v", err)
+	}
+	plan := &Plan{Operations: []PlanOperation{
+		{Action: PlanActionAdd, Path: "docs/a.txt", SHA256: hash},
+		{Action: PlanActionDelete, Path: "old.txt"},
+	}}
+
+	if err := plan.verifySource(sourceDir); err != nil {
+		t.Fatalf("verifySource() unexpected error: %v", err)
+	}
+
+	// Unplanned source changes don't count as drift and aren't applied
+	createTestFiles(t, sourceDir, map[string]string{"unplanned.txt": "extra"})
+
+	if err := plan.apply(sourceDir, workDir, syncOptions{}); err != nil {
+		t.Fatalf("apply() failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(workDir, "docs", "a.txt"))
+	if err != nil || string(content) != "planned" {
+		t.Errorf("planned file not applied: %q, %v", string(content), err)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "old.txt")); !os.IsNotExist(err) {
+		t.Error("planned deletion was not applied")
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "unplanned.txt")); !os.IsNotExist(err) {
+		t.Error("unplanned file should not be applied")
+	}
+}
+
+func TestPlanVerifySourceDetectsDrift(t *testing.T) {
+	sourceDir := t.TempDir()
+	createTestFiles(t, sourceDir, map[string]string{"a.txt": "planned"})
+
+	hash, err := hashFile(filepath.Join(sourceDir, "a.txt"), "")
+	if err != nil {
+		t.Fatalf("hashFile() failed: %v", err)
+	}
+	plan := &Plan{Operations: []PlanOperation{
+		{Action: PlanActionModify, Path: "a.txt", SHA256: hash},
+		{Action: PlanActionDelete, Path: "gone.txt"},
+	}}
+
+	createTestFiles(t, sourceDir, map[string]string{
+		"a.txt":    "changed after planning",
+		"gone.txt": "back again",
+	})
+
+	err = plan.verifySource(sourceDir)
+	if err == nil {
+		t.Fatal("verifySource() should detect drift")
+	}
+	if !strings.Contains(err.Error(), "a.txt (content changed)") || !strings.Contains(err.Error(), "gone.txt (reappeared)") {
+		t.Errorf("unexpected drift error: %v", err)
+	}
+}
+
+func TestPlanChecksumAlgorithms(t *testing.T) {
+	for _, algo := range []string{ChecksumSHA512, ChecksumBLAKE2b} {
+		t.Run(algo, func(t *testing.T) {
+			sourceDir := t.TempDir()
+			createTestFiles(t, sourceDir, map[string]string{"a.txt": "planned"})
+
+			config := Config{RepoURL: "https://github.com/user/repo.git", Branch: "main", ChecksumAlgo: algo}
+			plan, err := buildPlan(config, sourceDir, FileChangeStats{Added: []string{"a.txt"}}, "subject", "")
+			if err != nil {
+				t.Fatalf("buildPlan() failed: %v", err)
+			}
+			path := filepath.Join(t.TempDir(), "plan.json")
+			if err := writePlan(path, plan); err != nil {
+				t.Fatalf("writePlan() failed: %v", err)
+			}
+			got, err := readPlan(path)
+			if err != nil {
+				t.Fatalf("readPlan() failed: %v", err)
+			}
+
+			if got.Algorithm != algo {
+				t.Errorf("plan algorithm = %q, want %q", got.Algorithm, algo)
+			}
+			want, err := hashFile(filepath.Join(sourceDir, "a.txt"), algo)
+			if err != nil {
+				t.Fatalf("hashFile() failed: %v", err)
+			}
+			if got.Operations[0].SHA256 != want {
+				t.Errorf("plan hash = %s, want the %s digest %s", got.Operations[0].SHA256, algo, want)
+			}
+
+			// The plan is verified with its own algorithm, not the default
+			if err := got.verifySource(sourceDir); err != nil {
+				t.Errorf("verifySource() unexpected error: %v", err)
+			}
+			createTestFiles(t, sourceDir, map[string]string{"a.txt": "changed after planning"})
+			if err := got.verifySource(sourceDir); !errors.Is(err, ErrPlanDrift) {
+				t.Errorf("verifySource() error = %v, want ErrPlanDrift", err)
+			}
+		})
+	}
+}
+
+func TestPlanVerifySourceUnknownAlgorithm(t *testing.T) {
+	plan := &Plan{Algorithm: "md5", Operations: []PlanOperation{{Action: PlanActionAdd, Path: "a.txt", SHA256: "abc"}}}
+	if err := plan.verifySource(t.TempDir()); err == nil || errors.Is(err, ErrPlanDrift) {
+		t.Errorf("verifySource() error = %v, want an unknown algorithm error", err)
+	}
+}
+
+func TestValidateConfigPlanFlags(t *testing.T) {
+	base := Config{
+		Mode:       ModePush,
+		FolderPath: "/tmp/test",
+		RepoURL:    "https://github.com/user/repo.git",
+		Branch:     "main",
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{name: "plan out in push mode", modify: func(c *Config) { c.PlanOutPath = "plan.json" }},
+		{name: "apply plan in push mode", modify: func(c *Config) { c.ApplyPlanPath = "plan.json" }},
+		{
+			name: "plan out in pull mode",
+			modify: func(c *Config) {
+				c.Mode = ModePull
+				c.PlanOutPath = "plan.json"
+			},
+			wantErr: true,
+		},
+		{
+			name: "plan out and apply plan together",
+			modify: func(c *Config) {
+				c.PlanOutPath = "plan.json"
+				c.ApplyPlanPath = "plan.json"
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := base
+			tt.modify(&config)
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Progress is reported every progressInterval or every progressEveryFiles
+// files, whichever comes first, and once more when the sync completes.
+const (
+	progressInterval   = 5 * time.Second
+	progressEveryFiles = 100
+)
+
+// syncProgress is a snapshot of how far syncFiles has got.
+type syncProgress struct {
+	FilesDone  int
+	FilesTotal int
+	BytesDone  int64
+	BytesTotal int64
+	Elapsed    time.Duration
+}
+
+// Percent returns the completed share of the sync by bytes, or by files
+// when there are no bytes to copy.
+func (p syncProgress) Percent() float64 {
+	if p.BytesTotal > 0 {
+		return float64(p.BytesDone) * 100 / float64(p.BytesTotal)
+	}
+	if p.FilesTotal > 0 {
+		return float64(p.FilesDone) * 100 / float64(p.FilesTotal)
+	}
+	return 100
+}
+
+// BytesPerSecond returns the average throughput so far.
+func (p syncProgress) BytesPerSecond() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.BytesDone) / p.Elapsed.Seconds()
+}
+
+// progressTracker accumulates processed files and decides when to report.
+// A nil tracker ignores all calls, so callers don't need to check whether
+// progress reporting is enabled.
+type progressTracker struct {
+	progress    syncProgress
+	start       time.Time
+	lastReport  time.Time
+	sinceReport int
+	report      func(syncProgress)
+}
+
+func newProgressTracker(files int, size int64, report func(syncProgress)) *progressTracker {
+	now := time.Now()
+	return &progressTracker{
+		progress:   syncProgress{FilesTotal: files, BytesTotal: size},
+		start:      now,
+		lastReport: now,
+		report:     report,
+	}
+}
+
+// add records a processed file of the given size.
+func (t *progressTracker) add(size int64) {
+	if t == nil {
+		return
+	}
+	t.progress.FilesDone++
+	t.progress.BytesDone += size
+	t.sinceReport++
+	if t.sinceReport >= progressEveryFiles || time.Since(t.lastReport) >= progressInterval {
+		t.emit()
+	}
+}
+
+// finish reports the final state.
+func (t *progressTracker) finish() {
+	if t == nil {
+		return
+	}
+	t.emit()
+}
+
+func (t *progressTracker) emit() {
+	t.lastReport = time.Now()
+	t.sinceReport = 0
+	t.progress.Elapsed = time.Since(t.start)
+	t.report(t.progress)
+}
+
+// logCopy returns a FileCopied callback that logs every file written below
+// root at debug level, so that -verbose gives a per-file audit trail.
+func (s *Syncer) logCopy(root string) func(dst string, size int64, created bool) {
+	return func(dst string, size int64, created bool) {
+		if !s.logger.Enabled(context.Background(), slog.LevelDebug) {
+			return
+		}
+		// With -long-paths the destination carries the \\?\ prefix
+		base := root
+		if long := longPath(root); strings.HasPrefix(dst, long) {
+			base = long
+		}
+		path := dst
+		if rel, err := filepath.Rel(base, dst); err == nil {
+			path = filepath.ToSlash(rel)
+		}
+		action := "update"
+		if created {
+			action = "create"
+		}
+		s.logger.Debug("copied", "path", path, "bytes", size, "action", action)
+	}
+}
+
+// logCopyRetry is the CopyRetried callback, logging every retried copy.
+func (s *Syncer) logCopyRetry(src string, attempt int, err error) {
+	s.logger.Warn("Copying file failed, retrying", "path", src, "attempt", attempt, "error", err)
+}
+
+// logProgress logs a progress update of syncFiles.
+func (s *Syncer) logProgress(p syncProgress) {
+	s.logger.Info("Sync progress",
+		"files_done", p.FilesDone,
+		"files_total", p.FilesTotal,
+		"bytes_done", p.BytesDone,
+		"bytes_total", p.BytesTotal,
+		"percent", fmt.Sprintf("%.1f", p.Percent()),
+		"bytes_per_second", int64(p.BytesPerSecond()))
+}
@@ -0,0 +1,133 @@
+package syncer
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncFilesReportsProgress(t *testing.T) {
+	srcDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{
+		"a.txt":       "12345",
+		"dir/b.txt":   "1234567890",
+		"skip/c.log":  "excluded",
+		"dir/d.txt":   "12345",
+		"dir/e/f.txt": "",
+	})
+
+	var updates []syncProgress
+	opts := syncOptions{
+		Exclude:  []string{"skip"},
+		Progress: func(p syncProgress) { updates = append(updates, p) },
+	}
+	if _, err := syncFiles(srcDir, t.TempDir(), opts); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+
+	if len(updates) == 0 {
+		t.Fatal("expected at least one progress update")
+	}
+	last := updates[len(updates)-1]
+	if last.FilesTotal != 4 || last.FilesDone != 4 {
+		t.Errorf("files = %d/%d, want 4/4", last.FilesDone, last.FilesTotal)
+	}
+	if last.BytesTotal != 20 || last.BytesDone != 20 {
+		t.Errorf("bytes = %d/%d, want 20/20", last.BytesDone, last.BytesTotal)
+	}
+	if last.Percent() != 100 {
+		t.Errorf("Percent() = %v, want 100", last.Percent())
+	}
+}
+
+func TestProgressTrackerReportsEveryFiles(t *testing.T) {
+	var updates []syncProgress
+	tracker := newProgressTracker(progressEveryFiles*2+1, 0, func(p syncProgress) { updates = append(updates, p) })
+	for range progressEveryFiles*2 + 1 {
+		tracker.add(0)
+	}
+	tracker.finish()
+
+	if len(updates) != 3 {
+		t.Fatalf("got %d updates, want 3", len(updates))
+	}
+	if updates[0].FilesDone != progressEveryFiles || updates[1].FilesDone != progressEveryFiles*2 {
+		t.Errorf("unexpected intermediate updates: %+v", updates[:2])
+	}
+	if updates[2].Percent() != 100 {
+		t.Errorf("final update Percent() = %v, want 100", updates[2].Percent())
+	}
+}
+
+func TestSyncProgressThroughput(t *testing.T) {
+	p := syncProgress{BytesDone: 1000, BytesTotal: 4000, Elapsed: 2 * time.Second}
+	if got := p.Percent(); got != 25 {
+		t.Errorf("Percent() = %v, want 25", got)
+	}
+	if got := p.BytesPerSecond(); got != 500 {
+		t.Errorf("BytesPerSecond() = %v, want 500", got)
+	}
+}
+
+func TestLogProgress(t *testing.T) {
+	var logs bytes.Buffer
+	s := &Syncer{logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	s.logProgress(syncProgress{FilesDone: 1, FilesTotal: 2, BytesDone: 50, BytesTotal: 200, Elapsed: time.Second})
+
+	for _, want := range []string{`msg="Sync progress"`, "files_done=1", "files_total=2", "percent=25.0", "bytes_per_second=50"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log output %q should contain %q", logs.String(), want)
+		}
+	}
+}
+
+func TestLogCopyLogsEveryFile(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{"new.txt": "hello", "dir/existing.txt": "new content"})
+	createTestFiles(t, dstDir, map[string]string{"dir/existing.txt": "old"})
+
+	var logs bytes.Buffer
+	s := &Syncer{logger: slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+	if _, err := syncFiles(srcDir, dstDir, syncOptions{FileCopied: s.logCopy(dstDir)}); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+
+	type entry struct {
+		Msg    string `json:"msg"`
+		Path   string `json:"path"`
+		Bytes  int64  `json:"bytes"`
+		Action string `json:"action"`
+	}
+	got := map[string]entry{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var e entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("failed to parse log line %q: %v", line, err)
+		}
+		if e.Msg == "copied" {
+			got[e.Path] = e
+		}
+	}
+
+	want := map[string]entry{
+		"new.txt":          {Msg: "copied", Path: "new.txt", Bytes: 5, Action: "create"},
+		"dir/existing.txt": {Msg: "copied", Path: "dir/existing.txt", Bytes: 11, Action: "update"},
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("copy log entries = %+v, want %+v", got, want)
+	}
+}
+
+func TestLogCopyIsQuietWithoutVerbose(t *testing.T) {
+	var logs bytes.Buffer
+	s := &Syncer{logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	s.logCopy("/dst")("/dst/a.txt", 1, true)
+	if logs.Len() != 0 {
+		t.Errorf("logCopy() logged %q at the default level", logs.String())
+	}
+}
@@ -0,0 +1,52 @@
+package syncer
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// proxySchemes are the proxy URL schemes -proxy accepts, the ones git's
+// HTTP transport (curl) understands.
+var proxySchemes = []string{"http", "https", "socks4", "socks4a", "socks5", "socks5h"}
+
+// validateProxyURL checks that proxy is a proxy URL git can use, such as
+// http://proxy.example.com:3128 or socks5://127.0.0.1:1080.
+func validateProxyURL(proxy string) error {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL %q", SanitizeURL(proxy))
+	}
+	if !slices.Contains(proxySchemes, strings.ToLower(u.Scheme)) {
+		return fmt.Errorf("unsupported proxy URL scheme %q: use http://, https://, socks4://, socks4a://, socks5:// or socks5h://", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("invalid proxy URL %q: missing host", SanitizeURL(proxy))
+	}
+	if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" {
+		return fmt.Errorf("invalid proxy URL %q: it must not have a path or query", SanitizeURL(proxy))
+	}
+	return nil
+}
+
+// proxyEnv returns the environment variables that route git's HTTP
+// transport through proxy. curl only reads http_proxy in lower case.
+func proxyEnv(proxy string) []string {
+	return []string{"ALL_PROXY=" + proxy, "HTTPS_PROXY=" + proxy, "http_proxy=" + proxy}
+}
+
+// buildProxyCommand returns the ssh ProxyCommand that tunnels connections
+// through proxy with OpenBSD netcat: a CONNECT request for HTTP proxies, a
+// SOCKS handshake otherwise. Proxy credentials are not passed on.
+func buildProxyCommand(proxy string) string {
+	u, _ := url.Parse(proxy)
+	protocol := "connect"
+	switch strings.ToLower(u.Scheme) {
+	case "socks4", "socks4a":
+		protocol = "4"
+	case "socks5", "socks5h":
+		protocol = "5"
+	}
+	return fmt.Sprintf("nc -X %s -x %s %%h %%p", protocol, u.Host)
+}
@@ -0,0 +1,41 @@
+package syncer
+
+import (
+	"io"
+	"time"
+)
+
+// rateLimitedReader wraps an io.Reader and throttles it to bytesPerSec.
+// It works as a token bucket without burst: tokens accrue at the configured
+// rate from the first read, and a read that overdraws the bucket sleeps
+// until enough tokens have accumulated.
+type rateLimitedReader struct {
+	r           io.Reader
+	bytesPerSec int64
+	start       time.Time
+	total       int64
+}
+
+func newRateLimitedReader(r io.Reader, bytesPerSec int64) *rateLimitedReader {
+	return &rateLimitedReader{r: r, bytesPerSec: bytesPerSec}
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+
+	// Read at most one second's worth at a time so waits stay short
+	if int64(len(p)) > r.bytesPerSec {
+		p = p[:r.bytesPerSec]
+	}
+
+	n, err := r.r.Read(p)
+	r.total += int64(n)
+
+	allowed := time.Duration(float64(r.total) / float64(r.bytesPerSec) * float64(time.Second))
+	if wait := allowed - time.Since(r.start); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
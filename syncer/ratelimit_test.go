@@ -0,0 +1,67 @@
+package syncer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopyFileRateLimited(t *testing.T) {
+	tempDir := t.TempDir()
+	srcFile := filepath.Join(tempDir, "source.bin")
+	content := bytes.Repeat([]byte("x"), 50*1024)
+	if err := os.WriteFile(srcFile, content, 0644); err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	// 50KB at 100KB/s must take at least half a second
+	opts := syncOptions{RateLimit: 100 * 1024}
+	dstFile := filepath.Join(tempDir, "destination.bin")
+
+	start := time.Now()
+	if err := copyFile(srcFile, dstFile, 0644, opts); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if elapsed < 450*time.Millisecond {
+		t.Errorf("rate-limited copy took %v, want at least 500ms", elapsed)
+	}
+
+	got, err := os.ReadFile(dstFile)
+	if err != nil {
+		t.Fatalf("failed to read destination file: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("rate-limited copy produced different content")
+	}
+}
+
+func TestValidateConfigRateLimit(t *testing.T) {
+	tests := []struct {
+		limit   string
+		wantErr bool
+	}{
+		{limit: "", wantErr: false},
+		{limit: "10MB", wantErr: false},
+		{limit: "0", wantErr: true},
+		{limit: "ten", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.limit, func(t *testing.T) {
+			config := Config{
+				Mode:       ModePull,
+				FolderPath: "/tmp/test",
+				RepoURL:    "https://github.com/user/repo.git",
+				RateLimit:  tt.limit,
+			}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package syncer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewRunID returns a random identifier for a run, which tells apart the log
+// lines of runs that interleave in a shared log.
+func NewRunID() string {
+	var b [8]byte
+	// crypto/rand.Read never returns an error
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
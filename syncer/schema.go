@@ -0,0 +1,102 @@
+package syncer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Schema describes the expected layout of a synced folder. Patterns use the
+// same glob syntax as -include and -exclude.
+//
+// Example schema file:
+//
+//	{
+//	  "required": ["README.md", "docs/*.md"],
+//	  "forbidden": ["*.exe", "secrets/**"],
+//	  "allowedTopLevel": ["README.md", "docs", "src"]
+//	}
+type Schema struct {
+	// Required lists patterns that must each match at least one file.
+	Required []string `json:"required"`
+	// Forbidden lists patterns that must not match any file or directory.
+	Forbidden []string `json:"forbidden"`
+	// AllowedTopLevel, when non-empty, restricts the entries directly in the
+	// folder root to those matching one of the patterns.
+	AllowedTopLevel []string `json:"allowedTopLevel"`
+}
+
+// loadSchema reads a JSON schema definition from path.
+func loadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse schema %s: %w", path, err)
+	}
+	return &schema, nil
+}
+
+// Validate checks the tree rooted at root against the schema and returns an
+// error listing every violation. The .git directory is ignored.
+func (s *Schema) Validate(root string) error {
+	var violations []string
+	satisfied := make([]bool, len(s.Required))
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		if relPath == ".git" {
+			return filepath.SkipDir
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if !strings.Contains(relPath, "/") && len(s.AllowedTopLevel) > 0 && !matchAny(s.AllowedTopLevel, relPath) {
+			violations = append(violations, fmt.Sprintf("unexpected top-level entry %q", relPath))
+		}
+
+		for _, pattern := range s.Forbidden {
+			if matchPattern(pattern, relPath) {
+				violations = append(violations, fmt.Sprintf("forbidden path %q matches %q", relPath, pattern))
+				break
+			}
+		}
+
+		if !info.IsDir() {
+			for i, pattern := range s.Required {
+				if !satisfied[i] && matchPattern(pattern, relPath) {
+					satisfied[i] = true
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan folder for schema validation: %w", err)
+	}
+
+	for i, pattern := range s.Required {
+		if !satisfied[i] {
+			violations = append(violations, fmt.Sprintf("no file matches required pattern %q", pattern))
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("%w: %s", ErrSchemaViolation, strings.Join(violations, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  Schema
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:   "required file present",
+			schema: Schema{Required: []string{"README.md", "docs/*.md"}},
+			files: map[string]string{
+				"README.md":     "readme",
+				"docs/guide.md": "guide",
+			},
+		},
+		{
+			name:    "required file missing",
+			schema:  Schema{Required: []string{"README.md", "docs/*.md"}},
+			files:   map[string]string{"docs/guide.md": "guide"},
+			wantErr: `no file matches required pattern "README.md"`,
+		},
+		{
+			name:    "forbidden file present",
+			schema:  Schema{Forbidden: []string{"*.exe"}},
+			files:   map[string]string{"bin/tool.exe": "binary"},
+			wantErr: `forbidden path "bin/tool.exe"`,
+		},
+		{
+			name:   "allowed top-level entries",
+			schema: Schema{AllowedTopLevel: []string{"README.md", "docs"}},
+			files: map[string]string{
+				"README.md":     "readme",
+				"docs/guide.md": "guide",
+			},
+		},
+		{
+			name:   "unexpected top-level entry",
+			schema: Schema{AllowedTopLevel: []string{"README.md", "docs"}},
+			files: map[string]string{
+				"README.md": "readme",
+				"extra.txt": "extra",
+			},
+			wantErr: `unexpected top-level entry "extra.txt"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			createTestFiles(t, root, tt.files)
+			// A .git directory must never count as unexpected content
+			if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+				t.Fatalf("failed to create .git directory: %v", err)
+			}
+
+			err := tt.schema.Validate(root)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.json")
+	content := `{"required": ["README.md"], "forbidden": ["*.tmp"], "allowedTopLevel": ["README.md"]}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write schema: %v", err)
+	}
+
+	schema, err := loadSchema(path)
+	if err != nil {
+		t.Fatalf("loadSchema() failed: %v", err)
+	}
+	if len(schema.Required) != 1 || schema.Required[0] != "README.md" {
+		t.Errorf("unexpected required patterns: %v", schema.Required)
+	}
+	if len(schema.Forbidden) != 1 || schema.Forbidden[0] != "*.tmp" {
+		t.Errorf("unexpected forbidden patterns: %v", schema.Forbidden)
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatalf("failed to write schema: %v", err)
+	}
+	if _, err := loadSchema(path); err == nil {
+		t.Error("loadSchema() should fail for invalid JSON")
+	}
+}
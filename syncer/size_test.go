@@ -0,0 +1,34 @@
+package syncer
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "1024", want: 1024},
+		{input: "10B", want: 10},
+		{input: "512k", want: 512 * 1024},
+		{input: "10MB", want: 10 * 1024 * 1024},
+		{input: "10 MiB", want: 10 * 1024 * 1024},
+		{input: "1.5GB", want: 1536 * 1024 * 1024},
+		{input: "2T", want: 2 << 40},
+		{input: "", wantErr: true},
+		{input: "fast", wantErr: true},
+		{input: "-1MB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSize(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
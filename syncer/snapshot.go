@@ -0,0 +1,34 @@
+package syncer
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// takeSnapshot copies the files push would read from absPath into dir, so
+// that the rest of the run works from content frozen at this moment instead
+// of a folder that may change during the git operations (-snapshot). It
+// returns the path to push from in place of absPath.
+func takeSnapshot(absPath, dir string, singleFile bool, fileList []string, opts syncOptions) (string, error) {
+	// Oversized files are copied too, so that the sync from the snapshot
+	// still reports them
+	opts.MaxFileSize, opts.FailOversize = 0, false
+	opts.Progress = nil
+
+	var err error
+	switch {
+	case singleFile:
+		_, err = syncFile(absPath, dir, opts)
+		absPath = filepath.Join(dir, filepath.Base(absPath))
+	case fileList != nil:
+		_, err = syncFileList(absPath, dir, fileList, opts)
+		absPath = dir
+	default:
+		_, err = syncFiles(absPath, dir, opts)
+		absPath = dir
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot folder: %w", err)
+	}
+	return absPath, nil
+}
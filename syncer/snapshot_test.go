@@ -0,0 +1,54 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTakeSnapshot(t *testing.T) {
+	srcDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{
+		"data.csv":      "1,2,3",
+		"nested/db.sql": "select 1;",
+		"skip.tmp":      "scratch",
+	})
+
+	// The size limit is left to the sync from the snapshot
+	opts := syncOptions{Exclude: []string{"*.tmp"}, MaxFileSize: 1, FailOversize: true}
+	snapshotDir := t.TempDir()
+	path, err := takeSnapshot(srcDir, snapshotDir, false, nil, opts)
+	if err != nil {
+		t.Fatalf("takeSnapshot() failed: %v", err)
+	}
+	if path != snapshotDir {
+		t.Errorf("takeSnapshot() = %q, want %q", path, snapshotDir)
+	}
+
+	// Later changes to the source don't reach the snapshot
+	createTestFiles(t, srcDir, map[string]string{"data.csv": "changed"})
+
+	for path, want := range map[string]string{"data.csv": "1,2,3", "nested/db.sql": "select 1;"} {
+		got, err := os.ReadFile(filepath.Join(snapshotDir, path))
+		if err != nil || string(got) != want {
+			t.Errorf("snapshot %s = %q (%v), want %q", path, got, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(snapshotDir, "skip.tmp")); !os.IsNotExist(err) {
+		t.Error("excluded files should not be snapshotted")
+	}
+}
+
+func TestTakeSnapshotSingleFile(t *testing.T) {
+	srcDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{"notes.txt": "notes"})
+
+	snapshotDir := t.TempDir()
+	path, err := takeSnapshot(filepath.Join(srcDir, "notes.txt"), snapshotDir, true, nil, syncOptions{})
+	if err != nil {
+		t.Fatalf("takeSnapshot() failed: %v", err)
+	}
+	if want := filepath.Join(snapshotDir, "notes.txt"); path != want {
+		t.Errorf("takeSnapshot() = %q, want %q", path, want)
+	}
+}
@@ -0,0 +1,35 @@
+package syncer
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// cleanSparsePath returns p as a slash-separated path relative to the
+// repository root, or "" if it does not name a directory below the root.
+func cleanSparsePath(p string) string {
+	p = path.Clean(strings.Trim(filepath.ToSlash(p), "/"))
+	if p == "." || p == ".." || strings.HasPrefix(p, "../") || strings.HasPrefix(p, "-") {
+		return ""
+	}
+	return p
+}
+
+// outsideSparse reports whether slashPath lies outside every Sparse
+// directory. Directories above a sparse directory are inside, so that the
+// walk reaches it.
+func (o syncOptions) outsideSparse(slashPath string, isDir bool) bool {
+	if len(o.Sparse) == 0 {
+		return false
+	}
+	for _, dir := range o.Sparse {
+		if slashPath == dir || strings.HasPrefix(slashPath, dir+"/") {
+			return false
+		}
+		if isDir && strings.HasPrefix(dir, slashPath+"/") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// squashTrailer marks a commit made by -squash-after. It is the marker that
+// ends the run of sync commits eligible for the next squash.
+const squashTrailer = "Squashed-Sync-Commits"
+
+// logCommit is a commit read from git log.
+type logCommit struct {
+	Hash    string
+	Parents int
+	Message string
+}
+
+// Subject returns the first line of the commit message.
+func (c logCommit) Subject() string {
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return subject
+}
+
+// squashLogFormat makes git log print the hash, parents and message of each
+// commit, separated by NUL and terminated by a record separator.
+const squashLogFormat = "--format=%H%x00%P%x00%B%x1e"
+
+// parseSquashLog parses git log output in squashLogFormat, newest first.
+func parseSquashLog(output string) []logCommit {
+	var commits []logCommit
+	for _, record := range strings.Split(output, "\x1e") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\r\n"), "\x00", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		commits = append(commits, logCommit{
+			Hash:    fields[0],
+			Parents: len(strings.Fields(fields[1])),
+			Message: strings.TrimSpace(fields[2]),
+		})
+	}
+	return commits
+}
+
+// isSyncCommit reports whether c is an ordinary commit made by file-syncer:
+// a non-merge commit with a generated "Sync" subject, after the -message-prefix
+// prefix if any, that is not itself the result of a squash.
+func (c logCommit) isSyncCommit(prefix string) bool {
+	if c.Parents != 1 {
+		return false
+	}
+	subject := c.Subject()
+	if prefix != "" {
+		var ok bool
+		if subject, ok = strings.CutPrefix(subject, prefixSubject(prefix, "")); !ok {
+			return false
+		}
+	}
+	if !strings.HasPrefix(subject, "Sync ") && !strings.HasPrefix(subject, "Sync:") {
+		return false
+	}
+	for _, line := range strings.Split(c.Message, "\n") {
+		if strings.HasPrefix(line, squashTrailer+":") {
+			return false
+		}
+	}
+	return true
+}
+
+// shouldSquash reports whether the newest n of commits, listed newest first,
+// are all sync commits, with subjects starting with prefix, made since the
+// last squash. At least n+1 commits are needed, as the squash keeps the
+// parent of the oldest one.
+func shouldSquash(commits []logCommit, n int, prefix string) bool {
+	if n < 2 || len(commits) <= n {
+		return false
+	}
+	for _, c := range commits[:n] {
+		if !c.isSyncCommit(prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// squashMessage builds the message of the commit that replaces commits,
+// listed newest first: a summary subject, the original subjects from oldest
+// to newest and the squashTrailer marker.
+func squashMessage(commits []logCommit) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Sync: squash %d sync commits\n\n", len(commits))
+	b.WriteString("Squashed commits:\n")
+	for i := len(commits) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "  * %s\n", commits[i].Subject())
+	}
+	b.WriteString("\n" + squashTrailer + ": " + strconv.Itoa(len(commits)))
+	return b.String()
+}
+
+// squashSyncCommits squashes the last -squash-after commits in dir into one
+// if they are all sync commits. It returns whether it squashed and the
+// remote branch's commit the push must lease against, which is empty if the
+// branch does not exist on the remote yet.
+func (s *Syncer) squashSyncCommits(ctx context.Context, dir string) (bool, string, error) {
+	n := s.config.SquashAfter
+	output, err := s.runCommandOutput(ctx, dir, "git", "log", "--first-parent", squashLogFormat, "-n", strconv.Itoa(n+1))
+	if err != nil {
+		return false, "", fmt.Errorf("failed to read history for squashing: %w", err)
+	}
+	commits := parseSquashLog(output)
+	if !shouldSquash(commits, n, s.config.MessagePrefix) {
+		return false, "", nil
+	}
+
+	var lease string
+	if remote, err := s.runCommandOutput(ctx, dir, "git", "rev-parse", "-q", "--verify", "refs/remotes/origin/"+s.config.Branch); err == nil {
+		lease = strings.TrimSpace(remote)
+	}
+
+	s.logger.Info("Squashing sync commits", "commits", n)
+	if err := s.runCommand(ctx, dir, "git", "reset", "--soft", commits[n].Hash); err != nil {
+		return false, "", fmt.Errorf("failed to squash sync commits: %w", err)
+	}
+	if err := s.commit(ctx, dir, squashMessage(commits[:n])); err != nil {
+		return false, "", err
+	}
+	return true, lease, nil
+}
+
+// pushRewritten pushes a branch whose history was rewritten by a squash or
+// an -amend, using --force-with-lease against the remote commit seen at clone time so
+// that commits pushed by someone else in the meantime are never overwritten.
+func (s *Syncer) pushRewritten(ctx context.Context, dir, lease string) error {
+	branch := s.config.Branch
+	args := []string{"push", "--force-with-lease=" + branch + ":" + lease}
+	if s.config.NoVerify {
+		args = append(args, "--no-verify")
+	}
+	output, err := s.runCommandStderr(ctx, dir, "git", append(args, "origin", branch)...)
+	if err != nil {
+		return classifyPushError(output, err)
+	}
+	return nil
+}
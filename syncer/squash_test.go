@@ -0,0 +1,87 @@
+package syncer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSquashLog(t *testing.T) {
+	output := "aaa\x00bbb\x00Sync 2 files (2 added)\n\nAdded files:\n  + a.txt\n\x1e\n" +
+		"bbb\x00ccc ddd\x00Merge branch 'x'\n\x1e\n"
+
+	got := parseSquashLog(output)
+	if len(got) != 2 {
+		t.Fatalf("parseSquashLog() returned %d commits, want 2: %+v", len(got), got)
+	}
+	if got[0].Hash != "aaa" || got[0].Parents != 1 || got[0].Subject() != "Sync 2 files (2 added)" {
+		t.Errorf("first commit = %+v", got[0])
+	}
+	if got[1].Hash != "bbb" || got[1].Parents != 2 || got[1].Subject() != "Merge branch 'x'" {
+		t.Errorf("second commit = %+v", got[1])
+	}
+}
+
+func TestShouldSquash(t *testing.T) {
+	sync := logCommit{Parents: 1, Message: "Sync 1 file (1 modified)"}
+	perFile := logCommit{Parents: 1, Message: "Sync: add notes.txt"}
+	heartbeat := logCommit{Parents: 1, Message: heartbeatSubject}
+	manual := logCommit{Parents: 1, Message: "Fix typo in README"}
+	merge := logCommit{Parents: 2, Message: "Sync 1 file (1 added)"}
+	squash := logCommit{Parents: 1, Message: squashMessage([]logCommit{sync, sync})}
+	root := logCommit{Parents: 0, Message: "Initial commit"}
+	prefixed := logCommit{Parents: 1, Message: "[skip ci] Sync 1 file (1 modified)"}
+
+	tests := []struct {
+		name    string
+		commits []logCommit
+		n       int
+		prefix  string
+		want    bool
+	}{
+		{name: "enough sync commits", commits: []logCommit{sync, perFile, heartbeat, manual}, n: 3, want: true},
+		{name: "too few commits", commits: []logCommit{sync, sync, root}, n: 3},
+		{name: "no parent left", commits: []logCommit{sync, sync, sync}, n: 3},
+		{name: "manual commit", commits: []logCommit{sync, manual, sync, sync}, n: 3},
+		{name: "merge commit", commits: []logCommit{sync, merge, sync, sync}, n: 3},
+		{name: "previous squash", commits: []logCommit{sync, sync, squash, sync}, n: 3},
+		{name: "squash after previous squash", commits: []logCommit{sync, sync, sync, squash}, n: 3, want: true},
+		{name: "n below two", commits: []logCommit{sync, sync}, n: 1},
+		{name: "message prefix", commits: []logCommit{prefixed, prefixed, prefixed, manual}, n: 3, prefix: "[skip ci]", want: true},
+		{name: "without the message prefix", commits: []logCommit{prefixed, sync, prefixed, manual}, n: 3, prefix: "[skip ci]"},
+		{name: "unexpected message prefix", commits: []logCommit{prefixed, prefixed, prefixed, manual}, n: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldSquash(tt.commits, tt.n, tt.prefix); got != tt.want {
+				t.Errorf("shouldSquash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSquashMessage(t *testing.T) {
+	commits := []logCommit{
+		{Parents: 1, Message: "Sync 1 file (1 deleted)\n\nDeleted files:\n  - c.txt"},
+		{Parents: 1, Message: "Sync: modify b.txt"},
+		{Parents: 1, Message: "Sync 1 file (1 added)"},
+	}
+
+	want := "Sync: squash 3 sync commits\n\n" +
+		"Squashed commits:\n" +
+		"  * Sync 1 file (1 added)\n" +
+		"  * Sync: modify b.txt\n" +
+		"  * Sync 1 file (1 deleted)\n" +
+		"\n" +
+		"Squashed-Sync-Commits: 3"
+	got := squashMessage(commits)
+	if got != want {
+		t.Errorf("squashMessage() = %q, want %q", got, want)
+	}
+
+	// The squash commit is the marker for the next squash
+	marker := logCommit{Parents: 1, Message: strings.TrimSpace(got)}
+	if marker.isSyncCommit("") {
+		t.Error("a squash commit must not count as a sync commit")
+	}
+}
@@ -0,0 +1,69 @@
+package syncer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// installSSHKey writes Config.SSHKeyData to a temporary file readable only by
+// the current user, which the git commands then use as their identity. The
+// returned function overwrites and removes the file; it must be called even
+// if the run fails.
+func (s *Syncer) installSSHKey() (func(), error) {
+	if s.config.SSHKeyData == "" {
+		return func() {}, nil
+	}
+
+	f, err := os.CreateTemp("", "file-syncer-key-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH key file: %w", err)
+	}
+	path := f.Name()
+	remove := func() {
+		wipeFile(path)
+		s.keyPath = ""
+	}
+
+	// ssh rejects keys without a trailing newline
+	data := s.config.SSHKeyData
+	if !strings.HasSuffix(data, "\n") {
+		data += "\n"
+	}
+	err = f.Chmod(0600)
+	if err == nil {
+		_, err = f.WriteString(data)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		remove()
+		return nil, fmt.Errorf("failed to write SSH key file: %w", err)
+	}
+
+	s.keyPath = path
+	return remove, nil
+}
+
+// wipeFile overwrites the file at path with zeros before removing it, so the
+// key does not linger on disk.
+func wipeFile(path string) {
+	if info, err := os.Stat(path); err == nil {
+		if f, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+			f.Write(make([]byte, info.Size()))
+			f.Sync()
+			f.Close()
+		}
+	}
+	os.Remove(path)
+}
+
+// sshKeyPath returns the identity file for git commands: the configured key
+// or the one written from SSHKeyData.
+func (s *Syncer) sshKeyPath() string {
+	if s.keyPath != "" {
+		return s.keyPath
+	}
+	return s.config.SSHKeyPath
+}
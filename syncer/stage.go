@@ -0,0 +1,153 @@
+package syncer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+// stagingDir holds, in the root of a pull destination, the files a pull has
+// written but not yet moved into place. It only exists while a pull runs, or
+// after one was killed, in which case the next pull discards it.
+const stagingDir = ".file-syncer-staging"
+
+// stagedWrites redirects the file writes of a pull into a staging directory
+// inside the destination and moves them into place only once every file was
+// written. Removals, of .gitkeep placeholders and of extraneous paths with
+// -clean, wait until then as well, and the directories created for the
+// staged files are removed again if the pull is discarded. A pull that fails
+// or is interrupted before then leaves the destination as it was. A nil
+// *stagedWrites writes and removes in place.
+type stagedWrites struct {
+	dir string
+	// staged maps each destination path to its file in dir
+	staged map[string]string
+	// order lists the destination paths in the order they were staged
+	order []string
+	// modes holds the permissions of the read-only destination files made
+	// writable for the pull, to restore if it is discarded
+	modes map[string]os.FileMode
+	// dirs lists the directories created in the destination, parents
+	// first, to remove again if the pull is discarded
+	dirs []string
+	// removals lists the destination paths to remove once the staged files
+	// are in place
+	removals []string
+}
+
+// newStagedWrites prepares the staging directory of dstDir, discarding the
+// leftovers of a pull that was interrupted.
+func newStagedWrites(dstDir string) (*stagedWrites, error) {
+	dir := filepath.Join(dstDir, stagingDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to remove stale staging directory: %w", err)
+	}
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	return &stagedWrites{dir: dir, staged: map[string]string{}, modes: map[string]os.FileMode{}}, nil
+}
+
+// target returns the path to write dst to: a file in the staging directory,
+// the same one for every call with dst, or dst itself if w is nil.
+func (w *stagedWrites) target(dst string) string {
+	if w == nil {
+		return dst
+	}
+	if tmp, ok := w.staged[dst]; ok {
+		return tmp
+	}
+	// Numbered names keep the staged paths short and flat
+	tmp := filepath.Join(w.dir, strconv.Itoa(len(w.order)))
+	w.staged[dst] = tmp
+	w.order = append(w.order, dst)
+	return tmp
+}
+
+// madeWritable records that the read-only file dst, whose permissions were
+// perm, was made writable so that it can be replaced.
+func (w *stagedWrites) madeWritable(dst string, perm os.FileMode) {
+	if w == nil {
+		return
+	}
+	if _, ok := w.modes[dst]; !ok {
+		w.modes[dst] = perm
+	}
+}
+
+// mkdirAll creates dir along with any missing parents, like os.MkdirAll,
+// and records the directories it created.
+func (w *stagedWrites) mkdirAll(dir string, perm os.FileMode) error {
+	if w == nil {
+		return os.MkdirAll(dir, perm)
+	}
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil || filepath.Dir(d) == d {
+			break
+		}
+		missing = append(missing, d)
+	}
+	if err := os.MkdirAll(dir, perm); err != nil {
+		return err
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		w.dirs = append(w.dirs, missing[i])
+	}
+	return nil
+}
+
+// remove removes path along with anything below it, or, if w is not nil,
+// records it for removal once the staged files are in place.
+func (w *stagedWrites) remove(path string) error {
+	if w == nil {
+		return os.RemoveAll(path)
+	}
+	w.removals = append(w.removals, path)
+	return nil
+}
+
+// commit moves the staged files into place, one rename each, then makes
+// the recorded removals and removes the staging directory. The parent
+// directories of the files already exist, as they are created while the
+// files are staged.
+func (w *stagedWrites) commit() error {
+	if w == nil {
+		return nil
+	}
+	for _, dst := range w.order {
+		if err := os.Rename(w.staged[dst], dst); err != nil {
+			return err
+		}
+		// The staged file already has the permissions to keep
+		delete(w.modes, dst)
+	}
+	// The directories now hold the pull's files, or are meant to be empty
+	w.dirs = nil
+	for _, path := range w.removals {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(w.dir)
+}
+
+// discard removes the staging directory along with any files not yet
+// committed, makes the destination files that were made writable for them
+// read-only again and removes the directories created for them.
+func (w *stagedWrites) discard() error {
+	if w == nil {
+		return nil
+	}
+	for dst, perm := range w.modes {
+		os.Chmod(dst, perm)
+	}
+	err := os.RemoveAll(w.dir)
+	// Deepest first; a directory that holds files by now is kept
+	for _, dir := range slices.Backward(w.dirs) {
+		os.Remove(dir)
+	}
+	return err
+}
@@ -0,0 +1,232 @@
+package syncer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readTestFiles returns the content of the named files below dir.
+func readTestFiles(t *testing.T, dir string, names ...string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		files[name] = string(content)
+	}
+	return files
+}
+
+func TestStagedWritesCommit(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{"a.txt": "new a", "dir/b.txt": "new b"})
+	createTestFiles(t, dstDir, map[string]string{"a.txt": "old a", "local.txt": "local"})
+
+	stage, err := newStagedWrites(dstDir)
+	if err != nil {
+		t.Fatalf("newStagedWrites() failed: %v", err)
+	}
+	if _, err := syncFiles(srcDir, dstDir, syncOptions{Staging: stage}); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+
+	// Nothing reaches the destination before the commit
+	if got := readTestFiles(t, dstDir, "a.txt")["a.txt"]; got != "old a" {
+		t.Errorf("a.txt before commit = %q, want %q", got, "old a")
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "dir", "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("dir/b.txt exists before commit: %v", err)
+	}
+
+	if err := stage.commit(); err != nil {
+		t.Fatalf("commit() failed: %v", err)
+	}
+	want := map[string]string{"a.txt": "new a", "dir/b.txt": "new b", "local.txt": "local"}
+	for name, content := range readTestFiles(t, dstDir, "a.txt", "dir/b.txt", "local.txt") {
+		if content != want[name] {
+			t.Errorf("%s = %q, want %q", name, content, want[name])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, stagingDir)); !os.IsNotExist(err) {
+		t.Errorf("staging directory left behind after commit: %v", err)
+	}
+}
+
+func TestStagedWritesInterruptedSyncKeepsDestination(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{
+		"a.txt": "new a",
+		"b.txt": "new b",
+		"c.txt": strings.Repeat("c", 100),
+		"d.txt": "new d",
+	})
+	prior := map[string]string{"a.txt": "old a", "b.txt": "old b", "d.txt": "old d"}
+	createTestFiles(t, dstDir, prior)
+
+	// c.txt fails the sync after a.txt and b.txt were copied
+	stage, err := newStagedWrites(dstDir)
+	if err != nil {
+		t.Fatalf("newStagedWrites() failed: %v", err)
+	}
+	opts := syncOptions{Staging: stage, MaxFileSize: 10, FailOversize: true}
+	if _, err := syncFiles(srcDir, dstDir, opts); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("syncFiles() error = %v, want ErrFileTooLarge", err)
+	}
+	if len(stage.order) != 2 {
+		t.Fatalf("staged %v, want a.txt and b.txt", stage.order)
+	}
+	if err := stage.discard(); err != nil {
+		t.Fatalf("discard() failed: %v", err)
+	}
+
+	for name, content := range readTestFiles(t, dstDir, "a.txt", "b.txt", "d.txt") {
+		if content != prior[name] {
+			t.Errorf("%s = %q after the failed sync, want the prior %q", name, content, prior[name])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, "c.txt")); !os.IsNotExist(err) {
+		t.Errorf("c.txt written by the failed sync: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dstDir, stagingDir)); !os.IsNotExist(err) {
+		t.Errorf("staging directory left behind after discard: %v", err)
+	}
+}
+
+func TestStagedWritesStagesDirectoriesAndRemovals(t *testing.T) {
+	tests := []struct {
+		name   string
+		commit bool
+	}{
+		{name: "discard"},
+		{name: "commit", commit: true},
+	}
+
+	for _, tt := range tests {
+		commit := tt.commit
+		t.Run(tt.name, func(t *testing.T) {
+			srcDir, dstDir := t.TempDir(), t.TempDir()
+			createTestFiles(t, srcDir, map[string]string{"new/deep/b.txt": "b", "kept/" + gitKeepFile: ""})
+			createTestFiles(t, dstDir, map[string]string{"extra.txt": "extra", "kept/" + gitKeepFile: ""})
+
+			stage, err := newStagedWrites(dstDir)
+			if err != nil {
+				t.Fatalf("newStagedWrites() failed: %v", err)
+			}
+			opts := syncOptions{Staging: stage, StripGitKeep: true}
+			if _, err := syncFiles(srcDir, dstDir, opts); err != nil {
+				t.Fatalf("syncFiles() failed: %v", err)
+			}
+			// As with -clean
+			if _, err := removeExtraneous(srcDir, dstDir, opts); err != nil {
+				t.Fatalf("removeExtraneous() failed: %v", err)
+			}
+			for _, name := range []string{"extra.txt", "kept/" + gitKeepFile} {
+				if _, err := os.Stat(filepath.Join(dstDir, filepath.FromSlash(name))); err != nil {
+					t.Errorf("%s removed before the commit: %v", name, err)
+				}
+			}
+
+			if commit {
+				err = stage.commit()
+			} else {
+				err = stage.discard()
+			}
+			if err != nil {
+				t.Fatalf("commit or discard failed: %v", err)
+			}
+			for name, wantExists := range map[string]bool{
+				"extra.txt":           !commit,
+				"kept/" + gitKeepFile: !commit,
+				"new":                 commit,
+				"new/deep/b.txt":      commit,
+			} {
+				_, err := os.Stat(filepath.Join(dstDir, filepath.FromSlash(name)))
+				if exists := err == nil; exists != wantExists {
+					t.Errorf("%s exists = %v, want %v", name, exists, wantExists)
+				}
+			}
+		})
+	}
+}
+
+func TestNewStagedWritesDiscardsStaleStaging(t *testing.T) {
+	dstDir := t.TempDir()
+	// A pull that was killed leaves its staged files behind
+	createTestFiles(t, dstDir, map[string]string{stagingDir + "/0": "partial"})
+
+	stage, err := newStagedWrites(dstDir)
+	if err != nil {
+		t.Fatalf("newStagedWrites() failed: %v", err)
+	}
+	defer stage.discard()
+	entries, err := os.ReadDir(filepath.Join(dstDir, stagingDir))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("staging directory entries = %v, %v, want an empty directory", entries, err)
+	}
+}
+
+func TestStagedWritesTarget(t *testing.T) {
+	var none *stagedWrites
+	if got := none.target("/dst/a.txt"); got != "/dst/a.txt" {
+		t.Errorf("nil target() = %q, want the destination itself", got)
+	}
+
+	stage, err := newStagedWrites(t.TempDir())
+	if err != nil {
+		t.Fatalf("newStagedWrites() failed: %v", err)
+	}
+	defer stage.discard()
+	a := stage.target("/dst/a.txt")
+	if a == "/dst/a.txt" || filepath.Dir(a) != stage.dir {
+		t.Errorf("target() = %q, want a path in %s", a, stage.dir)
+	}
+	if again := stage.target("/dst/a.txt"); again != a {
+		t.Errorf("second target() = %q, want %q", again, a)
+	}
+	if b := stage.target("/dst/b.txt"); b == a {
+		t.Errorf("target() of another file = %q, want a different path", b)
+	}
+}
+
+func TestStagedWritesDiscardRestoresReadOnly(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{"locked.txt": "new"})
+	createTestFiles(t, dstDir, map[string]string{"locked.txt": "old"})
+	dst := filepath.Join(dstDir, "locked.txt")
+	if err := os.Chmod(dst, 0444); err != nil {
+		t.Fatalf("failed to make destination read-only: %v", err)
+	}
+	// Let the temporary directory be removed
+	t.Cleanup(func() { os.Chmod(dst, 0644) })
+
+	stage, err := newStagedWrites(dstDir)
+	if err != nil {
+		t.Fatalf("newStagedWrites() failed: %v", err)
+	}
+	opts := syncOptions{Staging: stage, ProtectReadOnly: true, ForceOverwrite: true}
+	if err := copyFile(filepath.Join(srcDir, "locked.txt"), dst, 0644, opts); err != nil {
+		t.Fatalf("copyFile() failed: %v", err)
+	}
+	// The pull fails before committing
+	if err := stage.discard(); err != nil {
+		t.Fatalf("discard() failed: %v", err)
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("failed to stat destination: %v", err)
+	}
+	if info.Mode().Perm() != 0444 {
+		t.Errorf("destination mode = %v, want it read-only again", info.Mode().Perm())
+	}
+	if content, _ := os.ReadFile(dst); string(content) != "old" {
+		t.Errorf("destination = %q, want %q", content, "old")
+	}
+}
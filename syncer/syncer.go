@@ -0,0 +1,1023 @@
+// Package syncer synchronizes the contents of a local folder with a git
+// repository. It implements the push and pull operations behind the
+// file-syncer command and can be embedded in other Go programs:
+//
+//	s, err := syncer.New(syncer.Config{
+//		Mode:       syncer.ModePush,
+//		FolderPath: "./myfiles",
+//		RepoURL:    "git@github.com:user/repo.git",
+//		Branch:     "main",
+//	}, slog.Default())
+//	if err != nil {
+//		return err
+//	}
+//	result, err := s.Push(ctx)
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Syncer runs push and pull operations for a validated Config.
+type Syncer struct {
+	config Config
+	logger *slog.Logger
+	// keyPath is the temporary identity file written from SSHKeyData
+	// during a run
+	keyPath string
+	// stdin supplies the file list for -from-stdin
+	stdin io.Reader
+	// snapshotTaken, if set, is called right after a -snapshot was taken
+	snapshotTaken func()
+	// cycleDone, if set, is called after each cycle of PullLoop
+	cycleDone func(cycle int, err error)
+	// caseInsensitive, if set, replaces the probe for a case-insensitive
+	// destination filesystem
+	caseInsensitive func(dir string) (bool, error)
+}
+
+// RunResult describes the outcome of a push.
+type RunResult struct {
+	// Branch is the branch that was pushed to.
+	Branch string
+	// Commit is the hash of the pushed commit, empty if nothing was pushed.
+	Commit string
+	// Stats lists the files changed by the push.
+	Stats FileChangeStats
+	// Pushed reports whether a commit was pushed to the remote.
+	Pushed bool
+	// Tag is the tag created on the pushed commit, if any.
+	Tag string
+	// Conflicts lists the files left in conflict by a sync.
+	Conflicts []string
+	// RunID is the identifier attached to the run's log lines and metrics.
+	RunID string
+	// Branches holds the result of every branch, the first included, when
+	// pushing to several branches. The other fields describe the first one.
+	Branches []*RunResult
+}
+
+// New validates config and returns a Syncer for it. A nil logger falls back
+// to slog.Default().
+func New(config Config, logger *slog.Logger) (*Syncer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if config.CommitMessageFile != "" {
+		// git commit runs inside the clone, so relative paths would not resolve
+		path, err := filepath.Abs(config.CommitMessageFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve commit message file: %w", err)
+		}
+		config.CommitMessageFile = path
+	}
+	if config.RepoFile != "" {
+		url, err := readRepoFile(config.RepoFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RepoURL = url
+	}
+	// Validate has already checked that the URL is well-formed
+	config.RepoURL, _ = normalizeRepoURL(config.RepoURL)
+	if config.MirrorRemote != "" {
+		config.MirrorRemote, _ = normalizeRepoURL(config.MirrorRemote)
+	}
+	if len(config.Sparse) > 0 {
+		sparse := make([]string, len(config.Sparse))
+		for i, dir := range config.Sparse {
+			sparse[i] = cleanSparsePath(dir)
+		}
+		config.Sparse = sparse
+	}
+	s := &Syncer{config: config, logger: logger, stdin: os.Stdin}
+	if config.UseNetrc {
+		s.checkNetrc()
+	}
+	return s, nil
+}
+
+// Push copies the local folder into a fresh clone of the repository, commits
+// any changes and pushes them, to Config.Branch and then to each of
+// Config.Branches. It returns ErrNoChanges, along with a result, when there
+// is nothing to push to any branch.
+func (s *Syncer) Push(ctx context.Context) (result *RunResult, err error) {
+	config := s.config
+	logger := s.logger
+	result = &RunResult{Branch: config.Branch, RunID: config.RunID}
+
+	metrics := runMetrics{Start: time.Now()}
+	defer func() {
+		if result != nil {
+			metrics.Added, metrics.Modified = len(result.Stats.Added), len(result.Stats.Modified)
+			metrics.Deleted, metrics.Renamed = len(result.Stats.Deleted), len(result.Stats.Renamed)
+		}
+		s.recordMetrics(metrics, err)
+	}()
+
+	logger.Info("Starting push operation")
+
+	// Create absolute path for folder
+	absPath, err := filepath.Abs(config.FolderPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve folder path: %w", err)
+	}
+
+	// Keep overlapping runs on the same folder apart
+	release, err := s.lock(ctx, absPath)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	removeKey, err := s.installSSHKey()
+	if err != nil {
+		return nil, err
+	}
+	defer removeKey()
+
+	restoreBranch, err := s.resolveBranch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer restoreBranch()
+	config.Branch = s.config.Branch
+	result.Branch = config.Branch
+
+	// Check if folder exists
+	info, err := os.Stat(absPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrFolderMissing, absPath)
+	}
+	singleFile := err == nil && !info.IsDir()
+	if singleFile && !config.AllowSingleFile {
+		return nil, fmt.Errorf("folder must be a directory: %s (use -allow-single-file to push a single file)", absPath)
+	}
+
+	schema, err := config.loadSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	// Let the pre-hook prepare the folder before anything is read from it
+	if config.PreHook != "" {
+		hookDir := absPath
+		if singleFile {
+			hookDir = filepath.Dir(absPath)
+		}
+		if err := s.runHook(ctx, "pre-hook", config.PreHook, hookDir, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := config.syncOptions()
+	if config.Progress {
+		opts.Progress = s.logProgress
+	}
+	if config.ExportIgnore && !singleFile {
+		opts.ExportIgnore, err = loadExportIgnore(absPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Read the file list up front so that a bad path fails before cloning
+	var fileList []string
+	if config.FromStdin {
+		fileList, err = readFileList(s.stdin, absPath)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Read file list from stdin", "files", len(fileList))
+	}
+
+	// Freeze the source so that writes during the git operations don't
+	// leak into the commit
+	if config.Snapshot {
+		snapshotDir, removeSnapshot, err := s.makeTempDir()
+		if err != nil {
+			return nil, err
+		}
+		defer removeSnapshot()
+		logger.Info("Taking snapshot of folder", "source", absPath, "snapshot", snapshotDir)
+		absPath, err = takeSnapshot(absPath, snapshotDir, singleFile, fileList, opts)
+		if err != nil {
+			return nil, err
+		}
+		if s.snapshotTaken != nil {
+			s.snapshotTaken()
+		}
+	}
+
+	// Check a saved plan against the source before doing any git work
+	var plan *Plan
+	if config.ApplyPlanPath != "" {
+		plan, err = readPlan(config.ApplyPlanPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := plan.checkTarget(config); err != nil {
+			return nil, err
+		}
+		logger.Info("Verifying plan preconditions", "plan", config.ApplyPlanPath, "operations", len(plan.Operations))
+		if err := plan.verifySource(absPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.LFS {
+		if err := s.checkLFS(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// Clone the repository into a temporary directory, or bring the
+	// persistent checkout up to date
+	var repoDir string
+	if config.WorkDir != "" {
+		repoDir, err = filepath.Abs(config.WorkDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve work directory: %w", err)
+		}
+		if err := s.updateWorkDir(ctx, repoDir); err != nil {
+			return nil, err
+		}
+	} else {
+		tempDir, removeTemp, err := s.makeTempDir()
+		if err != nil {
+			return nil, err
+		}
+		defer removeTemp()
+
+		if err := s.cloneForPush(ctx, tempDir); err != nil {
+			return nil, err
+		}
+		repoDir = tempDir
+	}
+
+	if config.Signoff {
+		if err := s.checkSignoffIdentity(ctx, repoDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// Push to a new branch off the cloned one, e.g. for a pull request
+	pusher := s
+	if config.BranchTemplate != "" {
+		branch, err := s.checkoutTemplateBranch(ctx, repoDir, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		bs := *s
+		bs.config.Branch = branch
+		bs.logger = s.logger.With("branch", branch)
+		pusher = &bs
+	}
+
+	src := pushSource{absPath: absPath, singleFile: singleFile, fileList: fileList, opts: opts, plan: plan, schema: schema}
+	result, err = pusher.pushBranch(ctx, repoDir, src)
+	if len(config.Branches) == 0 || (err != nil && !errors.Is(err, ErrNoChanges)) {
+		return result, err
+	}
+
+	// Push the same content to every further branch from the same clone
+	first := *result
+	result.Branches = []*RunResult{&first}
+	changed := err == nil
+	for _, branch := range config.Branches {
+		bs := *s
+		bs.config.Branch = branch
+		bs.logger = s.logger.With("branch", branch)
+		if err := bs.checkoutBranch(ctx, repoDir); err != nil {
+			return result, err
+		}
+		branchResult, err := bs.pushBranch(ctx, repoDir, src)
+		if err != nil && !errors.Is(err, ErrNoChanges) {
+			return result, err
+		}
+		changed = changed || err == nil
+		result.Branches = append(result.Branches, branchResult)
+	}
+	if !changed {
+		return result, ErrNoChanges
+	}
+	return result, nil
+}
+
+// pushSource is what pushBranch copies into the clone: the prepared source
+// folder, or a saved plan to apply instead.
+type pushSource struct {
+	absPath    string
+	singleFile bool
+	fileList   []string
+	opts       syncOptions
+	plan       *Plan
+	schema     *Schema
+}
+
+// pushBranch syncs src into the clone at repoDir, which has the branch
+// Config.Branch checked out, then commits and pushes the changes. It
+// returns ErrNoChanges, along with a result, when there is nothing to push.
+func (s *Syncer) pushBranch(ctx context.Context, repoDir string, src pushSource) (*RunResult, error) {
+	config := s.config
+	logger := s.logger
+	result := &RunResult{Branch: config.Branch, RunID: config.RunID}
+	absPath, opts := src.absPath, src.opts
+	opts.FileCopied = s.logCopy(repoDir)
+	opts.CopyRetried = s.logCopyRetry
+	var err error
+
+	// Never write files the repository ignores into the work tree
+	if config.RespectRepoGitignore {
+		opts.Ignore, err = loadGitignore(repoDir)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Applying repository .gitignore", "rules", len(opts.Ignore))
+	}
+
+	if src.plan != nil {
+		// Apply exactly the planned operations
+		logger.Info("Applying plan", "source", absPath, "destination", repoDir)
+		if err := src.plan.apply(absPath, repoDir, opts); err != nil {
+			return nil, fmt.Errorf("failed to apply plan: %w", err)
+		}
+	} else {
+		// Sync files from source folder to repo
+		logger.Info("Syncing files", "source", absPath, "destination", repoDir)
+		sync := syncFiles
+		switch {
+		case src.singleFile:
+			sync = syncFile
+		case config.FromStdin:
+			sync = func(srcDir, dstDir string, opts syncOptions) (syncCounts, error) {
+				return syncFileList(srcDir, dstDir, src.fileList, opts)
+			}
+		}
+		counts, err := sync(absPath, repoDir, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sync files: %w", err)
+		}
+		for _, path := range counts.Oversized {
+			logger.Warn("Skipping file larger than the maximum file size", "path", path, "max_file_size", config.MaxFileSize)
+		}
+		for _, path := range counts.Special {
+			logger.Warn("Skipping special file, such as a FIFO, socket or device", "path", path)
+		}
+
+		// Files deleted from the folder are still in the clone, so remove
+		// them for git to record the deletions. A single file or a file
+		// list only updates the files it names.
+		if !src.singleFile && !config.FromStdin {
+			removed, err := removeExtraneous(absPath, repoDir, opts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to remove deleted files: %w", err)
+			}
+			for _, path := range removed {
+				logger.Debug("Removed file deleted from the folder", "path", path)
+			}
+			logger.Info("Deleted files removed", "removed", len(removed))
+		}
+	}
+
+	// Validate the synced content before anything is committed
+	if src.schema != nil {
+		logger.Info("Validating folder structure", "schema", config.SchemaPath)
+		if err := src.schema.Validate(repoDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// LFS-tracked files must pass through the LFS filter when staged
+	var lfs bool
+	if config.LFS {
+		if lfs, err = usesLFS(repoDir); err != nil {
+			return nil, err
+		}
+		if lfs {
+			if err := s.setupLFS(ctx, repoDir); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Stage everything first so that git status reports renames. With
+	// -only-tracked, files the repository does not track are left out, and
+	// with -no-mode-changes, mode-only differences.
+	logger.Info("Adding changes")
+	add, untracked := "-A", "--untracked-files=all"
+	if config.OnlyTracked {
+		add, untracked = "-u", "--untracked-files=no"
+	}
+	if err := s.runCommand(ctx, repoDir, "git", s.indexArgs("add", add)...); err != nil {
+		return nil, fmt.Errorf("failed to add changes: %w", err)
+	}
+
+	// Check if there are changes
+	output, err := s.runCommandOutput(ctx, repoDir, "git", s.indexArgs("status", "--porcelain", "-z", untracked)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check git status: %w", err)
+	}
+
+	// Generate meaningful commit message based on changes
+	stats := parseGitStatus(output)
+	result.Stats = stats
+	commitSubject, commitBody := generateCommitMessage(stats, config.SummaryLimit)
+	if src.plan != nil {
+		commitSubject, commitBody = src.plan.Subject, src.plan.Body
+	}
+
+	// Only report the change set, with or without changes
+	if config.ListChanges {
+		logger.Info("Listing changes, skipping commit and push", "changed", stats.total())
+		return result, nil
+	}
+
+	// Write the plan for review instead of committing
+	if config.PlanOutPath != "" {
+		newPlan, err := buildPlan(config, absPath, stats, commitSubject, commitBody)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build plan: %w", err)
+		}
+		if err := writePlan(config.PlanOutPath, newPlan); err != nil {
+			return nil, err
+		}
+		logger.Info("Plan written", "path", config.PlanOutPath, "operations", len(newPlan.Operations))
+		return result, nil
+	}
+
+	noChanges := strings.TrimSpace(output) == ""
+	if !noChanges && len(config.CommitPaths) > 0 && !stats.touchesPaths(config.CommitPaths) {
+		logger.Info("No changes under -commit-paths, skipping commit", "changed", len(stats.paths()))
+		return result, ErrNoChanges
+	}
+	if noChanges && !config.CommitEmpty {
+		logger.Info("No changes to push")
+		return result, ErrNoChanges
+	}
+	if noChanges {
+		logger.Info("No changes, creating empty heartbeat commit")
+		commitSubject, commitBody = heartbeatSubject, ""
+	}
+
+	// Refuse mass changes, e.g. from pushing the wrong folder
+	if config.MaxChanges > 0 && stats.total() > config.MaxChanges {
+		if !config.ForceLarge {
+			return nil, fmt.Errorf("%w: %d files changed, the limit is %d (pass -force-large to push anyway)",
+				ErrTooManyChanges, stats.total(), config.MaxChanges)
+		}
+		logger.Warn("Pushing more changes than -max-changes allows", "changed", stats.total(), "max_changes", config.MaxChanges)
+	}
+
+	// Catch content that was never fetched from LFS
+	if err := s.checkLFSPointers(repoDir, stats); err != nil {
+		return nil, err
+	}
+
+	// Leave the changes staged in the work directory for review
+	if config.NoCommit {
+		logger.Info("Changes staged, not committing", "work_dir", repoDir,
+			"added", stats.Added, "modified", stats.Modified, "deleted", stats.Deleted, "renamed", stats.Renamed)
+		return result, nil
+	}
+
+	// Report what would be committed and stop
+	if config.DryRun {
+		logger.Info("Dry run, skipping commit and push", "message", commitSubject)
+		if config.Diff {
+			if err := s.printDiffs(ctx, repoDir, stats.Modified, os.Stdout); err != nil {
+				return nil, fmt.Errorf("failed to diff changes: %w", err)
+			}
+		}
+		return result, nil
+	}
+
+	var amended bool
+	var lease string
+	if config.CommitPerFile && !noChanges {
+		// Unstage again, then stage and commit every changed path on its own
+		if err := s.runCommand(ctx, repoDir, "git", "reset", "-q"); err != nil {
+			return nil, fmt.Errorf("failed to unstage changes: %w", err)
+		}
+		for _, fc := range perFileCommits(stats) {
+			logger.Info("Committing file", "path", fc.Path, "message", fc.Message)
+			paths := []string{fc.Path}
+			if fc.OldPath != "" {
+				paths = append(paths, fc.OldPath)
+			}
+			if err := s.commitPaths(ctx, repoDir, paths, fc.Message); err != nil {
+				return nil, err
+			}
+		}
+	} else if config.GroupBy == GroupByExt && !noChanges {
+		// Unstage again, then stage and commit each extension's changes together
+		if err := s.runCommand(ctx, repoDir, "git", "reset", "-q"); err != nil {
+			return nil, fmt.Errorf("failed to unstage changes: %w", err)
+		}
+		for _, gc := range extensionCommits(stats, config.SummaryLimit) {
+			logger.Info("Committing group", "extension", gc.Ext, "files", len(gc.Paths))
+			if err := s.commitPaths(ctx, repoDir, gc.Paths, gc.Message); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		// Replace the branch tip if it is an earlier commit of this author
+		if config.Amend {
+			if lease, err = s.amendLease(ctx, repoDir); err != nil {
+				return nil, err
+			}
+			amended = lease != ""
+		}
+
+		// Commit changes
+		if config.CommitMessageFile != "" {
+			logger.Info("Committing changes", "message_file", config.CommitMessageFile, "amend", amended)
+		} else {
+			logger.Info("Committing changes", "message", commitSubject, "amend", amended)
+		}
+		commitMessage := commitSubject
+		if commitBody != "" {
+			commitMessage = commitSubject + "\n\n" + commitBody
+		}
+		// The prefix goes into the message, so it can't be committed with -F
+		if config.CommitMessageFile != "" && config.MessagePrefix != "" {
+			data, err := os.ReadFile(config.CommitMessageFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read commit message file: %w", err)
+			}
+			commitMessage = string(data)
+		}
+		commit := s.commit
+		if amended {
+			commit = s.amendCommit
+		}
+		if err := commit(ctx, repoDir, commitMessage); err != nil {
+			return nil, err
+		}
+	}
+
+	// Fold the latest sync commits into one once enough have accumulated
+	var squashed bool
+	if config.SquashAfter > 0 {
+		squashed, lease, err = s.squashSyncCommits(ctx, repoDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	commit, err := s.runCommandOutput(ctx, repoDir, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve commit: %w", err)
+	}
+	result.Commit = strings.TrimSpace(commit)
+
+	// Resolve the tag before pushing so an existing tag fails the run early
+	tag, err := config.tagName(time.Now(), result.Commit)
+	if err != nil {
+		return nil, err
+	}
+	if tag != "" && !config.ForceTag {
+		if _, err := s.runCommandOutput(ctx, repoDir, "git", "rev-parse", "-q", "--verify", "refs/tags/"+tag); err == nil {
+			return nil, fmt.Errorf("%w: %s (use -force-tag to replace it)", ErrTagExists, tag)
+		}
+	}
+
+	if lfs {
+		if err := s.lfsPush(ctx, repoDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// Push to remote
+	logger.Info("Pushing to remote", "branch", config.Branch)
+	rewritten := squashed || amended
+	if rewritten && lease != "" && !config.ForceUnsafe {
+		err = s.pushRewritten(ctx, repoDir, lease)
+	} else {
+		err = s.push(ctx, repoDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Pushed = true
+
+	// Tag the pushed commit
+	if tag != "" {
+		logger.Info("Creating tag", "tag", tag)
+		if err := s.runCommand(ctx, repoDir, "git", tagArgs(tag, commitSubject, config.ForceTag)...); err != nil {
+			return nil, fmt.Errorf("failed to create tag %s: %w", tag, err)
+		}
+		if err := s.runCommand(ctx, repoDir, "git", pushTagArgs(tag, config.ForceTag, config.NoVerify)...); err != nil {
+			return nil, fmt.Errorf("failed to push tag %s: %w", tag, err)
+		}
+		result.Tag = tag
+	}
+
+	// Copy the pushed branch to the backup remote
+	if config.MirrorRemote != "" {
+		if err := s.pushMirror(ctx, repoDir, result.Tag, rewritten); err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Info("Push completed successfully")
+	return result, nil
+}
+
+// makeTempDir creates the temporary directory a run clones into. The
+// returned function removes it, unless -keep-temp is set, in which case it
+// logs where the directory was left for inspection.
+func (s *Syncer) makeTempDir() (string, func(), error) {
+	dir, err := os.MkdirTemp("", "file-syncer-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	return dir, func() {
+		if s.config.KeepTemp {
+			s.logger.Info("Keeping temporary directory", "path", dir)
+			return
+		}
+		os.RemoveAll(dir)
+	}, nil
+}
+
+// commitPaths stages paths and commits them with message.
+func (s *Syncer) commitPaths(ctx context.Context, dir string, paths []string, message string) error {
+	if err := s.runCommand(ctx, dir, "git", s.indexArgs(append([]string{"add", "-A", "--"}, paths...)...)...); err != nil {
+		return fmt.Errorf("failed to add %s: %w", strings.Join(paths, ", "), err)
+	}
+	return s.commit(ctx, dir, message)
+}
+
+// push pushes the branch to origin. A lease-protected force push that is
+// rejected because the remote moved after the clone fails with
+// ErrPushRejected.
+func (s *Syncer) push(ctx context.Context, dir string) error {
+	output, err := s.runCommandStderr(ctx, dir, "git", pushArgs(s.config)...)
+	if err == nil {
+		return nil
+	}
+	err = classifyPushError(output, err)
+	// The lease protects commits pushed since the clone, so it is never
+	// refreshed behind the user's back
+	if s.config.Force && errors.Is(err, ErrPushRejected) {
+		return fmt.Errorf("%w (the remote branch moved since it was cloned; run again to sync on top of it, or pass -force-unsafe to overwrite it)", err)
+	}
+	return err
+}
+
+// cloneForPush prepares dir as a checkout of the configured branch. The
+// remote is probed first: an empty remote gets a freshly initialized
+// repository, and a remote without the branch has its default branch cloned
+// and the branch created from it. Authentication or network failures surface
+// as ErrCloneFailed instead of being mistaken for a missing branch.
+func (s *Syncer) cloneForPush(ctx context.Context, dir string) error {
+	config := s.config
+
+	branches, err := s.remoteBranches(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCloneFailed, err)
+	}
+
+	switch {
+	case len(branches) == 0:
+		// Cloning an empty repository leaves no HEAD to branch from, so
+		// start a new repository and push the branch as its first commit
+		s.logger.Info("Remote repository is empty, initializing new repository", "url", SanitizeURL(config.RepoURL), "branch", config.Branch)
+		if err := s.runCommand(ctx, dir, "git", "init"); err != nil {
+			return fmt.Errorf("failed to initialize repository: %w", err)
+		}
+		if err := s.runCommand(ctx, dir, "git", "remote", "add", "origin", config.RepoURL); err != nil {
+			return fmt.Errorf("failed to add remote: %w", err)
+		}
+		if err := s.runCommand(ctx, dir, "git", "symbolic-ref", "HEAD", "refs/heads/"+config.Branch); err != nil {
+			return fmt.Errorf("failed to create branch: %w", err)
+		}
+
+	case slices.Contains(branches, config.Branch):
+		s.logger.Info("Cloning repository", "url", SanitizeURL(config.RepoURL), "branch", config.Branch)
+		if err := s.runCommand(ctx, dir, "git", "clone", "--branch", config.Branch, config.RepoURL, "."); err != nil {
+			return fmt.Errorf("%w: %w", ErrCloneFailed, err)
+		}
+
+	default:
+		s.logger.Info("Branch not found, cloning default branch", "url", SanitizeURL(config.RepoURL), "branch", config.Branch)
+		if err := s.runCommand(ctx, dir, "git", "clone", config.RepoURL, "."); err != nil {
+			return fmt.Errorf("%w: %w", ErrCloneFailed, err)
+		}
+		// Create and checkout the branch
+		if err := s.runCommand(ctx, dir, "git", "checkout", "-b", config.Branch); err != nil {
+			return fmt.Errorf("failed to create branch: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkoutBranch switches the clone at dir to Config.Branch, so the same
+// content can be pushed to another branch. Like in cloneForPush, a branch the
+// remote does not have yet starts from the remote's default branch, or from
+// the current commit if the remote was empty.
+func (s *Syncer) checkoutBranch(ctx context.Context, dir string) error {
+	branch := s.config.Branch
+	start := "HEAD"
+	for _, ref := range []string{"refs/remotes/origin/" + branch, "refs/remotes/origin/HEAD"} {
+		if _, err := s.runCommandOutput(ctx, dir, "git", "rev-parse", "-q", "--verify", ref); err == nil {
+			start = ref
+			break
+		}
+	}
+
+	s.logger.Info("Switching branch", "branch", branch, "start", start)
+	if err := s.runCommand(ctx, dir, "git", "checkout", "-q", "-f", "-B", branch, start); err != nil {
+		return fmt.Errorf("failed to check out branch %s: %w", branch, err)
+	}
+	// Drop ignored files synced for the previous branch
+	if err := s.runCommand(ctx, dir, "git", "clean", "-ffdxq"); err != nil {
+		return fmt.Errorf("failed to clean work tree: %w", err)
+	}
+	return nil
+}
+
+// cloneRef clones the default branch into dir and checks out Config.Ref, a
+// tag, branch or commit, as a detached HEAD.
+func (s *Syncer) cloneRef(ctx context.Context, dir string) error {
+	ref := s.config.Ref
+	s.logger.Info("Cloning repository", "url", SanitizeURL(s.config.RepoURL), "ref", ref)
+	if err := s.runCommand(ctx, dir, "git", "clone", s.config.RepoURL, "."); err != nil {
+		return fmt.Errorf("%w: %w", ErrCloneFailed, err)
+	}
+
+	// Branches other than the default one only exist as remote-tracking refs
+	commit, err := s.runCommandOutput(ctx, dir, "git", "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		commit, err = s.runCommandOutput(ctx, dir, "git", "rev-parse", "--verify", "--quiet", "origin/"+ref+"^{commit}")
+	}
+	if err != nil {
+		return fmt.Errorf("ref %q does not exist in the repository", ref)
+	}
+	commit = strings.TrimSpace(commit)
+	if err := s.runCommand(ctx, dir, "git", "checkout", "--quiet", "--detach", commit); err != nil {
+		return fmt.Errorf("failed to check out %s: %w", ref, err)
+	}
+	s.logger.Info("Checked out ref", "ref", ref, "commit", commit)
+	return nil
+}
+
+// commit creates a commit of the staged changes with message.
+func (s *Syncer) commit(ctx context.Context, dir string, message string) error {
+	return s.runCommit(s.commitCommand(ctx, dir, message, false))
+}
+
+// amendCommit replaces the branch tip with a commit of its tree plus the
+// staged changes, with message (-amend).
+func (s *Syncer) amendCommit(ctx context.Context, dir string, message string) error {
+	return s.runCommit(s.commitCommand(ctx, dir, message, true))
+}
+
+// runCommit runs a command built by commitCommand.
+func (s *Syncer) runCommit(cmd *exec.Cmd) error {
+	if output, err := s.runStderr(cmd); err != nil {
+		if s.config.Sign && strings.Contains(output, "sign") {
+			return fmt.Errorf("%w: signing failed, check that gpg can use the signing key: %w", ErrCommitFailed, err)
+		}
+		return fmt.Errorf("%w: %w", ErrCommitFailed, err)
+	}
+	return nil
+}
+
+// Pull copies the contents of the repository branch into the local folder,
+// creating the folder if needed.
+func (s *Syncer) Pull(ctx context.Context) (err error) {
+	config := s.config
+	metrics := runMetrics{Start: time.Now()}
+	defer func() { s.recordMetrics(metrics, err) }()
+	logger := s.logger
+
+	logger.Info("Starting pull operation")
+
+	// Create absolute path for folder
+	absPath, err := filepath.Abs(config.FolderPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve folder path: %w", err)
+	}
+
+	// Keep overlapping runs on the same folder apart
+	release, err := s.lock(ctx, absPath)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	removeKey, err := s.installSSHKey()
+	if err != nil {
+		return err
+	}
+	defer removeKey()
+
+	restoreBranch, err := s.resolveBranch(ctx)
+	if err != nil {
+		return err
+	}
+	defer restoreBranch()
+	config.Branch = s.config.Branch
+
+	schema, err := config.loadSchema()
+	if err != nil {
+		return err
+	}
+
+	// Create folder if it doesn't exist
+	if err := os.MkdirAll(absPath, 0755); err != nil {
+		return fmt.Errorf("failed to create folder: %w", err)
+	}
+
+	if config.LFS {
+		if err := s.checkLFS(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Reuse the persistent checkout if configured, clone into a temporary
+	// directory otherwise
+	var repoDir string
+	if config.WorkDir != "" {
+		repoDir, err = filepath.Abs(config.WorkDir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve work directory: %w", err)
+		}
+		if err := s.updateWorkDir(ctx, repoDir); err != nil {
+			return err
+		}
+	} else {
+		// Create temporary directory for git operations
+		tempDir, removeTemp, err := s.makeTempDir()
+		if err != nil {
+			return err
+		}
+		defer removeTemp()
+
+		// Clone the repository
+		if config.Ref != "" {
+			if err := s.cloneRef(ctx, tempDir); err != nil {
+				return err
+			}
+		} else {
+			logger.Info("Cloning repository", "url", SanitizeURL(config.RepoURL), "branch", config.Branch)
+			args := []string{"clone", "--branch", config.Branch}
+			if config.RecurseSubmodules {
+				args = append(args, "--recurse-submodules")
+			}
+			if config.Depth > 0 {
+				args = append(args, "--depth", strconv.Itoa(config.Depth))
+			}
+			// Fetch file contents only for the paths that are checked out
+			if len(config.Sparse) > 0 {
+				args = append(args, "--filter=blob:none", "--sparse")
+			}
+			if err := s.runCommand(ctx, tempDir, "git", append(args, config.RepoURL, ".")...); err != nil {
+				return fmt.Errorf("%w: %w", ErrCloneFailed, err)
+			}
+			if len(config.Sparse) > 0 {
+				logger.Info("Checking out sparse paths", "paths", config.Sparse)
+				if err := s.runCommand(ctx, tempDir, "git", append([]string{"sparse-checkout", "set"}, config.Sparse...)...); err != nil {
+					return fmt.Errorf("failed to set up sparse checkout: %w", err)
+				}
+			}
+		}
+		repoDir = tempDir
+	}
+
+	// Check out the submodules at the commits the checkout records; this
+	// also covers -ref and reused work directories
+	if config.RecurseSubmodules {
+		logger.Info("Updating submodules")
+		if err := s.runCommand(ctx, repoDir, "git", "submodule", "update", "--init", "--recursive"); err != nil {
+			return fmt.Errorf("failed to update submodules: %w", err)
+		}
+	}
+
+	// Replace LFS pointer files with the real content
+	if config.LFS {
+		lfs, err := usesLFS(repoDir)
+		if err != nil {
+			return err
+		}
+		if lfs {
+			if err := s.lfsPull(ctx, repoDir); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Validate the repository content before touching the destination
+	if schema != nil {
+		logger.Info("Validating folder structure", "schema", config.SchemaPath)
+		if err := schema.Validate(repoDir); err != nil {
+			return err
+		}
+	}
+	if config.ScanConflictMarkers {
+		logger.Info("Scanning for conflict markers")
+		if err := checkConflictMarkers(repoDir, config.syncOptions()); err != nil {
+			return err
+		}
+	}
+
+	// Sync files from repo to destination folder
+	logger.Info("Syncing files", "source", repoDir, "destination", absPath)
+	opts := config.syncOptions()
+	if config.Progress {
+		opts.Progress = s.logProgress
+	}
+	opts.MetadataWarning = s.metadataWarner()
+	opts.FileCopied = s.logCopy(absPath)
+	opts.CopyRetried = s.logCopyRetry
+	if err := s.checkCaseCollisions(ctx, repoDir, absPath, opts); err != nil {
+		return err
+	}
+	cachePath := filepath.Join(absPath, hashCacheFile)
+	if config.HashCache {
+		if opts.HashCache, err = loadHashCache(cachePath); err != nil {
+			return err
+		}
+	}
+	// Stage the files so that a failed or interrupted pull leaves the
+	// destination as it was
+	if opts.Staging, err = newStagedWrites(absPath); err != nil {
+		return err
+	}
+	defer opts.Staging.discard()
+	var counts syncCounts
+	if config.Strategy == StrategyArchive {
+		counts, err = s.extractArchive(ctx, repoDir, absPath, opts)
+	} else {
+		counts, err = syncFiles(repoDir, absPath, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to sync files: %w", err)
+	}
+	// Mirror the repository by deleting what it does not contain. The
+	// paths are removed along with moving the staged files into place.
+	var removed []string
+	if config.Clean {
+		if removed, err = removeExtraneous(repoDir, absPath, opts); err != nil {
+			return fmt.Errorf("failed to remove extraneous files: %w", err)
+		}
+	}
+	if err := opts.Staging.commit(); err != nil {
+		return fmt.Errorf("failed to move synced files into place: %w", err)
+	}
+	logger.Info("Files synced", "copied", counts.Copied, "skipped", counts.Skipped)
+	for _, path := range counts.Special {
+		logger.Warn("Skipping special file, such as a FIFO, socket or device", "path", path)
+	}
+	metrics.Added, metrics.Modified = counts.Created, counts.Copied-counts.Created
+	if config.Clean {
+		for _, path := range removed {
+			logger.Debug("Removed extraneous path", "path", path)
+		}
+		logger.Info("Extraneous files removed", "removed", len(removed))
+		metrics.Deleted = len(removed)
+	}
+	if opts.HashCache != nil {
+		if err := opts.HashCache.save(cachePath); err != nil {
+			return err
+		}
+	}
+
+	if config.ArchiveOut != "" {
+		logger.Info("Writing archive", "path", config.ArchiveOut)
+		files, err := writeArchive(repoDir, config.ArchiveOut, opts)
+		if err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+		logger.Info("Archive written", "path", config.ArchiveOut, "files", files)
+	}
+
+	if config.PostHook != "" {
+		commit, err := s.runCommandOutput(ctx, repoDir, "git", "rev-parse", "HEAD")
+		if err != nil {
+			return fmt.Errorf("failed to resolve commit: %w", err)
+		}
+		if err := s.runHook(ctx, "post-hook", config.PostHook, absPath, strings.TrimSpace(commit)); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Pull completed successfully")
+	return nil
+}
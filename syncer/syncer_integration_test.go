@@ -0,0 +1,2366 @@
+//go:build integration
+
+package syncer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPushIntegrationPushesFilesToRemote(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"seed.txt": "initial content",
+	})
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "new-file.txt", "integration content")
+
+	config := Config{
+		Mode:       ModePush,
+		FolderPath: sourceDir,
+		RepoURL:    remote,
+		Branch:     "main",
+	}
+
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("runSyncer() push failed: %v", err)
+	}
+
+	verificationDir := t.TempDir()
+	runGit(t, verificationDir, "clone", "--branch", "main", remote, ".")
+
+	content, err := os.ReadFile(filepath.Join(verificationDir, "new-file.txt"))
+	if err != nil {
+		t.Fatalf("failed to read synced file: %v", err)
+	}
+
+	if string(content) != "integration content" {
+		t.Fatalf("unexpected file content: %q", string(content))
+	}
+}
+
+func TestPushIntegrationRemovesDeletedFiles(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"keep.txt":      "keep",
+		"gone.txt":      "gone",
+		"old/file.txt":  "old",
+		"build/out.txt": "excluded",
+	})
+
+	// The folder no longer has gone.txt or old/, and build/ is excluded
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "keep.txt", "keep")
+	if err := os.Mkdir(filepath.Join(sourceDir, "empty"), 0755); err != nil {
+		t.Fatalf("failed to create empty directory: %v", err)
+	}
+
+	config := Config{
+		Mode:          ModePush,
+		FolderPath:    sourceDir,
+		RepoURL:       remote,
+		Branch:        "main",
+		Exclude:       []string{"build"},
+		KeepEmptyDirs: true,
+	}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("runSyncer() push failed: %v", err)
+	}
+
+	files := gitOutput(t, remote, "ls-tree", "-r", "--name-only", "main")
+	if files != "build/out.txt\nempty/.gitkeep\nkeep.txt\n" {
+		t.Errorf("files on main = %q, want gone.txt and old/ removed", files)
+	}
+	if subject := gitOutput(t, remote, "log", "-1", "--format=%s", "main"); !strings.Contains(subject, "2 deleted") {
+		t.Errorf("commit subject = %q, want it to count the deletions", subject)
+	}
+
+	// The placeholder of a still empty directory is not deleted again
+	if err := runSyncer(t, config); !errors.Is(err, ErrNoChanges) {
+		t.Errorf("second push = %v, want ErrNoChanges", err)
+	}
+}
+
+func TestPushIntegrationMaxChanges(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	tests := []struct {
+		name       string
+		maxChanges int
+		forceLarge bool
+		wantErr    error
+	}{
+		{name: "under limit", maxChanges: 3},
+		{name: "over limit", maxChanges: 2, wantErr: ErrTooManyChanges},
+		{name: "over limit with force", maxChanges: 2, forceLarge: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := createRemoteRepoWithContent(t, map[string]string{"seed.txt": "seed"})
+			before := gitOutput(t, remote, "rev-parse", "main")
+
+			// Three changes: seed.txt modified, a.txt and b.txt added
+			sourceDir := t.TempDir()
+			writeTestFile(t, sourceDir, "seed.txt", "changed")
+			writeTestFile(t, sourceDir, "a.txt", "a")
+			writeTestFile(t, sourceDir, "b.txt", "b")
+
+			config := Config{
+				Mode:       ModePush,
+				FolderPath: sourceDir,
+				RepoURL:    remote,
+				Branch:     "main",
+				MaxChanges: tt.maxChanges,
+				ForceLarge: tt.forceLarge,
+			}
+			err := runSyncer(t, config)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("runSyncer() error = %v, want %v", err, tt.wantErr)
+				}
+				if after := gitOutput(t, remote, "rev-parse", "main"); after != before {
+					t.Error("push over -max-changes changed the remote")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runSyncer() push failed: %v", err)
+			}
+			if content := gitOutput(t, remote, "show", "main:a.txt"); content != "a" {
+				t.Errorf("a.txt on main = %q, want %q", content, "a")
+			}
+		})
+	}
+}
+
+func TestPushIntegrationListChanges(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"keep.txt":   "keep",
+		"edit.txt":   "before",
+		"remove.txt": "remove",
+	})
+	before := gitOutput(t, remote, "rev-parse", "main")
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "keep.txt", "keep")
+	writeTestFile(t, sourceDir, "edit.txt", "after")
+	writeTestFile(t, sourceDir, "new.txt", "new")
+
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", ListChanges: true}
+	s, err := New(config, nil)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	result, err := s.Push(context.Background())
+	if err != nil {
+		t.Fatalf("Push() with -list-changes failed: %v", err)
+	}
+
+	want := FileChangeStats{
+		Added:    []string{"new.txt"},
+		Modified: []string{"edit.txt"},
+		Deleted:  []string{"remove.txt"},
+		Renamed:  [][2]string{},
+	}
+	if !reflect.DeepEqual(result.Stats, want) {
+		t.Errorf("Stats = %+v, want %+v", result.Stats, want)
+	}
+	if result.Pushed {
+		t.Error("-list-changes must not push")
+	}
+	if after := gitOutput(t, remote, "rev-parse", "main"); after != before {
+		t.Error("-list-changes changed the remote")
+	}
+}
+
+func TestPushIntegrationReturnsRunResult(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"seed.txt": "initial content",
+	})
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "new-file.txt", "integration content")
+
+	s, err := New(Config{
+		Mode:       ModePush,
+		FolderPath: sourceDir,
+		RepoURL:    remote,
+		Branch:     "main",
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	result, err := s.Push(context.Background())
+	if err != nil {
+		t.Fatalf("Push() failed: %v", err)
+	}
+
+	if !result.Pushed || result.Branch != "main" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(result.Stats.Added) != 1 || result.Stats.Added[0] != "new-file.txt" {
+		t.Errorf("unexpected stats: %+v", result.Stats)
+	}
+
+	verificationDir := t.TempDir()
+	runGit(t, verificationDir, "clone", "--branch", "main", remote, ".")
+	head := strings.TrimSpace(gitOutput(t, verificationDir, "rev-parse", "HEAD"))
+	if result.Commit != head {
+		t.Errorf("result commit = %q, want remote HEAD %q", result.Commit, head)
+	}
+}
+
+func TestPullIntegrationPullsFilesFromRemote(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"pull-dir/file.txt": "pulled content",
+	})
+
+	destinationDir := t.TempDir()
+
+	config := Config{
+		Mode:       ModePull,
+		FolderPath: destinationDir,
+		RepoURL:    remote,
+		Branch:     "main",
+	}
+
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("runSyncer() pull failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destinationDir, "pull-dir", "file.txt"))
+	if err != nil {
+		t.Fatalf("failed to read pulled file: %v", err)
+	}
+
+	if string(content) != "pulled content" {
+		t.Fatalf("unexpected pulled content: %q", string(content))
+	}
+
+	if _, err := os.Stat(filepath.Join(destinationDir, ".git")); !os.IsNotExist(err) {
+		t.Fatalf(".git directory should not be present in destination")
+	}
+}
+
+func TestPushIntegrationPlanAndApply(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"seed.txt": "initial content",
+	})
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "seed.txt", "initial content")
+	writeTestFile(t, sourceDir, "planned.txt", "planned content")
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+
+	config := Config{
+		Mode:        ModePush,
+		FolderPath:  sourceDir,
+		RepoURL:     remote,
+		Branch:      "main",
+		PlanOutPath: planPath,
+	}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("runSyncer() with -plan-out failed: %v", err)
+	}
+
+	plan, err := readPlan(planPath)
+	if err != nil {
+		t.Fatalf("failed to read plan: %v", err)
+	}
+	if len(plan.Operations) != 1 || plan.Operations[0].Path != "planned.txt" {
+		t.Fatalf("unexpected plan operations: %+v", plan.Operations)
+	}
+
+	// Writing the plan must not push anything
+	verificationDir := t.TempDir()
+	runGit(t, verificationDir, "clone", "--branch", "main", remote, ".")
+	if _, err := os.Stat(filepath.Join(verificationDir, "planned.txt")); !os.IsNotExist(err) {
+		t.Fatal("plan generation should not push changes")
+	}
+
+	// Unplanned files added after review are not pushed
+	writeTestFile(t, sourceDir, "unplanned.txt", "late addition")
+
+	config.PlanOutPath = ""
+	config.ApplyPlanPath = planPath
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("runSyncer() with -apply-plan failed: %v", err)
+	}
+
+	verificationDir = t.TempDir()
+	runGit(t, verificationDir, "clone", "--branch", "main", remote, ".")
+	content, err := os.ReadFile(filepath.Join(verificationDir, "planned.txt"))
+	if err != nil || string(content) != "planned content" {
+		t.Fatalf("planned file not pushed: %q, %v", string(content), err)
+	}
+	if _, err := os.Stat(filepath.Join(verificationDir, "unplanned.txt")); !os.IsNotExist(err) {
+		t.Fatal("unplanned file should not be pushed")
+	}
+}
+
+func TestPushIntegrationApplyPlanAbortsOnDrift(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"seed.txt": "initial content",
+	})
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "planned.txt", "planned content")
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+
+	config := Config{
+		Mode:        ModePush,
+		FolderPath:  sourceDir,
+		RepoURL:     remote,
+		Branch:      "main",
+		PlanOutPath: planPath,
+	}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("runSyncer() with -plan-out failed: %v", err)
+	}
+
+	writeTestFile(t, sourceDir, "planned.txt", "edited after review")
+
+	config.PlanOutPath = ""
+	config.ApplyPlanPath = planPath
+	err := runSyncer(t, config)
+	if err == nil || !strings.Contains(err.Error(), "drifted") {
+		t.Fatalf("runSyncer() should abort on drift, got: %v", err)
+	}
+
+	verificationDir := t.TempDir()
+	runGit(t, verificationDir, "clone", "--branch", "main", remote, ".")
+	if _, err := os.Stat(filepath.Join(verificationDir, "planned.txt")); !os.IsNotExist(err) {
+		t.Fatal("drifted plan should not push changes")
+	}
+}
+
+func TestPushIntegrationTypedErrors(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	t.Run("clone failure", func(t *testing.T) {
+		config := Config{
+			Mode:       ModePush,
+			FolderPath: t.TempDir(),
+			RepoURL:    filepath.Join(t.TempDir(), "missing.git"),
+			Branch:     "main",
+		}
+		if err := runSyncer(t, config); !errors.Is(err, ErrCloneFailed) {
+			t.Fatalf("runSyncer() error = %v, want ErrCloneFailed", err)
+		}
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		remote := createRemoteRepoWithContent(t, map[string]string{"seed.txt": "same"})
+		sourceDir := t.TempDir()
+		writeTestFile(t, sourceDir, "seed.txt", "same")
+
+		config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main"}
+		if err := runSyncer(t, config); !errors.Is(err, ErrNoChanges) {
+			t.Fatalf("runSyncer() error = %v, want ErrNoChanges", err)
+		}
+	})
+
+	t.Run("push rejected", func(t *testing.T) {
+		remote := createRemoteRepoWithContent(t, map[string]string{"seed.txt": "initial"})
+		hook := filepath.Join(remote, "hooks", "pre-receive")
+		if err := os.WriteFile(hook, []byte("#!/bin/sh\necho 'pushes disabled' >&2\nexit 1\n"), 0755); err != nil {
+			t.Fatalf("failed to install pre-receive hook: %v", err)
+		}
+
+		sourceDir := t.TempDir()
+		writeTestFile(t, sourceDir, "new.txt", "content")
+
+		config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main"}
+		if err := runSyncer(t, config); !errors.Is(err, ErrPushRejected) {
+			t.Fatalf("runSyncer() error = %v, want ErrPushRejected", err)
+		}
+	})
+}
+
+func TestPushIntegrationCreatesTag(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"seed.txt": "initial content",
+	})
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "tagged.txt", "first")
+
+	config := Config{
+		Mode:       ModePush,
+		FolderPath: sourceDir,
+		RepoURL:    remote,
+		Branch:     "main",
+		Tag:        "snapshot",
+	}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("runSyncer() with -tag failed: %v", err)
+	}
+
+	tagged := strings.TrimSpace(gitOutput(t, remote, "rev-parse", "snapshot^{commit}"))
+	head := strings.TrimSpace(gitOutput(t, remote, "rev-parse", "main"))
+	if tagged != head {
+		t.Fatalf("tag points at %s, want %s", tagged, head)
+	}
+
+	// A second push with the same tag must fail clearly without -force-tag
+	writeTestFile(t, sourceDir, "tagged.txt", "second")
+	if err := runSyncer(t, config); !errors.Is(err, ErrTagExists) {
+		t.Fatalf("runSyncer() error = %v, want ErrTagExists", err)
+	}
+
+	config.ForceTag = true
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("runSyncer() with -force-tag failed: %v", err)
+	}
+
+	tagged = strings.TrimSpace(gitOutput(t, remote, "rev-parse", "snapshot^{commit}"))
+	head = strings.TrimSpace(gitOutput(t, remote, "rev-parse", "main"))
+	if tagged != head {
+		t.Fatalf("forced tag points at %s, want %s", tagged, head)
+	}
+}
+
+func TestPushIntegrationCommitPerFile(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"existing.txt": "initial content",
+	})
+	before := strings.TrimSpace(gitOutput(t, remote, "rev-parse", "main"))
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "existing.txt", "changed content")
+	writeTestFile(t, sourceDir, "new-1.txt", "one")
+	writeTestFile(t, sourceDir, "dir/new-2.txt", "two")
+
+	config := Config{
+		Mode:          ModePush,
+		FolderPath:    sourceDir,
+		RepoURL:       remote,
+		Branch:        "main",
+		CommitPerFile: true,
+	}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("runSyncer() with -commit-per-file failed: %v", err)
+	}
+
+	log := strings.Split(strings.TrimSpace(gitOutput(t, remote, "log", "--format=%s", before+"..main")), "\n")
+	want := []string{"Sync: modify existing.txt", "Sync: add new-1.txt", "Sync: add dir/new-2.txt"}
+	if len(log) != len(want) {
+		t.Fatalf("got %d commits %q, want %d", len(log), log, len(want))
+	}
+	for _, subject := range want {
+		if !slices.Contains(log, subject) {
+			t.Errorf("missing commit %q in %q", subject, log)
+		}
+	}
+}
+
+func TestPushIntegrationCommitPerFileQuotedPaths(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"old name.txt": "moved"})
+	before := strings.TrimSpace(gitOutput(t, remote, "rev-parse", "main"))
+
+	// git status quotes these paths unless it runs with -z
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "a b.txt", "spaced")
+	writeTestFile(t, sourceDir, "café.txt", "accented")
+	writeTestFile(t, sourceDir, "new name.txt", "moved")
+
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", CommitPerFile: true}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("runSyncer() with -commit-per-file failed: %v", err)
+	}
+
+	log := strings.Split(strings.TrimSpace(gitOutput(t, remote, "log", "--format=%s", before+"..main")), "\n")
+	want := []string{"Sync: add a b.txt", "Sync: add café.txt", "Sync: rename old name.txt -> new name.txt"}
+	for _, subject := range want {
+		if !slices.Contains(log, subject) {
+			t.Errorf("missing commit %q in %q", subject, log)
+		}
+	}
+	files := gitOutput(t, remote, "-c", "core.quotePath=false", "ls-tree", "-r", "--name-only", "main")
+	if files != "a b.txt\ncafé.txt\nnew name.txt\n" {
+		t.Errorf("unexpected files on main: %q", files)
+	}
+}
+
+func TestPushIntegrationGroupByExt(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"README.md": "initial content",
+	})
+	before := strings.TrimSpace(gitOutput(t, remote, "rev-parse", "main"))
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "README.md", "changed content")
+	writeTestFile(t, sourceDir, "docs/guide.md", "guide")
+	writeTestFile(t, sourceDir, "images/logo.png", "png")
+
+	config := Config{
+		Mode:       ModePush,
+		FolderPath: sourceDir,
+		RepoURL:    remote,
+		Branch:     "main",
+		GroupBy:    GroupByExt,
+	}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("runSyncer() with -group-by ext failed: %v", err)
+	}
+
+	log := strings.Split(strings.TrimSpace(gitOutput(t, remote, "log", "--reverse", "--format=%s", before+"..main")), "\n")
+	want := []string{"Sync .md files (2 changed)", "Sync .png files (1 changed)"}
+	if !slices.Equal(log, want) {
+		t.Errorf("got commits %q, want %q", log, want)
+	}
+	files := strings.Fields(gitOutput(t, remote, "show", "--name-only", "--format=", "main"))
+	if !slices.Equal(files, []string{"images/logo.png"}) {
+		t.Errorf("last commit touched %q, want only images/logo.png", files)
+	}
+}
+
+func TestPushIntegrationGroupByExtQuotedPaths(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"seed.txt": "seed"})
+	before := strings.TrimSpace(gitOutput(t, remote, "rev-parse", "main"))
+
+	// git status quotes these paths unless it runs with -z
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "seed.txt", "seed")
+	writeTestFile(t, sourceDir, "release notes.md", "notes")
+	writeTestFile(t, sourceDir, "résumé.md", "cv")
+
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", GroupBy: GroupByExt}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("runSyncer() with -group-by ext failed: %v", err)
+	}
+
+	log := strings.Split(strings.TrimSpace(gitOutput(t, remote, "log", "--format=%s", before+"..main")), "\n")
+	if want := []string{"Sync .md files (2 changed)"}; !slices.Equal(log, want) {
+		t.Errorf("got commits %q, want %q", log, want)
+	}
+	files := gitOutput(t, remote, "-c", "core.quotePath=false", "ls-tree", "-r", "--name-only", "main")
+	if files != "release notes.md\nrésumé.md\nseed.txt\n" {
+		t.Errorf("unexpected files on main: %q", files)
+	}
+}
+
+func TestPushIntegrationSquashAfter(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"file.txt": "initial content",
+	})
+	before := strings.TrimSpace(gitOutput(t, remote, "rev-parse", "main"))
+
+	sourceDir := t.TempDir()
+	config := Config{
+		Mode:        ModePush,
+		FolderPath:  sourceDir,
+		RepoURL:     remote,
+		Branch:      "main",
+		SquashAfter: 3,
+	}
+	for i := 1; i <= 3; i++ {
+		writeTestFile(t, sourceDir, "file.txt", "version "+strconv.Itoa(i))
+		if err := runSyncer(t, config); err != nil {
+			t.Fatalf("push %d failed: %v", i, err)
+		}
+	}
+
+	log := strings.Split(strings.TrimSpace(gitOutput(t, remote, "log", "--format=%s", before+"..main")), "\n")
+	if len(log) != 1 || log[0] != "Sync: squash 3 sync commits" {
+		t.Fatalf("got commits %q, want a single squash commit", log)
+	}
+	if parent := strings.TrimSpace(gitOutput(t, remote, "rev-parse", "main~1")); parent != before {
+		t.Errorf("squash commit parent = %s, want %s", parent, before)
+	}
+	if content := gitOutput(t, remote, "show", "main:file.txt"); content != "version 3" {
+		t.Errorf("file.txt = %q, want %q", content, "version 3")
+	}
+
+	// Counting starts again after the squash
+	writeTestFile(t, sourceDir, "file.txt", "version 4")
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("push after squash failed: %v", err)
+	}
+	if count := strings.TrimSpace(gitOutput(t, remote, "rev-list", "--count", before+"..main")); count != "2" {
+		t.Errorf("got %s commits after the next push, want 2", count)
+	}
+}
+
+func TestPushIntegrationBranchTemplate(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"file.txt": "initial content"})
+	base := gitOutput(t, remote, "rev-parse", "main")
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "file.txt", "for review")
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", RunID: "3f9a2c1d", BranchTemplate: "sync/{{.Base}}-{{.RunID}}"}
+	s, err := New(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	result, err := s.Push(context.Background())
+	if err != nil {
+		t.Fatalf("push with -branch-template failed: %v", err)
+	}
+
+	if result.Branch != "sync/main-3f9a2c1d" || !result.Pushed {
+		t.Errorf("result branch = %q, pushed = %v, want sync/main-3f9a2c1d pushed", result.Branch, result.Pushed)
+	}
+	if content := gitOutput(t, remote, "show", "sync/main-3f9a2c1d:file.txt"); content != "for review" {
+		t.Errorf("file.txt on the new branch = %q, want %q", content, "for review")
+	}
+	if parent := gitOutput(t, remote, "rev-parse", "sync/main-3f9a2c1d~1"); parent != base {
+		t.Errorf("new branch starts at %s, want the base branch %s", parent, base)
+	}
+	if after := gitOutput(t, remote, "rev-parse", "main"); after != base {
+		t.Error("the base branch changed")
+	}
+
+	// The same name again is refused
+	writeTestFile(t, sourceDir, "file.txt", "second review")
+	if err := runSyncer(t, config); !errors.Is(err, ErrPushRejected) {
+		t.Errorf("push to an existing template branch error = %v, want ErrPushRejected", err)
+	}
+}
+
+func TestPushIntegrationSignoff(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"file.txt": "initial content"})
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "file.txt", "signed off")
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", Signoff: true}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("push with -signoff failed: %v", err)
+	}
+
+	trailer := strings.TrimSpace(gitOutput(t, remote, "log", "-1", "--format=%(trailers:key=Signed-off-by,valueonly)", "main"))
+	if trailer != "file-syncer <file-syncer@example.com>" {
+		t.Errorf("Signed-off-by = %q, want the committer identity", trailer)
+	}
+}
+
+func TestPushIntegrationMessagePrefix(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"file.txt": "initial content"})
+	sourceDir := t.TempDir()
+
+	// Generated subject
+	writeTestFile(t, sourceDir, "file.txt", "first")
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", MessagePrefix: "[skip ci]"}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("push with -message-prefix failed: %v", err)
+	}
+	if subject := strings.TrimSpace(gitOutput(t, remote, "log", "-1", "--format=%s", "main")); subject != "[skip ci] Sync 1 file (1 modified)" {
+		t.Errorf("subject = %q, want the prefixed generated subject", subject)
+	}
+
+	// Custom message from a file
+	messagePath := filepath.Join(t.TempDir(), "message.txt")
+	if err := os.WriteFile(messagePath, []byte("Release sync\n\nGenerated by CI.\n"), 0644); err != nil {
+		t.Fatalf("failed to write message file: %v", err)
+	}
+	writeTestFile(t, sourceDir, "file.txt", "second")
+	config.CommitMessageFile = messagePath
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("push with -message-prefix and -commit-message-file failed: %v", err)
+	}
+	if message := strings.TrimSpace(gitOutput(t, remote, "log", "-1", "--format=%B", "main")); message != "[skip ci] Release sync\n\nGenerated by CI." {
+		t.Errorf("message = %q, want the prefixed custom message", message)
+	}
+}
+
+func TestPushIntegrationSignoffWithoutIdentity(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"file.txt": "initial content"})
+	before := gitOutput(t, remote, "rev-parse", "main")
+
+	// Hide every source of a committer identity
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_COMMITTER_NAME", "")
+	t.Setenv("GIT_COMMITTER_EMAIL", "")
+	t.Setenv("EMAIL", "")
+	os.Unsetenv("GIT_COMMITTER_NAME")
+	os.Unsetenv("GIT_COMMITTER_EMAIL")
+	os.Unsetenv("EMAIL")
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "file.txt", "signed off")
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", Signoff: true}
+	err := runSyncer(t, config)
+	if err == nil || !strings.Contains(err.Error(), "-signoff requires a committer identity") {
+		t.Fatalf("push without an identity error = %v, want the missing identity reported", err)
+	}
+	if after := gitOutput(t, remote, "rev-parse", "main"); after != before {
+		t.Error("push without an identity changed the remote")
+	}
+}
+
+func TestPushIntegrationForceKeepsConcurrentPush(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+	if runtime.GOOS == "windows" {
+		t.Skip("the git wrapper is a shell script")
+	}
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"notes.txt": "base"})
+	otherDir := t.TempDir()
+	runGit(t, otherDir, "clone", remote, ".")
+	writeTestFile(t, otherDir, "other.txt", "concurrent")
+	runGit(t, otherDir, "add", "-A")
+	runGit(t, otherDir, "commit", "-m", "concurrent change")
+
+	// The wrapper lets a third party push between the clone and the push
+	realGit, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	binDir := t.TempDir()
+	marker := filepath.Join(binDir, "pushed")
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = push ] && [ ! -e " + marker + " ]; then\n" +
+		"  touch " + marker + "\n" +
+		"  (cd " + otherDir + " && " + realGit + " push -q origin main) || exit 1\n" +
+		"fi\n" +
+		"exec " + realGit + " \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(binDir, "git"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "notes.txt", "ours")
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", Force: true}
+	if err := runSyncer(t, config); !errors.Is(err, ErrPushRejected) {
+		t.Fatalf("push after a concurrent push: error = %v, want ErrPushRejected", err)
+	}
+	if content := gitOutput(t, remote, "show", "main:other.txt"); content != "concurrent" {
+		t.Errorf("the concurrent commit was overwritten, other.txt = %q", content)
+	}
+}
+
+func TestPushIntegrationAmend(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"file.txt": "initial content"})
+	seed := strings.TrimSpace(gitOutput(t, remote, "rev-parse", "main"))
+	t.Setenv("GIT_AUTHOR_EMAIL", "sync-bot@example.com")
+
+	sourceDir := t.TempDir()
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", Amend: true}
+	push := func(content string) {
+		t.Helper()
+		writeTestFile(t, sourceDir, "file.txt", content)
+		if err := runSyncer(t, config); err != nil {
+			t.Fatalf("push of %q failed: %v", content, err)
+		}
+	}
+	commits := func(since string) string {
+		t.Helper()
+		return strings.TrimSpace(gitOutput(t, remote, "rev-list", "--count", since+"..main"))
+	}
+
+	// The seed commit has another author, so the first sync adds a commit
+	push("version 1")
+	if got := commits(seed); got != "1" {
+		t.Fatalf("got %s commits after the first push, want 1", got)
+	}
+
+	// Later syncs replace it
+	push("version 2")
+	push("version 3")
+	if got := commits(seed); got != "1" {
+		t.Errorf("got %s commits after amending pushes, want 1", got)
+	}
+	if content := gitOutput(t, remote, "show", "main:file.txt"); content != "version 3" {
+		t.Errorf("file.txt = %q, want %q", content, "version 3")
+	}
+	if author := strings.TrimSpace(gitOutput(t, remote, "log", "-1", "--format=%ae", "main")); author != "sync-bot@example.com" {
+		t.Errorf("tip author = %q, want the sync author", author)
+	}
+
+	// A commit by someone else is kept
+	t.Setenv("GIT_AUTHOR_EMAIL", "jane@example.com")
+	push("version 4")
+	human := strings.TrimSpace(gitOutput(t, remote, "rev-parse", "main"))
+	t.Setenv("GIT_AUTHOR_EMAIL", "sync-bot@example.com")
+	push("version 5")
+	if got := commits(human); got != "1" {
+		t.Errorf("got %s commits on top of another author's commit, want 1", got)
+	}
+}
+
+func TestPushIntegrationCommitPaths(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"config/app.yaml": "debug: false",
+		"logs/run.log":    "first run",
+	})
+	before := strings.TrimSpace(gitOutput(t, remote, "rev-parse", "main"))
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "config/app.yaml", "debug: false")
+	writeTestFile(t, sourceDir, "logs/run.log", "second run")
+
+	config := Config{
+		Mode:        ModePush,
+		FolderPath:  sourceDir,
+		RepoURL:     remote,
+		Branch:      "main",
+		CommitPaths: []string{"config/"},
+	}
+	if err := runSyncer(t, config); !errors.Is(err, ErrNoChanges) {
+		t.Fatalf("push with a change outside -commit-paths returned %v, want ErrNoChanges", err)
+	}
+	if head := strings.TrimSpace(gitOutput(t, remote, "rev-parse", "main")); head != before {
+		t.Fatalf("a change outside -commit-paths was pushed")
+	}
+
+	writeTestFile(t, sourceDir, "config/app.yaml", "debug: true")
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("push with a change inside -commit-paths failed: %v", err)
+	}
+	files := strings.Fields(gitOutput(t, remote, "diff", "--name-only", before, "main"))
+	if !slices.Equal(files, []string{"config/app.yaml", "logs/run.log"}) {
+		t.Errorf("pushed changes to %q, want config/app.yaml and logs/run.log", files)
+	}
+}
+
+func TestPushIntegrationMultipleBranches(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"app.conf": "version 1",
+	})
+	runGit(t, remote, "branch", "stable", "main")
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "app.conf", "version 2")
+	writeTestFile(t, sourceDir, "notes.txt", "release notes")
+
+	s, err := New(Config{
+		Mode:       ModePush,
+		FolderPath: sourceDir,
+		RepoURL:    remote,
+		Branch:     "main",
+		Branches:   []string{"stable"},
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	result, err := s.Push(context.Background())
+	if err != nil {
+		t.Fatalf("Push() to several branches failed: %v", err)
+	}
+
+	if len(result.Branches) != 2 {
+		t.Fatalf("got %d branch results, want 2", len(result.Branches))
+	}
+	for i, branch := range []string{"main", "stable"} {
+		br := result.Branches[i]
+		if br.Branch != branch || !br.Pushed {
+			t.Errorf("result %d = branch %q pushed %v, want %q pushed", i, br.Branch, br.Pushed, branch)
+		}
+		if head := strings.TrimSpace(gitOutput(t, remote, "rev-parse", branch)); head != br.Commit {
+			t.Errorf("%s is at %s, want the pushed commit %s", branch, head, br.Commit)
+		}
+		for path, want := range map[string]string{"app.conf": "version 2", "notes.txt": "release notes"} {
+			if got := gitOutput(t, remote, "show", branch+":"+path); got != want {
+				t.Errorf("%s:%s = %q, want %q", branch, path, got, want)
+			}
+		}
+	}
+}
+
+func TestPushIntegrationSnapshot(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"data.txt": "old",
+	})
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "data.txt", "at snapshot time")
+
+	s, err := New(Config{
+		Mode:       ModePush,
+		FolderPath: sourceDir,
+		RepoURL:    remote,
+		Branch:     "main",
+		Snapshot:   true,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	// Simulate a writer changing the folder while the push runs
+	s.snapshotTaken = func() {
+		writeTestFile(t, sourceDir, "data.txt", "written during the push")
+		writeTestFile(t, sourceDir, "late.txt", "created during the push")
+	}
+	if _, err := s.Push(context.Background()); err != nil {
+		t.Fatalf("Push() with -snapshot failed: %v", err)
+	}
+
+	if got := gitOutput(t, remote, "show", "main:data.txt"); got != "at snapshot time" {
+		t.Errorf("data.txt = %q, want the snapshot-time content", got)
+	}
+	if files := gitOutput(t, remote, "ls-tree", "--name-only", "main"); strings.Contains(files, "late.txt") {
+		t.Error("a file created after the snapshot was pushed")
+	}
+}
+
+// createMasterRemote returns a bare repository whose default branch is
+// master rather than main.
+func createMasterRemote(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	remote := createRemoteRepoWithContent(t, files)
+	runGit(t, remote, "branch", "-m", "main", "master")
+	runGit(t, remote, "symbolic-ref", "HEAD", "refs/heads/master")
+	return remote
+}
+
+func TestRemoteDefaultBranchIntegration(t *testing.T) {
+	requireGit(t)
+
+	remote := createMasterRemote(t, map[string]string{"a.txt": "a"})
+	s, err := New(Config{Mode: ModePull, FolderPath: t.TempDir(), RepoURL: remote, Branch: BranchAuto}, nil)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	branch, err := s.remoteDefaultBranch(context.Background())
+	if err != nil {
+		t.Fatalf("remoteDefaultBranch() failed: %v", err)
+	}
+	if branch != "master" {
+		t.Errorf("remoteDefaultBranch() = %q, want master", branch)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.git")
+	runGit(t, t.TempDir(), "init", "--bare", empty)
+	s.config.RepoURL = empty
+	if _, err := s.remoteDefaultBranch(context.Background()); !errors.Is(err, ErrCloneFailed) {
+		t.Errorf("remoteDefaultBranch() on an empty remote error = %v, want ErrCloneFailed", err)
+	}
+}
+
+func TestPushAndPullIntegrationBranchAuto(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createMasterRemote(t, map[string]string{"a.txt": "old"})
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "a.txt", "new")
+	s, err := New(Config{
+		Mode:       ModePush,
+		FolderPath: sourceDir,
+		RepoURL:    remote,
+		Branch:     BranchAuto,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	result, err := s.Push(context.Background())
+	if err != nil {
+		t.Fatalf("Push() with -branch auto failed: %v", err)
+	}
+	if result.Branch != "master" {
+		t.Errorf("result.Branch = %q, want master", result.Branch)
+	}
+	if got := gitOutput(t, remote, "show", "master:a.txt"); got != "new" {
+		t.Errorf("master:a.txt = %q, want new", got)
+	}
+	if s.config.Branch != BranchAuto {
+		t.Errorf("config.Branch = %q after the run, want it restored to %q", s.config.Branch, BranchAuto)
+	}
+
+	pullDir := t.TempDir()
+	if err := runSyncer(t, Config{
+		Mode:       ModePull,
+		FolderPath: pullDir,
+		RepoURL:    remote,
+		Branch:     BranchAuto,
+	}); err != nil {
+		t.Fatalf("Pull() with -branch auto failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(pullDir, "a.txt"), "new")
+}
+
+func TestPullIntegrationSparse(t *testing.T) {
+	requireGit(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"README.md":            "root",
+		"services/api/main.go": "package main",
+		"services/web/app.js":  "app",
+		"docs/guide.md":        "guide",
+	})
+	// Serve partial clones, as hosting services do
+	runGit(t, remote, "config", "uploadpack.allowFilter", "true")
+	destDir := t.TempDir()
+	writeTestFile(t, destDir, "local.txt", "kept")
+
+	err := runSyncer(t, Config{
+		Mode:       ModePull,
+		FolderPath: destDir,
+		RepoURL:    "file://" + filepath.ToSlash(remote),
+		Branch:     "main",
+		Sparse:     []string{"services/api/"},
+		Depth:      1,
+		Clean:      true,
+	})
+	if err != nil {
+		t.Fatalf("sparse pull failed: %v", err)
+	}
+
+	var files []string
+	err = filepath.Walk(destDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(destDir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to list destination: %v", err)
+	}
+	slices.Sort(files)
+	if want := []string{"local.txt", "services/api/main.go"}; !slices.Equal(files, want) {
+		t.Errorf("destination files = %v, want %v", files, want)
+	}
+}
+
+func TestCheckIntegration(t *testing.T) {
+	requireGit(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"a.txt": "a"})
+	folder := filepath.Join(t.TempDir(), "not-created")
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr error
+	}{
+		{
+			name:   "reachable branch",
+			config: Config{Mode: ModePull, FolderPath: folder, RepoURL: remote, Branch: "main", ValidateOnly: true},
+		},
+		{
+			name:   "push to a new branch",
+			config: Config{Mode: ModePush, FolderPath: folder, RepoURL: remote, Branch: "feature", ValidateOnly: true},
+		},
+		{
+			name:    "pull from a missing branch",
+			config:  Config{Mode: ModePull, FolderPath: folder, RepoURL: remote, Branch: "feature", ValidateOnly: true},
+			wantErr: ErrCloneFailed,
+		},
+		{
+			name:    "bogus repository",
+			config:  Config{Mode: ModePull, FolderPath: folder, RepoURL: filepath.Join(t.TempDir(), "missing.git"), Branch: "main", ValidateOnly: true},
+			wantErr: ErrCloneFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(tt.config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			err = s.Check(context.Background())
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Check() error = %v, want nil", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Check() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := os.Stat(folder); !os.IsNotExist(err) {
+				t.Errorf("Check() touched the folder: %v", err)
+			}
+		})
+	}
+}
+
+func TestPullLoopIntegrationRunsCycles(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"a.txt": "v1"})
+	destDir := t.TempDir()
+	s, err := New(Config{
+		Mode:       ModePull,
+		FolderPath: destDir,
+		RepoURL:    remote,
+		Branch:     "main",
+		Interval:   10 * time.Millisecond,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var cycles []error
+	s.cycleDone = func(cycle int, err error) {
+		cycles = append(cycles, err)
+		switch cycle {
+		case 1:
+			assertFileContent(t, filepath.Join(destDir, "a.txt"), "v1")
+			// Change the remote so that the second cycle has something to pull
+			work := t.TempDir()
+			runGit(t, work, "clone", "-b", "main", remote, ".")
+			writeTestFile(t, work, "a.txt", "v2")
+			runGit(t, work, "commit", "-am", "update")
+			runGit(t, work, "push", "origin", "main")
+		case 2:
+			cancel()
+		}
+	}
+
+	done := make(chan error)
+	go func() { done <- s.PullLoop(ctx) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("PullLoop() error = %v, want nil after cancellation", err)
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("PullLoop() did not stop after cancellation")
+	}
+
+	if len(cycles) != 2 {
+		t.Fatalf("ran %d cycles, want 2", len(cycles))
+	}
+	for i, err := range cycles {
+		if err != nil {
+			t.Errorf("cycle %d failed: %v", i+1, err)
+		}
+	}
+	assertFileContent(t, filepath.Join(destDir, "a.txt"), "v2")
+}
+
+func TestPullIntegrationCaseCollision(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+	if fold, err := caseInsensitiveDir(t.TempDir()); err != nil || fold {
+		t.Skip("the colliding files cannot be created on a case-insensitive filesystem")
+	}
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"File.txt": "upper", "file.txt": "lower"})
+	pull := func(config Config, fold bool) error {
+		t.Helper()
+		s, err := New(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		s.caseInsensitive = func(string) (bool, error) { return fold, nil }
+		return s.Pull(context.Background())
+	}
+
+	// A case-sensitive folder takes both files
+	destDir := t.TempDir()
+	config := Config{Mode: ModePull, FolderPath: destDir, RepoURL: remote, Branch: "main"}
+	if err := pull(config, false); err != nil {
+		t.Fatalf("pull to a case-sensitive folder failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(destDir, "File.txt"), "upper")
+	assertFileContent(t, filepath.Join(destDir, "file.txt"), "lower")
+
+	// A case-insensitive folder is left alone
+	destDir = t.TempDir()
+	config.FolderPath = destDir
+	err := pull(config, true)
+	if !errors.Is(err, ErrCaseCollision) {
+		t.Fatalf("pull error = %v, want ErrCaseCollision", err)
+	}
+	if !strings.Contains(err.Error(), "File.txt and file.txt") {
+		t.Errorf("error %q should name the colliding files", err)
+	}
+	if entries, _ := os.ReadDir(destDir); len(entries) != 0 {
+		t.Errorf("folder holds %d entries, want nothing synced", len(entries))
+	}
+
+	// Excluding one of the files resolves the collision
+	config.Exclude = []string{"File.txt"}
+	if err := pull(config, true); err != nil {
+		t.Fatalf("pull with the collision excluded failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(destDir, "file.txt"), "lower")
+}
+
+func TestPullIntegrationReusesWorkDir(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"file.txt": "first version",
+	})
+
+	workDir := filepath.Join(t.TempDir(), "checkout")
+	destinationDir := t.TempDir()
+	config := Config{
+		Mode:       ModePull,
+		FolderPath: destinationDir,
+		RepoURL:    remote,
+		Branch:     "main",
+		WorkDir:    workDir,
+	}
+
+	// First pull clones into the work directory
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("first pull failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, ".git")); err != nil {
+		t.Fatalf("work directory should hold a checkout after the first pull: %v", err)
+	}
+	assertFileContent(t, filepath.Join(destinationDir, "file.txt"), "first version")
+
+	// Leave a marker in the checkout's git directory to prove it is reused
+	marker := filepath.Join(workDir, ".git", "file-syncer-marker")
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatalf("failed to write marker: %v", err)
+	}
+
+	// Update the remote and pull again
+	updateDir := t.TempDir()
+	runGit(t, updateDir, "clone", "--branch", "main", remote, ".")
+	writeTestFile(t, updateDir, "file.txt", "second version")
+	writeTestFile(t, updateDir, "added.txt", "added later")
+	runGit(t, updateDir, "add", "-A")
+	runGit(t, updateDir, "commit", "-m", "update")
+	runGit(t, updateDir, "push", "origin", "main")
+
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("second pull failed: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("work directory should be reused, not re-cloned: %v", err)
+	}
+	assertFileContent(t, filepath.Join(destinationDir, "file.txt"), "second version")
+	assertFileContent(t, filepath.Join(destinationDir, "added.txt"), "added later")
+}
+
+func TestPushIntegrationNoCommit(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"seed.txt": "seed", "old.txt": "old"})
+	before := gitOutput(t, remote, "rev-parse", "main")
+	workDir := filepath.Join(t.TempDir(), "checkout")
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "seed.txt", "changed")
+	writeTestFile(t, sourceDir, "new.txt", "new")
+
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", WorkDir: workDir, NoCommit: true}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("push with -no-commit failed: %v", err)
+	}
+
+	if head := gitOutput(t, workDir, "rev-parse", "HEAD"); head != before {
+		t.Errorf("work directory HEAD = %s, want it unchanged at %s", head, before)
+	}
+	if after := gitOutput(t, remote, "rev-parse", "main"); after != before {
+		t.Error("push with -no-commit changed the remote")
+	}
+	staged := gitOutput(t, workDir, "diff", "--cached", "--name-status")
+	if staged != "A\tnew.txt\nD\told.txt\nM\tseed.txt\n" {
+		t.Errorf("staged changes = %q, want new.txt added, old.txt deleted and seed.txt modified", staged)
+	}
+	if unstaged := gitOutput(t, workDir, "status", "--porcelain", "--untracked-files=all"); unstaged != "A  new.txt\nD  old.txt\nM  seed.txt\n" {
+		t.Errorf("work tree status = %q, want every change staged", unstaged)
+	}
+}
+
+func TestPushIntegrationReadOnlySourceFile(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	// A read-only source file is read-only in the clone too, and the next
+	// write into that clone must still replace it
+	writeReadOnly := func(t *testing.T, dir, content string) {
+		path := filepath.Join(dir, "ro.txt")
+		os.Chmod(path, 0644)
+		writeTestFile(t, dir, "ro.txt", content)
+		if err := os.Chmod(path, 0444); err != nil {
+			t.Fatalf("failed to make ro.txt read-only: %v", err)
+		}
+	}
+
+	t.Run("several branches", func(t *testing.T) {
+		remote := createRemoteRepoWithContent(t, map[string]string{"seed.txt": "seed"})
+		runGit(t, remote, "branch", "stable", "main")
+		sourceDir := t.TempDir()
+		writeTestFile(t, sourceDir, "seed.txt", "seed")
+		writeReadOnly(t, sourceDir, "locked")
+
+		config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", Branches: []string{"stable"}}
+		if err := runSyncer(t, config); err != nil {
+			t.Fatalf("push failed: %v", err)
+		}
+		for _, branch := range []string{"main", "stable"} {
+			if got := gitOutput(t, remote, "show", branch+":ro.txt"); got != "locked" {
+				t.Errorf("%s:ro.txt = %q, want %q", branch, got, "locked")
+			}
+		}
+	})
+
+	t.Run("work dir", func(t *testing.T) {
+		remote := createRemoteRepoWithContent(t, map[string]string{"seed.txt": "seed"})
+		sourceDir := t.TempDir()
+		writeTestFile(t, sourceDir, "seed.txt", "seed")
+		config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", WorkDir: filepath.Join(t.TempDir(), "checkout")}
+
+		for _, content := range []string{"first", "second"} {
+			writeReadOnly(t, sourceDir, content)
+			if err := runSyncer(t, config); err != nil {
+				t.Fatalf("push of %q failed: %v", content, err)
+			}
+			if got := gitOutput(t, remote, "show", "main:ro.txt"); got != content {
+				t.Errorf("ro.txt = %q, want %q", got, content)
+			}
+		}
+	})
+}
+
+func TestPushIntegrationReusesWorkDir(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"seed.txt": "seed"})
+	workDir := filepath.Join(t.TempDir(), "checkout")
+	sourceDir := t.TempDir()
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "feature", WorkDir: workDir}
+
+	// The first push clones into the work directory and creates the branch
+	writeTestFile(t, sourceDir, "seed.txt", "seed")
+	writeTestFile(t, sourceDir, "notes.txt", "first")
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("first push failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, ".git")); err != nil {
+		t.Fatalf("work directory should survive the push: %v", err)
+	}
+	if content := gitOutput(t, remote, "show", "feature:notes.txt"); content != "first" {
+		t.Errorf("notes.txt on feature = %q, want %q", content, "first")
+	}
+
+	marker := filepath.Join(workDir, ".git", "file-syncer-marker")
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatalf("failed to write marker: %v", err)
+	}
+
+	// Someone else pushes to the branch, and a stray file is left in the checkout
+	updateDir := t.TempDir()
+	runGit(t, updateDir, "clone", "--branch", "feature", remote, ".")
+	writeTestFile(t, updateDir, "other.txt", "from elsewhere")
+	runGit(t, updateDir, "add", "-A")
+	runGit(t, updateDir, "commit", "-m", "other change")
+	runGit(t, updateDir, "push", "origin", "feature")
+	writeTestFile(t, workDir, "stray.txt", "stray")
+
+	writeTestFile(t, sourceDir, "other.txt", "from elsewhere")
+	writeTestFile(t, sourceDir, "notes.txt", "second")
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("second push failed: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("work directory should be reused, not re-cloned: %v", err)
+	}
+	files := gitOutput(t, remote, "ls-tree", "-r", "--name-only", "feature")
+	if files != "notes.txt\nother.txt\nseed.txt\n" {
+		t.Errorf("unexpected files on feature: %q", files)
+	}
+	if content := gitOutput(t, remote, "show", "feature:notes.txt"); content != "second" {
+		t.Errorf("notes.txt on feature = %q, want %q", content, "second")
+	}
+}
+
+func TestIntegrationNonGitHubRemoteURLs(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	urls := map[string]string{
+		"GitLab scp-style SSH": "git@gitlab.example.com:group/subgroup/repo.git",
+		"GitLab SSH URL":       "ssh://git@gitlab.example.com:2222/group/repo.git",
+		"Gitea HTTPS":          "https://gitea.local/owner/repo.git",
+	}
+
+	for name, repoURL := range urls {
+		t.Run(name, func(t *testing.T) {
+			remote := createRemoteRepoWithContent(t, map[string]string{
+				"README.md": "seed",
+			})
+			aliasRemoteURL(t, repoURL, remote)
+
+			sourceDir := t.TempDir()
+			writeTestFile(t, sourceDir, "README.md", "seed")
+			writeTestFile(t, sourceDir, "notes.txt", "pushed through "+repoURL)
+
+			push := Config{
+				Mode:       ModePush,
+				FolderPath: sourceDir,
+				RepoURL:    repoURL,
+				Branch:     "main",
+			}
+			if err := runSyncer(t, push); err != nil {
+				t.Fatalf("push to %s failed: %v", repoURL, err)
+			}
+
+			destinationDir := t.TempDir()
+			pull := Config{
+				Mode:       ModePull,
+				FolderPath: destinationDir,
+				RepoURL:    repoURL,
+				Branch:     "main",
+			}
+			if err := runSyncer(t, pull); err != nil {
+				t.Fatalf("pull from %s failed: %v", repoURL, err)
+			}
+			assertFileContent(t, filepath.Join(destinationDir, "notes.txt"), "pushed through "+repoURL)
+		})
+	}
+}
+
+func TestIntegrationIgnoreModeChanges(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	// Reproduce a mode-only diff: the content is unchanged, only +x flips
+	repoDir := t.TempDir()
+	runGit(t, repoDir, "init")
+	writeTestFile(t, repoDir, "script.sh", "echo hi")
+	runGit(t, repoDir, "add", "-A")
+	runGit(t, repoDir, "commit", "-m", "seed")
+	if err := os.Chmod(filepath.Join(repoDir, "script.sh"), 0755); err != nil {
+		t.Fatalf("failed to chmod script: %v", err)
+	}
+	if status := gitOutput(t, repoDir, "status", "--porcelain"); strings.TrimSpace(status) == "" {
+		t.Fatal("expected git to report the mode change")
+	}
+
+	s := newTestSyncer(t, Config{Mode: ModePush, FolderPath: repoDir, RepoURL: repoDir, Branch: "main", NoModeChanges: true})
+	if status := gitOutput(t, repoDir, s.indexArgs("status", "--porcelain")...); strings.TrimSpace(status) != "" {
+		t.Errorf("mode-only change should be ignored, got status %q", status)
+	}
+	// The repository's own configuration is left alone
+	if status := gitOutput(t, repoDir, "status", "--porcelain"); strings.TrimSpace(status) == "" {
+		t.Error("the mode change is ignored outside the syncer's commands too")
+	}
+}
+
+func TestPushIntegrationNoModeChanges(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"seed.txt": "seed"})
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "seed.txt", "seed")
+	writeTestFile(t, sourceDir, "run.sh", "echo run")
+	if err := os.Chmod(filepath.Join(sourceDir, "run.sh"), 0755); err != nil {
+		t.Fatalf("failed to chmod script: %v", err)
+	}
+
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", NoModeChanges: true}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+
+	// New files are normalized to non-executable
+	tree := gitOutput(t, remote, "ls-tree", "main", "run.sh")
+	if !strings.HasPrefix(tree, "100644 ") {
+		t.Errorf("run.sh should be committed as 100644, got %q", tree)
+	}
+
+	// With the mode ignored, a second push finds nothing to do
+	if err := runSyncer(t, config); !errors.Is(err, ErrNoChanges) {
+		t.Fatalf("second push error = %v, want ErrNoChanges", err)
+	}
+
+	// A reused work dir keeps its configuration for runs without the flag
+	config.WorkDir = filepath.Join(t.TempDir(), "checkout")
+	if err := runSyncer(t, config); !errors.Is(err, ErrNoChanges) {
+		t.Fatalf("push into a work dir error = %v, want ErrNoChanges", err)
+	}
+	cmd := exec.Command("git", "config", "--local", "core.fileMode")
+	cmd.Dir = config.WorkDir
+	if out, _ := cmd.Output(); strings.TrimSpace(string(out)) == "false" {
+		t.Error("-no-mode-changes set core.fileMode=false in the work dir")
+	}
+}
+
+func TestPushIntegrationEmptyRemote(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := filepath.Join(t.TempDir(), "empty.git")
+	runGit(t, filepath.Dir(remote), "init", "--bare", remote)
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "README.md", "first content")
+	writeTestFile(t, sourceDir, "docs/guide.md", "guide")
+
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "trunk"}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("first push to an empty remote failed: %v", err)
+	}
+
+	files := gitOutput(t, remote, "ls-tree", "-r", "--name-only", "trunk")
+	if files != "README.md\ndocs/guide.md\n" {
+		t.Errorf("unexpected files on trunk: %q", files)
+	}
+	if count := strings.TrimSpace(gitOutput(t, remote, "rev-list", "--count", "trunk")); count != "1" {
+		t.Errorf("trunk should have exactly one commit, got %s", count)
+	}
+}
+
+func TestIntegrationHooks(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	t.Run("post-hook after pull", func(t *testing.T) {
+		remote := createRemoteRepoWithContent(t, map[string]string{"app.conf": "setting=1"})
+		destinationDir := t.TempDir()
+
+		config := Config{
+			Mode:       ModePull,
+			FolderPath: destinationDir,
+			RepoURL:    remote,
+			Branch:     "main",
+			PostHook:   `echo "$FILE_SYNCER_COMMIT" > commit.txt`,
+		}
+		if err := runSyncer(t, config); err != nil {
+			t.Fatalf("pull failed: %v", err)
+		}
+
+		want := gitOutput(t, remote, "rev-parse", "main")
+		assertFileContent(t, filepath.Join(destinationDir, "commit.txt"), want)
+	})
+
+	t.Run("failing pre-hook aborts push", func(t *testing.T) {
+		remote := createRemoteRepoWithContent(t, map[string]string{"seed.txt": "seed"})
+		before := gitOutput(t, remote, "rev-parse", "main")
+
+		sourceDir := t.TempDir()
+		writeTestFile(t, sourceDir, "new.txt", "content")
+
+		config := Config{
+			Mode:       ModePush,
+			FolderPath: sourceDir,
+			RepoURL:    remote,
+			Branch:     "main",
+			PreHook:    "exit 1",
+		}
+		if err := runSyncer(t, config); !errors.Is(err, ErrHookFailed) {
+			t.Fatalf("push error = %v, want ErrHookFailed", err)
+		}
+		if after := gitOutput(t, remote, "rev-parse", "main"); after != before {
+			t.Error("remote should be unchanged after a failing pre-hook")
+		}
+	})
+}
+
+func TestPushIntegrationCommitEmpty(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"seed.txt": "same"})
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "seed.txt", "same")
+
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", CommitEmpty: true}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+
+	if count := strings.TrimSpace(gitOutput(t, remote, "rev-list", "--count", "main")); count != "2" {
+		t.Fatalf("expected the heartbeat commit on top of the seed commit, got %s commits", count)
+	}
+	if subject := strings.TrimSpace(gitOutput(t, remote, "log", "-1", "--format=%s", "main")); subject != heartbeatSubject {
+		t.Errorf("commit subject = %q, want %q", subject, heartbeatSubject)
+	}
+	if files := gitOutput(t, remote, "diff-tree", "--no-commit-id", "--name-only", "-r", "main"); files != "" {
+		t.Errorf("heartbeat commit should not change files, got %q", files)
+	}
+}
+
+func TestPushIntegrationRespectRepoGitignore(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{".gitignore": "*.tmp\n"})
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, ".gitignore", "*.tmp\n")
+	writeTestFile(t, sourceDir, "report.txt", "report")
+	writeTestFile(t, sourceDir, "work/scratch.tmp", "scratch")
+
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", RespectRepoGitignore: true}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+
+	files := gitOutput(t, remote, "ls-tree", "-r", "--name-only", "main")
+	if files != ".gitignore\nreport.txt\n" {
+		t.Errorf("unexpected files on main: %q", files)
+	}
+}
+
+func TestPushIntegrationOnlyTracked(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		".gitignore": "*.log\n",
+		"data.txt":   "old",
+	})
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, ".gitignore", "*.log\n")
+	writeTestFile(t, sourceDir, "data.txt", "new")
+	writeTestFile(t, sourceDir, "new.txt", "untracked")
+	writeTestFile(t, sourceDir, "debug.log", "ignored")
+
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", OnlyTracked: true}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+
+	if files := gitOutput(t, remote, "ls-tree", "-r", "--name-only", "main"); files != ".gitignore\ndata.txt\n" {
+		t.Errorf("files on main = %q, want only the tracked files", files)
+	}
+	if got := gitOutput(t, remote, "show", "main:data.txt"); got != "new" {
+		t.Errorf("data.txt = %q, want new", got)
+	}
+	if message := gitOutput(t, remote, "log", "-1", "--format=%B", "main"); strings.Contains(message, "new.txt") || strings.Contains(message, "debug.log") {
+		t.Errorf("commit message mentions untracked files: %q", message)
+	}
+}
+
+func TestPushIntegrationNoVerify(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks are shell scripts")
+	}
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"a.txt": "old"})
+
+	// Hooks configured for every repository, as a global core.hooksPath does
+	hooksDir := t.TempDir()
+	for _, hook := range []string{"pre-commit", "pre-push"} {
+		if err := os.WriteFile(filepath.Join(hooksDir, hook), []byte("#!/bin/sh\necho blocked by "+hook+" >&2\nexit 1\n"), 0755); err != nil {
+			t.Fatalf("failed to write %s hook: %v", hook, err)
+		}
+	}
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "core.hooksPath")
+	t.Setenv("GIT_CONFIG_VALUE_0", hooksDir)
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "a.txt", "new")
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main"}
+	if err := runSyncer(t, config); !errors.Is(err, ErrCommitFailed) {
+		t.Fatalf("push with a failing pre-commit hook error = %v, want ErrCommitFailed", err)
+	}
+
+	config.NoVerify = true
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("push with -no-verify failed: %v", err)
+	}
+	if got := gitOutput(t, remote, "show", "main:a.txt"); got != "new" {
+		t.Errorf("a.txt = %q, want new", got)
+	}
+}
+
+func TestPushIntegrationMirrorRemote(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"a.txt": "old"})
+	mirror := filepath.Join(t.TempDir(), "mirror.git")
+	runGit(t, t.TempDir(), "init", "--bare", mirror)
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "a.txt", "new")
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", MirrorRemote: mirror, Tag: "v1"}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("push with -mirror-remote failed: %v", err)
+	}
+
+	primary := gitOutput(t, remote, "rev-parse", "main")
+	if got := gitOutput(t, mirror, "rev-parse", "main"); got != primary {
+		t.Errorf("mirror main = %q, want the pushed commit %q", got, primary)
+	}
+	if got := gitOutput(t, mirror, "rev-parse", "v1^{commit}"); got != primary {
+		t.Errorf("mirror tag v1 = %q, want %q", got, primary)
+	}
+}
+
+func TestPushIntegrationMirrorFailure(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"a.txt": "old"})
+	missing := filepath.Join(t.TempDir(), "missing.git")
+	sourceDir := t.TempDir()
+
+	// An unreachable mirror only warns
+	writeTestFile(t, sourceDir, "a.txt", "new")
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", MirrorRemote: missing}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("push with a failing optional mirror error = %v, want nil", err)
+	}
+	if got := gitOutput(t, remote, "show", "main:a.txt"); got != "new" {
+		t.Errorf("a.txt = %q, want the primary push to go through", got)
+	}
+
+	// A required mirror fails the run
+	writeTestFile(t, sourceDir, "a.txt", "newer")
+	config.MirrorRequired = true
+	if err := runSyncer(t, config); !errors.Is(err, ErrPushFailed) {
+		t.Errorf("push with a failing required mirror error = %v, want ErrPushFailed", err)
+	}
+}
+
+func TestPushIntegrationSingleFile(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"seed.txt": "seed"})
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "notes.txt", "notes")
+	writeTestFile(t, sourceDir, "other.txt", "other")
+
+	config := Config{
+		Mode:            ModePush,
+		FolderPath:      filepath.Join(sourceDir, "notes.txt"),
+		RepoURL:         remote,
+		Branch:          "main",
+		AllowSingleFile: true,
+	}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+
+	files := gitOutput(t, remote, "ls-tree", "-r", "--name-only", "main")
+	if files != "notes.txt\nseed.txt\n" {
+		t.Errorf("unexpected files on main: %q", files)
+	}
+	if content := gitOutput(t, remote, "show", "main:notes.txt"); content != "notes" {
+		t.Errorf("notes.txt content = %q, want %q", content, "notes")
+	}
+}
+
+func TestPullIntegrationConflictMarkersLeaveFolder(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"config.yml": "<<<<<<< HEAD\nport: 80\n=======\nport: 8080\n>>>>>>> feature\n",
+		"new.txt":    "new",
+	})
+	folder := t.TempDir()
+	writeTestFile(t, folder, "config.yml", "port: 80\n")
+
+	config := Config{Mode: ModePull, FolderPath: folder, RepoURL: remote, Branch: "main", ScanConflictMarkers: true}
+	if err := runSyncer(t, config); !errors.Is(err, ErrConflictMarkers) {
+		t.Fatalf("pull error = %v, want ErrConflictMarkers", err)
+	}
+	assertFileContent(t, filepath.Join(folder, "config.yml"), "port: 80\n")
+	if _, err := os.Stat(filepath.Join(folder, "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("new.txt was pulled despite the conflict markers: %v", err)
+	}
+}
+
+func TestPullIntegrationClean(t *testing.T) {
+	requireGit(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"tracked.txt": "tracked"})
+
+	for _, clean := range []bool{false, true} {
+		destDir := t.TempDir()
+		writeTestFile(t, destDir, "extra/local.txt", "local")
+
+		config := Config{Mode: ModePull, FolderPath: destDir, RepoURL: remote, Branch: "main", Clean: clean}
+		if err := runSyncer(t, config); err != nil {
+			t.Fatalf("pull with clean=%v failed: %v", clean, err)
+		}
+
+		assertFileContent(t, filepath.Join(destDir, "tracked.txt"), "tracked")
+		_, err := os.Stat(filepath.Join(destDir, "extra"))
+		if clean && !os.IsNotExist(err) {
+			t.Error("-clean should remove files that are not in the repository")
+		}
+		if !clean && err != nil {
+			t.Errorf("pull without -clean should keep extra files: %v", err)
+		}
+	}
+}
+
+func TestPullIntegrationInterruptedKeepsDestination(t *testing.T) {
+	requireGit(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"a.txt":       "new a",
+		"b.txt":       "new b",
+		"config.tmpl": "{{.FILE_SYNCER_TEST_SETTING}}",
+	})
+	destDir := t.TempDir()
+	writeTestFile(t, destDir, "a.txt", "old a")
+	writeTestFile(t, destDir, "b.txt", "old b")
+
+	// The template fails to render after a.txt and b.txt were copied
+	config := Config{Mode: ModePull, FolderPath: destDir, RepoURL: remote, Branch: "main", RenderTemplates: true}
+	if err := runSyncer(t, config); err == nil {
+		t.Fatal("pull with an unset template variable should fail")
+	}
+	assertFileContent(t, filepath.Join(destDir, "a.txt"), "old a")
+	assertFileContent(t, filepath.Join(destDir, "b.txt"), "old b")
+	if _, err := os.Stat(filepath.Join(destDir, stagingDir)); !os.IsNotExist(err) {
+		t.Errorf("failed pull left its staging directory behind: %v", err)
+	}
+
+	// Running it again once the cause is fixed completes the pull
+	t.Setenv("FILE_SYNCER_TEST_SETTING", "on")
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("second pull failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(destDir, "a.txt"), "new a")
+	assertFileContent(t, filepath.Join(destDir, "b.txt"), "new b")
+	assertFileContent(t, filepath.Join(destDir, "config"), "on")
+}
+
+func TestPullIntegrationLFS(t *testing.T) {
+	requireGit(t)
+	if err := exec.Command("git", "lfs", "version").Run(); err != nil {
+		t.Skip("git-lfs not available")
+	}
+
+	baseDir := t.TempDir()
+	remote := filepath.Join(baseDir, "remote.git")
+	runGit(t, baseDir, "init", "--bare", remote)
+
+	workingDir := t.TempDir()
+	runGit(t, workingDir, "init")
+	runGit(t, workingDir, "config", "user.email", "file-syncer@example.com")
+	runGit(t, workingDir, "config", "user.name", "file-syncer")
+	runGit(t, workingDir, "lfs", "install", "--local")
+	runGit(t, workingDir, "lfs", "track", "*.bin")
+	writeTestFile(t, workingDir, "asset.bin", "binary content")
+	runGit(t, workingDir, "add", ".")
+	runGit(t, workingDir, "commit", "-m", "seed")
+	runGit(t, workingDir, "branch", "-M", "main")
+	runGit(t, workingDir, "remote", "add", "origin", remote)
+	runGit(t, workingDir, "push", "-u", "origin", "main")
+	runGit(t, remote, "symbolic-ref", "HEAD", "refs/heads/main")
+
+	// Clone without smudging so that only -lfs can bring the content down
+	t.Setenv("GIT_LFS_SKIP_SMUDGE", "1")
+
+	destDir := t.TempDir()
+	config := Config{Mode: ModePull, FolderPath: destDir, RepoURL: remote, Branch: "main", LFS: true}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("pull failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(destDir, "asset.bin"), "binary content")
+}
+
+func TestPushIntegrationDryRun(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"notes.txt": "one\n"})
+	sourceDir := t.TempDir()
+	writeTestFile(t, sourceDir, "notes.txt", "two\n")
+	before := gitOutput(t, remote, "rev-parse", "main")
+
+	config := Config{Mode: ModePush, FolderPath: sourceDir, RepoURL: remote, Branch: "main", DryRun: true, Diff: true}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+
+	if after := gitOutput(t, remote, "rev-parse", "main"); after != before {
+		t.Errorf("dry run must not push, main moved from %s to %s", before, after)
+	}
+}
+
+func TestPullIntegrationArchiveStrategy(t *testing.T) {
+	requireGit(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"README.md":          "readme",
+		"docs/guide.md":      "guide",
+		"docs/deep/notes.md": "notes",
+		".gitignore":         "*.tmp\n",
+	})
+
+	trees := map[string]map[string]string{}
+	for _, strategy := range []string{StrategyCopy, StrategyArchive} {
+		destDir := t.TempDir()
+		config := Config{Mode: ModePull, FolderPath: destDir, RepoURL: remote, Branch: "main", Strategy: strategy}
+		if err := runSyncer(t, config); err != nil {
+			t.Fatalf("pull with -strategy %s failed: %v", strategy, err)
+		}
+		trees[strategy] = readTree(t, destDir)
+	}
+
+	if !reflect.DeepEqual(trees[StrategyArchive], trees[StrategyCopy]) {
+		t.Errorf("archive strategy extracted %v, copy strategy wrote %v", trees[StrategyArchive], trees[StrategyCopy])
+	}
+	if _, ok := trees[StrategyArchive]["docs/deep/notes.md"]; !ok {
+		t.Errorf("nested file missing from archive extraction: %v", trees[StrategyArchive])
+	}
+}
+
+func TestPullIntegrationRef(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"version.txt": "1.0"})
+
+	// Tag the seed commit, then move main on
+	workingDir := t.TempDir()
+	runGit(t, workingDir, "clone", remote, ".")
+	runGit(t, workingDir, "tag", "v1.0")
+	writeTestFile(t, workingDir, "version.txt", "2.0")
+	runGit(t, workingDir, "commit", "-am", "bump")
+	runGit(t, workingDir, "push", "origin", "main", "v1.0")
+
+	destDir := t.TempDir()
+	config := Config{Mode: ModePull, FolderPath: destDir, RepoURL: remote, Ref: "v1.0"}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("pull of v1.0 failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(destDir, "version.txt"), "1.0")
+
+	config.Ref = "v9.9"
+	err := runSyncer(t, config)
+	if err == nil || !strings.Contains(err.Error(), `ref "v9.9" does not exist`) {
+		t.Errorf("pull of a missing ref: error = %v, want a ref does not exist error", err)
+	}
+}
+
+func TestSyncIntegrationCleanMerge(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{
+		"shared.txt": "shared",
+		"remote.txt": "remote v1",
+		"local.txt":  "local v1",
+		"gone.txt":   "gone",
+	})
+	folder := t.TempDir()
+	config := Config{Mode: ModeSync, FolderPath: folder, RepoURL: remote, Branch: "main"}
+
+	// The first sync pulls everything and records the base
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("initial sync failed: %v", err)
+	}
+	assertFileContent(t, filepath.Join(folder, "shared.txt"), "shared")
+
+	// Change different files on each side
+	workingDir := t.TempDir()
+	runGit(t, workingDir, "clone", remote, ".")
+	writeTestFile(t, workingDir, "remote.txt", "remote v2")
+	writeTestFile(t, workingDir, "added-remotely.txt", "new remote")
+	runGit(t, workingDir, "add", "-A")
+	runGit(t, workingDir, "commit", "-m", "remote edits")
+	runGit(t, workingDir, "push", "origin", "main")
+
+	writeTestFile(t, folder, "local.txt", "local v2")
+	writeTestFile(t, folder, "added-locally.txt", "new local")
+	if err := os.Remove(filepath.Join(folder, "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(folder, "remote.txt"), "remote v2")
+	assertFileContent(t, filepath.Join(folder, "added-remotely.txt"), "new remote")
+	if files := gitOutput(t, remote, "ls-tree", "-r", "--name-only", "main"); files != "added-locally.txt\nadded-remotely.txt\nlocal.txt\nremote.txt\nshared.txt\n" {
+		t.Errorf("unexpected files on main: %q", files)
+	}
+	if content := gitOutput(t, remote, "show", "main:local.txt"); content != "local v2" {
+		t.Errorf("local.txt on main = %q, want %q", content, "local v2")
+	}
+
+	// Both sides now agree
+	if err := runSyncer(t, config); !errors.Is(err, ErrNoChanges) {
+		t.Errorf("sync without changes: error = %v, want ErrNoChanges", err)
+	}
+}
+
+func TestSyncIntegrationConflict(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"notes.txt": "base", "other.txt": "other"})
+	folder := t.TempDir()
+	config := Config{Mode: ModeSync, FolderPath: folder, RepoURL: remote, Branch: "main"}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("initial sync failed: %v", err)
+	}
+
+	workingDir := t.TempDir()
+	runGit(t, workingDir, "clone", remote, ".")
+	writeTestFile(t, workingDir, "notes.txt", "theirs")
+	writeTestFile(t, workingDir, "other.txt", "other v2")
+	runGit(t, workingDir, "commit", "-am", "remote edit")
+	runGit(t, workingDir, "push", "origin", "main")
+	writeTestFile(t, folder, "notes.txt", "ours")
+
+	err := runSyncer(t, config)
+	if !errors.Is(err, ErrSyncConflict) || !strings.Contains(err.Error(), "notes.txt") {
+		t.Fatalf("sync error = %v, want ErrSyncConflict naming notes.txt", err)
+	}
+	assertFileContent(t, filepath.Join(folder, "notes.txt"), "ours")
+	assertFileContent(t, filepath.Join(folder, "notes.txt.conflict"), "theirs")
+	assertFileContent(t, filepath.Join(folder, "other.txt"), "other v2")
+	if content := gitOutput(t, remote, "show", "main:notes.txt"); content != "theirs" {
+		t.Errorf("conflicting file must not be pushed, main has %q", content)
+	}
+	manifest, err := readSyncManifest(filepath.Join(folder, syncManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(manifest.Conflicts, []string{"notes.txt"}) {
+		t.Errorf("manifest conflicts = %v, want [notes.txt]", manifest.Conflicts)
+	}
+
+	// Unresolved conflicts keep failing
+	if err := runSyncer(t, config); !errors.Is(err, ErrSyncConflict) {
+		t.Errorf("sync with unresolved conflict: error = %v, want ErrSyncConflict", err)
+	}
+
+	// Resolving the conflict pushes the merged file
+	writeTestFile(t, folder, "notes.txt", "merged")
+	if err := os.Remove(filepath.Join(folder, "notes.txt.conflict")); err != nil {
+		t.Fatal(err)
+	}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("sync after resolving failed: %v", err)
+	}
+	if content := gitOutput(t, remote, "show", "main:notes.txt"); content != "merged" {
+		t.Errorf("notes.txt on main = %q, want %q", content, "merged")
+	}
+}
+
+func TestSyncIntegrationSyncsOwnConflictFiles(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"theirs.conflict": "from the repository"})
+	folder := t.TempDir()
+	writeTestFile(t, folder, "mine.conflict", "from the folder")
+	config := Config{Mode: ModeSync, FolderPath: folder, RepoURL: remote, Branch: "main"}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	// Files that merely end in .conflict are the user's, not conflict copies
+	assertFileContent(t, filepath.Join(folder, "theirs.conflict"), "from the repository")
+	if content := gitOutput(t, remote, "show", "main:mine.conflict"); content != "from the folder" {
+		t.Errorf("mine.conflict on main = %q, want %q", content, "from the folder")
+	}
+}
+
+func TestSyncIntegrationChecksumAlgo(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+
+	remote := createRemoteRepoWithContent(t, map[string]string{"notes.txt": "base"})
+	folder := t.TempDir()
+	config := Config{Mode: ModeSync, FolderPath: folder, RepoURL: remote, Branch: "main", ChecksumAlgo: ChecksumBLAKE2b}
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("initial sync failed: %v", err)
+	}
+	manifest, err := readSyncManifest(filepath.Join(folder, syncManifestFile))
+	if err != nil {
+		t.Fatalf("readSyncManifest() failed: %v", err)
+	}
+	want, err := hashFile(filepath.Join(folder, "notes.txt"), ChecksumBLAKE2b)
+	if err != nil {
+		t.Fatalf("hashFile() failed: %v", err)
+	}
+	if manifest.Algorithm != ChecksumBLAKE2b || manifest.Files["notes.txt"] != want {
+		t.Errorf("manifest = %+v, want blake2b digests", manifest)
+	}
+
+	// The base can't be used with another algorithm
+	writeTestFile(t, folder, "notes.txt", "local edit")
+	config.ChecksumAlgo = ChecksumSHA512
+	if err := runSyncer(t, config); err == nil || !strings.Contains(err.Error(), "-checksum-algo blake2b") {
+		t.Errorf("sync with another algorithm: error = %v, want one naming blake2b", err)
+	}
+	if content := gitOutput(t, remote, "show", "main:notes.txt"); content != "base" {
+		t.Errorf("notes.txt on main = %q, want it untouched", content)
+	}
+
+	config.ChecksumAlgo = ChecksumBLAKE2b
+	if err := runSyncer(t, config); err != nil {
+		t.Fatalf("sync with the manifest's algorithm failed: %v", err)
+	}
+	if content := gitOutput(t, remote, "show", "main:notes.txt"); content != "local edit" {
+		t.Errorf("notes.txt on main = %q, want %q", content, "local edit")
+	}
+}
+
+func TestPullIntegrationRecurseSubmodules(t *testing.T) {
+	requireGit(t)
+	setGitIdentityEnv(t)
+	// Recent git refuses local file:// submodules unless allowed
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+
+	library := createRemoteRepoWithContent(t, map[string]string{"lib.txt": "library", "docs/api.md": "api"})
+	remote := createRemoteRepoWithContent(t, map[string]string{"app.txt": "app"})
+
+	workingDir := t.TempDir()
+	runGit(t, workingDir, "clone", remote, ".")
+	runGit(t, workingDir, "submodule", "add", library, "vendor/lib")
+	runGit(t, workingDir, "commit", "-m", "add submodule")
+	runGit(t, workingDir, "push", "origin", "main")
+
+	for _, recurse := range []bool{false, true} {
+		destDir := t.TempDir()
+		config := Config{Mode: ModePull, FolderPath: destDir, RepoURL: remote, Branch: "main", RecurseSubmodules: recurse}
+		if err := runSyncer(t, config); err != nil {
+			t.Fatalf("pull with recurse-submodules=%v failed: %v", recurse, err)
+		}
+
+		tree := readTree(t, destDir)
+		if recurse {
+			if tree["vendor/lib/lib.txt"] != "library" || tree["vendor/lib/docs/api.md"] != "api" {
+				t.Errorf("submodule files missing from pull: %v", tree)
+			}
+			if _, ok := tree["vendor/lib/.git"]; ok {
+				t.Error("the submodule's .git link should not be copied")
+			}
+		} else if _, ok := tree["vendor/lib/lib.txt"]; ok {
+			t.Errorf("submodule files pulled without -recurse-submodules: %v", tree)
+		}
+		if tree["app.txt"] != "app" {
+			t.Errorf("app.txt missing from pull: %v", tree)
+		}
+	}
+}
+
+// readTree returns the content of every file below dir, keyed by its
+// slash-separated relative path.
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", dir, err)
+	}
+	return files
+}
+
+func createRemoteRepoWithContent(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	baseDir := t.TempDir()
+	remotePath := filepath.Join(baseDir, "remote.git")
+	runGit(t, baseDir, "init", "--bare", remotePath)
+
+	workingDir := t.TempDir()
+	runGit(t, workingDir, "init")
+	runGit(t, workingDir, "config", "user.email", "file-syncer@example.com")
+	runGit(t, workingDir, "config", "user.name", "file-syncer")
+
+	for path, content := range files {
+		writeTestFile(t, workingDir, path, content)
+	}
+
+	runGit(t, workingDir, "add", ".")
+	runGit(t, workingDir, "commit", "-m", "seed")
+	runGit(t, workingDir, "branch", "-M", "main")
+	runGit(t, workingDir, "remote", "add", "origin", remotePath)
+	runGit(t, workingDir, "push", "-u", "origin", "main")
+	runGit(t, remotePath, "symbolic-ref", "HEAD", "refs/heads/main")
+
+	return remotePath
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\nOutput: %s", strings.Join(args, " "), err, string(output))
+	}
+}
+
+func assertFileContent(t *testing.T, path, want string) {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	if string(content) != want {
+		t.Fatalf("unexpected content in %s: got %q, want %q", path, string(content), want)
+	}
+}
+
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git %s failed: %v", strings.Join(args, " "), err)
+	}
+	return string(output)
+}
+
+func writeTestFile(t *testing.T, baseDir, relativePath, content string) {
+	t.Helper()
+
+	fullPath := filepath.Join(baseDir, filepath.FromSlash(relativePath))
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatalf("failed to create directories for %s: %v", relativePath, err)
+	}
+
+	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file %s: %v", relativePath, err)
+	}
+}
+
+// runSyncer runs a push, pull or sync for config, depending on its mode.
+func runSyncer(t *testing.T, config Config) error {
+	t.Helper()
+
+	s, err := New(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		return err
+	}
+	switch config.Mode {
+	case ModePush:
+		_, err := s.Push(context.Background())
+		return err
+	case ModeSync:
+		_, err := s.Sync(context.Background())
+		return err
+	}
+	return s.Pull(context.Background())
+}
+
+// aliasRemoteURL makes git resolve repoURL to the local repository at path
+// using url.<base>.insteadOf, so remote URL forms of other hosts can be
+// exercised without network access.
+func aliasRemoteURL(t *testing.T, repoURL, path string) {
+	t.Helper()
+
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "url."+path+".insteadOf")
+	t.Setenv("GIT_CONFIG_VALUE_0", repoURL)
+}
+
+func setGitIdentityEnv(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("GIT_AUTHOR_NAME", "file-syncer")
+	t.Setenv("GIT_AUTHOR_EMAIL", "file-syncer@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "file-syncer")
+	t.Setenv("GIT_COMMITTER_EMAIL", "file-syncer@example.com")
+}
+
+func requireGit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available in PATH")
+	}
+}
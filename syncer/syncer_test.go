@@ -0,0 +1,69 @@
+package syncer
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	valid := Config{
+		Mode:       ModePush,
+		FolderPath: "/tmp/test",
+		RepoURL:    "https://github.com/user/repo.git",
+		Branch:     "main",
+	}
+
+	s, err := New(valid, nil)
+	if err != nil {
+		t.Fatalf("New() unexpected error: %v", err)
+	}
+	if s.logger != slog.Default() {
+		t.Error("New() with nil logger should use slog.Default()")
+	}
+
+	invalid := valid
+	invalid.Mode = "sideways"
+	if _, err := New(invalid, nil); err == nil {
+		t.Error("New() should reject an invalid config")
+	}
+}
+
+func TestMakeTempDir(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		s := newTestSyncer(t, Config{
+			Mode:       ModePush,
+			FolderPath: "/tmp/test",
+			RepoURL:    "https://github.com/user/repo.git",
+			KeepTemp:   keep,
+		})
+
+		dir, removeTemp, err := s.makeTempDir()
+		if err != nil {
+			t.Fatalf("makeTempDir() failed: %v", err)
+		}
+		removeTemp()
+
+		_, err = os.Stat(dir)
+		if keep {
+			if err != nil {
+				t.Errorf("with -keep-temp the temp dir should persist: %v", err)
+			}
+			os.RemoveAll(dir)
+		} else if !os.IsNotExist(err) {
+			t.Errorf("without -keep-temp the temp dir should be removed, stat error = %v", err)
+		}
+	}
+}
+
+// newTestSyncer returns a Syncer for config that discards its log output.
+func newTestSyncer(t *testing.T, config Config) *Syncer {
+	t.Helper()
+
+	s, err := New(config, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	return s
+}
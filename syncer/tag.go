@@ -0,0 +1,75 @@
+package syncer
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// tagTemplateData is the data available to -tag-template.
+type tagTemplateData struct {
+	// Date is the UTC date of the push as YYYYMMDD
+	Date string
+	// Commit is the abbreviated hash of the pushed commit
+	Commit string
+}
+
+// parseTagTemplate parses a -tag-template value.
+func parseTagTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("tag").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderTagName renders a tag template for a push made at now of commit.
+func renderTagName(text string, now time.Time, commit string) (string, error) {
+	tmpl, err := parseTagTemplate(text)
+	if err != nil {
+		return "", err
+	}
+
+	shortCommit := commit
+	if len(shortCommit) > 7 {
+		shortCommit = shortCommit[:7]
+	}
+
+	var name strings.Builder
+	data := tagTemplateData{Date: now.UTC().Format("20060102"), Commit: shortCommit}
+	if err := tmpl.Execute(&name, data); err != nil {
+		return "", fmt.Errorf("failed to render tag template: %w", err)
+	}
+	return strings.TrimSpace(name.String()), nil
+}
+
+// tagName returns the tag to create for the pushed commit, or an empty string
+// when tagging is not configured.
+func (c Config) tagName(now time.Time, commit string) (string, error) {
+	if c.TagTemplate != "" {
+		return renderTagName(c.TagTemplate, now, commit)
+	}
+	return c.Tag, nil
+}
+
+// tagArgs builds the git arguments that create an annotated tag.
+func tagArgs(name, message string, force bool) []string {
+	args := []string{"tag", "-a"}
+	if force {
+		args = append(args, "-f")
+	}
+	return append(args, name, "-m", message)
+}
+
+// pushTagArgs builds the git arguments that push a tag to origin.
+func pushTagArgs(name string, force, noVerify bool) []string {
+	args := []string{"push"}
+	if force {
+		args = append(args, "--force")
+	}
+	if noVerify {
+		args = append(args, "--no-verify")
+	}
+	return append(args, "origin", "refs/tags/"+name)
+}
@@ -0,0 +1,88 @@
+package syncer
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRenderTagName(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC)
+	commit := "0123456789abcdef0123456789abcdef01234567"
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{name: "date", template: "sync-{{.Date}}", want: "sync-20240101"},
+		{name: "commit", template: "snapshot-{{.Commit}}", want: "snapshot-0123456"},
+		{name: "date and commit", template: "sync-{{.Date}}-{{.Commit}}", want: "sync-20240101-0123456"},
+		{name: "literal", template: "release", want: "release"},
+		{name: "unknown field", template: "sync-{{.Branch}}", wantErr: true},
+		{name: "invalid syntax", template: "sync-{{.Date", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderTagName(tt.template, now, commit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderTagName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("renderTagName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagArgs(t *testing.T) {
+	if got, want := tagArgs("v1", "Sync 1 file", false), []string{"tag", "-a", "v1", "-m", "Sync 1 file"}; !slices.Equal(got, want) {
+		t.Errorf("tagArgs() = %q, want %q", got, want)
+	}
+	if got, want := tagArgs("v1", "Sync 1 file", true), []string{"tag", "-a", "-f", "v1", "-m", "Sync 1 file"}; !slices.Equal(got, want) {
+		t.Errorf("tagArgs() forced = %q, want %q", got, want)
+	}
+	if got, want := pushTagArgs("v1", false, false), []string{"push", "origin", "refs/tags/v1"}; !slices.Equal(got, want) {
+		t.Errorf("pushTagArgs() = %q, want %q", got, want)
+	}
+	if got, want := pushTagArgs("v1", true, false), []string{"push", "--force", "origin", "refs/tags/v1"}; !slices.Equal(got, want) {
+		t.Errorf("pushTagArgs() forced = %q, want %q", got, want)
+	}
+	if got, want := pushTagArgs("v1", false, true), []string{"push", "--no-verify", "origin", "refs/tags/v1"}; !slices.Equal(got, want) {
+		t.Errorf("pushTagArgs() without hooks = %q, want %q", got, want)
+	}
+}
+
+func TestValidateConfigTagFlags(t *testing.T) {
+	base := Config{
+		Mode:       ModePush,
+		FolderPath: "/tmp/test",
+		RepoURL:    "https://github.com/user/repo.git",
+		Branch:     "main",
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{name: "tag", modify: func(c *Config) { c.Tag = "v1" }},
+		{name: "tag template", modify: func(c *Config) { c.TagTemplate = "sync-{{.Date}}" }},
+		{name: "invalid template", modify: func(c *Config) { c.TagTemplate = "sync-{{" }, wantErr: true},
+		{name: "tag and template", modify: func(c *Config) { c.Tag, c.TagTemplate = "v1", "sync-{{.Date}}" }, wantErr: true},
+		{name: "tag in pull mode", modify: func(c *Config) { c.Mode, c.Tag = ModePull, "v1" }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := base
+			tt.modify(&config)
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package syncer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// DefaultTemplateSuffix marks the files rendered by -render-templates when
+// no -template-suffix is given.
+const DefaultTemplateSuffix = ".tmpl"
+
+// templateTarget reports whether relPath is a template to render and, if
+// so, returns dstPath without the template suffix.
+func (o syncOptions) templateTarget(relPath, dstPath string) (string, bool) {
+	if o.TemplateSuffix == "" || !strings.HasSuffix(relPath, o.TemplateSuffix) {
+		return "", false
+	}
+	// A file named just like the suffix has no name to render to
+	name := strings.TrimSuffix(relPath, o.TemplateSuffix)
+	if name == "" || os.IsPathSeparator(name[len(name)-1]) {
+		return "", false
+	}
+	return strings.TrimSuffix(dstPath, o.TemplateSuffix), true
+}
+
+// templateSource reports whether the destination file relPath is rendered
+// from a template in srcDir, so that removeExtraneous keeps it.
+func (o syncOptions) templateSource(srcDir, relPath string) (bool, error) {
+	if o.TemplateSuffix == "" {
+		return false, nil
+	}
+	info, err := os.Lstat(filepath.Join(srcDir, relPath+o.TemplateSuffix))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !info.IsDir(), nil
+}
+
+// renderTemplate executes the template file src with TemplateData and
+// writes the output to dst with mode. It reports whether dst was written:
+// with SkipUnchanged, a dst that already holds the output is left alone.
+func renderTemplate(src, dst string, mode os.FileMode, opts syncOptions) (bool, error) {
+	text, err := os.ReadFile(src)
+	if err != nil {
+		return false, err
+	}
+	// Fail on unset variables instead of rendering "<no value>"
+	tmpl, err := template.New(src).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return false, fmt.Errorf("failed to parse template: %w", err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, opts.TemplateData); err != nil {
+		return false, fmt.Errorf("failed to render template: %w", err)
+	}
+
+	if opts.SkipUnchanged {
+		if info, err := os.Stat(dst); err == nil && info.Mode().IsRegular() && opts.samePerm(info.Mode().Perm(), mode.Perm()) {
+			current, err := os.ReadFile(dst)
+			if err != nil {
+				return false, err
+			}
+			if bytes.Equal(current, out.Bytes()) {
+				return false, nil
+			}
+		}
+	}
+
+	_, err = os.Lstat(dst)
+	created := os.IsNotExist(err)
+	perm, readOnly, err := opts.prepareOverwrite(dst)
+	if err != nil {
+		return false, err
+	}
+	if readOnly {
+		mode = perm
+	}
+	target := opts.Staging.target(dst)
+	if err := os.WriteFile(target, out.Bytes(), mode); err != nil {
+		return false, err
+	}
+	// As in copyFile, the mode is masked by the umask on creation
+	if err := os.Chmod(target, mode.Perm()); err != nil {
+		return false, err
+	}
+	if opts.FileCopied != nil {
+		opts.FileCopied(dst, int64(out.Len()), created)
+	}
+	return true, nil
+}
+
+// environData returns the process environment as template data, so that
+// templates can refer to variables as {{.HOME}}.
+func environData() map[string]string {
+	data := map[string]string{}
+	for _, kv := range os.Environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok && key != "" {
+			data[key] = value
+		}
+	}
+	return data
+}
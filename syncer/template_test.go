@@ -0,0 +1,95 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncFilesRendersTemplates(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{
+		"app.conf.tmpl":   "host = {{.APP_HOST}}\nport = {{.APP_PORT}}\n",
+		"plain.txt":       "{{.APP_HOST}} stays as it is",
+		"dir/nested.tmpl": `{{if index . "DEBUG"}}debug{{else}}quiet{{end}}`,
+	})
+	opts := syncOptions{
+		TemplateSuffix: ".tmpl",
+		TemplateData:   map[string]string{"APP_HOST": "example.com", "APP_PORT": "8080"},
+	}
+
+	counts, err := syncFiles(srcDir, dstDir, opts)
+	if err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+	if counts.Copied != 3 || counts.Created != 3 {
+		t.Errorf("counts = %+v, want 3 copied and created", counts)
+	}
+
+	for path, want := range map[string]string{
+		"app.conf":   "host = example.com\nport = 8080\n",
+		"plain.txt":  "{{.APP_HOST}} stays as it is",
+		"dir/nested": "quiet",
+	} {
+		content, err := os.ReadFile(filepath.Join(dstDir, path))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", path, content, want)
+		}
+	}
+	for _, path := range []string{"app.conf.tmpl", "dir/nested.tmpl"} {
+		if _, err := os.Stat(filepath.Join(dstDir, path)); !os.IsNotExist(err) {
+			t.Errorf("template %s was copied as well: %v", path, err)
+		}
+	}
+
+	// Rendering again with the same data leaves the output alone
+	opts.SkipUnchanged = true
+	counts, err = syncFiles(srcDir, dstDir, opts)
+	if err != nil {
+		t.Fatalf("second syncFiles() failed: %v", err)
+	}
+	if counts.Skipped != 3 || counts.Copied != 0 {
+		t.Errorf("second counts = %+v, want 3 skipped", counts)
+	}
+
+	// -clean keeps rendered files, whose name is not in the source
+	removed, err := removeExtraneous(srcDir, dstDir, opts)
+	if err != nil {
+		t.Fatalf("removeExtraneous() failed: %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("removeExtraneous() removed %v, want nothing", removed)
+	}
+}
+
+func TestSyncFilesFailsOnUnsetTemplateVariable(t *testing.T) {
+	srcDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{"app.conf.tmpl": "host = {{.MISSING}}"})
+
+	_, err := syncFiles(srcDir, t.TempDir(), syncOptions{TemplateSuffix: ".tmpl", TemplateData: map[string]string{}})
+	if err == nil || !strings.Contains(err.Error(), "app.conf.tmpl") {
+		t.Errorf("syncFiles() error = %v, want a render error naming app.conf.tmpl", err)
+	}
+}
+
+func TestSyncFilesCopiesTemplatesWithoutRendering(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{"app.conf.tmpl": "host = {{.APP_HOST}}"})
+
+	if _, err := syncFiles(srcDir, dstDir, syncOptions{}); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dstDir, "app.conf.tmpl"))
+	if err != nil {
+		t.Fatalf("failed to read app.conf.tmpl: %v", err)
+	}
+	if string(content) != "host = {{.APP_HOST}}" {
+		t.Errorf("app.conf.tmpl = %q, want it copied unchanged", content)
+	}
+}
@@ -0,0 +1,57 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// updateWorkDir brings the persistent checkout in dir up to date with the
+// remote branch. The first run clones into dir, creating the branch on push
+// like a fresh clone would; later runs fetch and hard reset instead of
+// downloading the whole repository again. Untracked files are removed so the
+// checkout mirrors the remote exactly.
+func (s *Syncer) updateWorkDir(ctx context.Context, dir string) error {
+	config := s.config
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		s.logger.Info("Cloning repository into work directory", "url", SanitizeURL(config.RepoURL), "branch", config.Branch, "work_dir", dir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create work directory: %w", err)
+		}
+		if config.Mode == ModePush {
+			return s.cloneForPush(ctx, dir)
+		}
+		if err := s.runCommand(ctx, dir, "git", "clone", "--branch", config.Branch, config.RepoURL, "."); err != nil {
+			return fmt.Errorf("%w: %w", ErrCloneFailed, err)
+		}
+		return nil
+	}
+
+	// Refuse to reuse a checkout of a different repository
+	origin, err := s.runCommandOutput(ctx, dir, "git", "remote", "get-url", "origin")
+	if err != nil {
+		return fmt.Errorf("failed to read origin of work directory %s: %w", dir, err)
+	}
+	// The output is redacted, so compare against the redacted URL
+	if strings.TrimSpace(origin) != redact(config.RepoURL) {
+		return fmt.Errorf("work directory %s is a checkout of %s, not %s", dir, strings.TrimSpace(origin), redact(config.RepoURL))
+	}
+
+	s.logger.Info("Updating work directory", "branch", config.Branch, "work_dir", dir)
+	if err := s.runCommand(ctx, dir, "git", "fetch", "origin", config.Branch); err != nil {
+		return fmt.Errorf("failed to fetch branch %s: %w", config.Branch, err)
+	}
+	if err := s.runCommand(ctx, dir, "git", "checkout", "-B", config.Branch, "origin/"+config.Branch); err != nil {
+		return fmt.Errorf("failed to check out branch %s: %w", config.Branch, err)
+	}
+	if err := s.runCommand(ctx, dir, "git", "reset", "--hard", "origin/"+config.Branch); err != nil {
+		return fmt.Errorf("failed to reset work directory: %w", err)
+	}
+	if err := s.runCommand(ctx, dir, "git", "clean", "-ffdx"); err != nil {
+		return fmt.Errorf("failed to clean work directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+//go:build linux
+
+package syncer
+
+import (
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestSyncFilesPreservesXattrs(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	createTestFiles(t, srcDir, map[string]string{"app.conf": "key=value"})
+
+	src := filepath.Join(srcDir, "app.conf")
+	if err := syscall.Setxattr(src, "user.file-syncer.test", []byte("yes"), 0); err != nil {
+		if errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EPERM) {
+			t.Skipf("extended attributes not supported here: %v", err)
+		}
+		t.Fatalf("failed to set xattr: %v", err)
+	}
+
+	var warnings int
+	opts := syncOptions{
+		PreserveXattrs:  true,
+		MetadataWarning: func(string, error) { warnings++ },
+	}
+	if _, err := syncFiles(srcDir, dstDir, opts); err != nil {
+		t.Fatalf("syncFiles() failed: %v", err)
+	}
+	if warnings != 0 {
+		t.Errorf("got %d metadata warnings, want none", warnings)
+	}
+
+	value, err := getXattr(filepath.Join(dstDir, "app.conf"), "user.file-syncer.test")
+	if err != nil {
+		t.Fatalf("failed to read xattr of synced file: %v", err)
+	}
+	if string(value) != "yes" {
+		t.Errorf("xattr = %q, want %q", value, "yes")
+	}
+}
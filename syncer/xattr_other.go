@@ -0,0 +1,9 @@
+//go:build !linux
+
+package syncer
+
+// copyXattrs is only implemented on Linux; the standard library has no
+// extended attribute calls for other platforms.
+func copyXattrs(src, dst string) error {
+	return errMetadataUnsupported
+}